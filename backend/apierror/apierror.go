@@ -0,0 +1,179 @@
+// Package apierror defines the errors the API reports to clients and the
+// JSON envelope they are written in:
+//
+//	{"error": {"code": "trip_not_found", "message": "Trip not found", "requestId": "..."}}
+//
+// Codes are stable and meant for programs; messages are meant for people
+// and are translated according to the request's Accept-Language header.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"ticket-booking-app/backend/logging"
+
+	"golang.org/x/text/language"
+)
+
+// Error is an error answered with Status and a message looked up by Code.
+type Error struct {
+	Status int
+	Code   string
+	// Details is sent along as is, e.g. the invalid fields of a request.
+	Details interface{}
+}
+
+// New returns an error with the given status and code. The code should
+// have an entry in the message catalog.
+func New(status int, code string) *Error {
+	return &Error{Status: status, Code: code}
+}
+
+func (e *Error) Error() string {
+	return e.Code
+}
+
+// WithDetails returns a copy of e carrying details.
+func (e *Error) WithDetails(details interface{}) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+var (
+	// Malformed requests
+	ErrInvalidBody       = New(http.StatusBadRequest, "invalid_request_body")
+	ErrValidation        = New(http.StatusBadRequest, "validation_failed")
+	ErrInvalidID         = New(http.StatusBadRequest, "invalid_id")
+	ErrInvalidCode       = New(http.StatusBadRequest, "invalid_code")
+	ErrInvalidLink       = New(http.StatusBadRequest, "invalid_link")
+	ErrInvalidPhone      = New(http.StatusBadRequest, "invalid_phone")
+	ErrUnknownRole       = New(http.StatusBadRequest, "unknown_role")
+	ErrUnknownTripStatus = New(http.StatusBadRequest, "unknown_trip_status")
+	// ErrBusNotFound and ErrOperatorNotFound are for IDs given in a request
+	// body, hence not a 404.
+	ErrBusNotFound           = New(http.StatusBadRequest, "bus_not_found")
+	ErrOperatorNotFound      = New(http.StatusBadRequest, "operator_not_found")
+	ErrUserExists            = New(http.StatusBadRequest, "user_exists")
+	ErrMFASetupRequired      = New(http.StatusBadRequest, "mfa_setup_required")
+	ErrRevokeOwnAdmin        = New(http.StatusBadRequest, "cannot_revoke_own_admin")
+	ErrBodyTooLarge          = New(http.StatusRequestEntityTooLarge, "request_too_large")
+	ErrInvalidIdempotencyKey = New(http.StatusBadRequest, "invalid_idempotency_key")
+
+	// Authentication
+	ErrUnauthenticated     = New(http.StatusUnauthorized, "authentication_required")
+	ErrInvalidToken        = New(http.StatusUnauthorized, "invalid_token")
+	ErrTokenExpired        = New(http.StatusUnauthorized, "token_expired")
+	ErrSessionRevoked      = New(http.StatusUnauthorized, "session_revoked")
+	ErrSessionExpired      = New(http.StatusUnauthorized, "session_expired")
+	ErrLoginExpired        = New(http.StatusUnauthorized, "login_expired")
+	ErrInvalidRefreshToken = New(http.StatusUnauthorized, "invalid_refresh_token")
+	ErrRefreshTokenReused  = New(http.StatusUnauthorized, "refresh_token_reused")
+	// ErrInvalidCredentials does not say whether the email or the password
+	// was wrong, so that it cannot be used to find registered addresses.
+	ErrInvalidCredentials = New(http.StatusUnauthorized, "invalid_credentials")
+	ErrUnknownUser        = New(http.StatusUnauthorized, "user_not_found")
+	ErrTooManyAttempts    = New(http.StatusTooManyRequests, "too_many_attempts")
+	ErrTooManyCodes       = New(http.StatusTooManyRequests, "too_many_codes")
+	ErrTooManyRequests    = New(http.StatusTooManyRequests, "too_many_requests")
+
+	// Authorization
+	ErrForbidden            = New(http.StatusForbidden, "forbidden")
+	ErrOperatorRequired     = New(http.StatusForbidden, "operator_required")
+	ErrOtherOperator        = New(http.StatusForbidden, "other_operator")
+	ErrMFARequired          = New(http.StatusForbidden, "mfa_required")
+	ErrVerificationRequired = New(http.StatusForbidden, "verification_required")
+
+	// Missing resources
+	ErrNotFound         = New(http.StatusNotFound, "not_found")
+	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, "method_not_allowed")
+	ErrUserNotFound     = New(http.StatusNotFound, "user_not_found")
+	ErrTripNotFound     = New(http.StatusNotFound, "trip_not_found")
+	ErrBookingNotFound  = New(http.StatusNotFound, "booking_not_found")
+	ErrSessionNotFound  = New(http.StatusNotFound, "session_not_found")
+	ErrRoleNotAssigned  = New(http.StatusNotFound, "role_not_assigned")
+	ErrNotWaitlisted    = New(http.StatusNotFound, "not_waitlisted")
+
+	// Conflicts with the current state
+	ErrSeatTaken               = New(http.StatusConflict, "seat_taken")
+	ErrBookingCancelled        = New(http.StatusConflict, "booking_already_cancelled")
+	ErrTripHasBookings         = New(http.StatusConflict, "trip_has_bookings")
+	ErrInvalidStatusTransition = New(http.StatusConflict, "invalid_status_transition")
+	ErrTripNotBookable         = New(http.StatusConflict, "trip_not_bookable")
+	ErrBusInUse                = New(http.StatusConflict, "bus_in_use")
+	ErrPhoneTaken              = New(http.StatusConflict, "phone_taken")
+	ErrEmailTaken              = New(http.StatusConflict, "email_taken")
+	ErrEmailVerified           = New(http.StatusConflict, "email_already_verified")
+	ErrHasEmail                = New(http.StatusConflict, "account_has_email")
+	ErrMFANotEnabled           = New(http.StatusConflict, "mfa_not_enabled")
+	ErrMFAAlreadyEnabled       = New(http.StatusConflict, "mfa_already_enabled")
+	ErrIdempotencyKeyReused    = New(http.StatusConflict, "idempotency_key_reused")
+	ErrRequestInProgress       = New(http.StatusConflict, "request_in_progress")
+	ErrSeatsAvailable          = New(http.StatusConflict, "seats_available")
+	ErrAlreadyWaitlisted       = New(http.StatusConflict, "already_waitlisted")
+	ErrNoWaitlistOffer         = New(http.StatusConflict, "no_waitlist_offer")
+
+	ErrInternal = New(http.StatusInternalServerError, "internal_error")
+)
+
+type envelope struct {
+	Error payload `json:"error"`
+}
+
+type payload struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// Write answers r with err in the language the client prefers.
+func Write(w http.ResponseWriter, r *http.Request, err *Error) {
+	lang := Language(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(envelope{payload{
+		Code:      err.Code,
+		Message:   Message(err.Code, lang),
+		Details:   err.Details,
+		RequestID: logging.RequestID(r.Context()),
+	}})
+}
+
+// NotFound and MethodNotAllowed answer requests the router has no route
+// for.
+var (
+	NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, ErrNotFound)
+	})
+	MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, ErrMethodNotAllowed)
+	})
+)
+
+var matcher = language.NewMatcher([]language.Tag{language.English, language.Amharic})
+
+// Language returns the supported language that best matches the request's
+// Accept-Language header, "en" or "am".
+func Language(r *http.Request) string {
+	tag, _ := language.MatchStrings(matcher, r.Header.Get("Accept-Language"))
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// Message returns the message for code in lang, falling back to English
+// and then to the code itself.
+func Message(code, lang string) string {
+	byLanguage := messages[code]
+	if msg, ok := byLanguage[lang]; ok {
+		return msg
+	}
+	if msg, ok := byLanguage[DefaultLanguage]; ok {
+		return msg
+	}
+	return code
+}
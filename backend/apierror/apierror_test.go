@@ -0,0 +1,57 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ticket-booking-app/backend/logging"
+)
+
+func TestEveryMessageIsTranslated(t *testing.T) {
+	for code, byLanguage := range messages {
+		for _, lang := range []string{"en", "am"} {
+			if byLanguage[lang] == "" {
+				t.Errorf("%s has no %s message", code, lang)
+			}
+		}
+	}
+}
+
+func TestWriteUsesTheClientsLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                          "Trip not found",
+		"am-ET":                     "ጉዞው አልተገኘም",
+		"fr-FR, am;q=0.8":           "ጉዞው አልተገኘም",
+		"de, en-US;q=0.9, am;q=0.5": "Trip not found",
+	}
+	for header, want := range cases {
+		req := httptest.NewRequest("GET", "/api/trips/9", nil)
+		req.Header.Set("Accept-Language", header)
+		req = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+		rr := httptest.NewRecorder()
+		Write(rr, req, ErrTripNotFound)
+
+		if rr.Code != http.StatusNotFound || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%q: got %d %s", header, rr.Code, rr.Header().Get("Content-Type"))
+		}
+		var body envelope
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("could not unmarshal response: %v", err)
+		}
+		if body.Error.Code != "trip_not_found" || body.Error.Message != want || body.Error.RequestID != "req-1" {
+			t.Errorf("%q: unexpected error %+v", header, body.Error)
+		}
+	}
+}
+
+func TestWithDetailsCopies(t *testing.T) {
+	err := ErrValidation.WithDetails(map[string]string{"email": "required"})
+	if ErrValidation.Details != nil {
+		t.Error("WithDetails modified the shared error")
+	}
+	if err.Status != http.StatusBadRequest || err.Code != "validation_failed" {
+		t.Errorf("unexpected error %+v", err)
+	}
+}
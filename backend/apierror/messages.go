@@ -0,0 +1,250 @@
+package apierror
+
+const DefaultLanguage = "en"
+
+// messages holds the error messages by code and language. Every code must
+// have an entry for DefaultLanguage.
+var messages = map[string]map[string]string{
+	// Malformed requests
+	"invalid_request_body": {
+		"en": "Invalid request body",
+		"am": "የጥያቄው ይዘት ልክ አይደለም",
+	},
+	"validation_failed": {
+		"en": "Some fields are missing or invalid",
+		"am": "አንዳንድ መስኮች ጎድለዋል ወይም ልክ አይደሉም",
+	},
+	"request_too_large": {
+		"en": "Request body is too large",
+		"am": "የጥያቄው ይዘት በጣም ትልቅ ነው",
+	},
+	"invalid_idempotency_key": {
+		"en": "Idempotency-Key must be at most 255 printable characters",
+		"am": "Idempotency-Key ቢበዛ 255 የሚታተሙ ቁምፊዎች መሆን አለበት",
+	},
+	"invalid_id": {
+		"en": "Invalid ID",
+		"am": "መለያ ቁጥሩ ልክ አይደለም",
+	},
+	"invalid_code": {
+		"en": "Invalid or expired code",
+		"am": "ኮዱ ልክ አይደለም ወይም ጊዜው አልፎበታል",
+	},
+	"invalid_link": {
+		"en": "Invalid or expired link",
+		"am": "ሊንኩ ልክ አይደለም ወይም ጊዜው አልፎበታል",
+	},
+	"invalid_phone": {
+		"en": "Invalid phone number",
+		"am": "የስልክ ቁጥሩ ልክ አይደለም",
+	},
+	"unknown_role": {
+		"en": "Unknown role",
+		"am": "ያልታወቀ ሚና",
+	},
+	"unknown_trip_status": {
+		"en": "Unknown trip status",
+		"am": "ያልታወቀ የጉዞ ሁኔታ",
+	},
+
+	// Authentication
+	"authentication_required": {
+		"en": "Sign in to continue",
+		"am": "ለመቀጠል እባክዎ ይግቡ",
+	},
+	"invalid_token": {
+		"en": "Invalid token",
+		"am": "ማስመሰያው ልክ አይደለም",
+	},
+	"token_expired": {
+		"en": "Token has expired",
+		"am": "የማስመሰያው ጊዜ አልፏል",
+	},
+	"session_revoked": {
+		"en": "Session has been revoked",
+		"am": "ክፍለ ጊዜው ተሰርዟል",
+	},
+	"session_expired": {
+		"en": "Session has expired",
+		"am": "የክፍለ ጊዜው ጊዜ አልፏል",
+	},
+	"login_expired": {
+		"en": "Login has expired, sign in again",
+		"am": "የመግቢያው ጊዜ አልፏል፣ እባክዎ እንደገና ይግቡ",
+	},
+	"invalid_refresh_token": {
+		"en": "Invalid refresh token",
+		"am": "የማደሻ ማስመሰያው ልክ አይደለም",
+	},
+	"refresh_token_reused": {
+		"en": "Refresh token has already been used",
+		"am": "የማደሻ ማስመሰያው ቀድሞ ጥቅም ላይ ውሏል",
+	},
+	"invalid_credentials": {
+		"en": "Invalid email or password",
+		"am": "ኢሜይሉ ወይም የይለፍ ቃሉ ትክክል አይደለም",
+	},
+	"mfa_required": {
+		"en": "Two-factor authentication is required for your role",
+		"am": "ለሚናዎ ባለሁለት ደረጃ ማረጋገጫ ያስፈልጋል",
+	},
+	"mfa_not_enabled": {
+		"en": "Two-factor authentication is not enabled",
+		"am": "ባለሁለት ደረጃ ማረጋገጫ አልበራም",
+	},
+	"mfa_already_enabled": {
+		"en": "Two-factor authentication is already enabled",
+		"am": "ባለሁለት ደረጃ ማረጋገጫ አስቀድሞ በርቷል",
+	},
+	"mfa_setup_required": {
+		"en": "Set up an authenticator first",
+		"am": "መጀመሪያ የማረጋገጫ መተግበሪያ ያዘጋጁ",
+	},
+	"too_many_attempts": {
+		"en": "Too many failed attempts, try again later",
+		"am": "ብዙ ያልተሳኩ ሙከራዎች ተደርገዋል፣ ቆይተው እንደገና ይሞክሩ",
+	},
+	"too_many_codes": {
+		"en": "Too many codes requested, try again later",
+		"am": "ብዙ ኮዶች ተጠይቀዋል፣ ቆይተው እንደገና ይሞክሩ",
+	},
+	"too_many_requests": {
+		"en": "Too many requests, try again later",
+		"am": "ብዙ ጥያቄዎች ተልከዋል፣ ቆይተው እንደገና ይሞክሩ",
+	},
+
+	// Authorization
+	"forbidden": {
+		"en": "You do not have permission to do this",
+		"am": "ይህን ለማድረግ ፈቃድ የለዎትም",
+	},
+	"operator_required": {
+		"en": "Operator account required",
+		"am": "የኦፕሬተር መለያ ያስፈልጋል",
+	},
+	"other_operator": {
+		"en": "Resource belongs to another operator",
+		"am": "ይህ የሌላ ኦፕሬተር ነው",
+	},
+	"verification_required": {
+		"en": "Please verify your email address or phone number to make more bookings",
+		"am": "ተጨማሪ ቦታ ለማስያዝ እባክዎ የኢሜይል አድራሻዎን ወይም ስልክ ቁጥርዎን ያረጋግጡ",
+	},
+	"cannot_revoke_own_admin": {
+		"en": "Admins cannot revoke their own admin role",
+		"am": "አስተዳዳሪዎች የራሳቸውን የአስተዳዳሪ ሚና መሰረዝ አይችሉም",
+	},
+
+	// Missing resources
+	"not_found": {
+		"en": "Not found",
+		"am": "አልተገኘም",
+	},
+	"method_not_allowed": {
+		"en": "Method not allowed",
+		"am": "ይህ ዘዴ አይፈቀድም",
+	},
+	"user_not_found": {
+		"en": "User not found",
+		"am": "ተጠቃሚው አልተገኘም",
+	},
+	"trip_not_found": {
+		"en": "Trip not found",
+		"am": "ጉዞው አልተገኘም",
+	},
+	"booking_not_found": {
+		"en": "Booking not found",
+		"am": "ቦታ ማስያዣው አልተገኘም",
+	},
+	"session_not_found": {
+		"en": "Session not found",
+		"am": "ክፍለ ጊዜው አልተገኘም",
+	},
+	"bus_not_found": {
+		"en": "Bus not found",
+		"am": "አውቶቡሱ አልተገኘም",
+	},
+	"operator_not_found": {
+		"en": "Operator not found",
+		"am": "ኦፕሬተሩ አልተገኘም",
+	},
+	"role_not_assigned": {
+		"en": "User does not have this role",
+		"am": "ተጠቃሚው ይህ ሚና የለውም",
+	},
+	"not_waitlisted": {
+		"en": "You are not on the waitlist for this trip",
+		"am": "ለዚህ ጉዞ በተጠባባቂ ዝርዝር ውስጥ የሉም",
+	},
+
+	// Conflicts with the current state
+	"seat_taken": {
+		"en": "Some of the seats are already taken",
+		"am": "ከመቀመጫዎቹ አንዳንዶቹ ተይዘዋል",
+	},
+	"user_exists": {
+		"en": "User already exists",
+		"am": "ተጠቃሚው አስቀድሞ አለ",
+	},
+	"booking_already_cancelled": {
+		"en": "Booking already cancelled",
+		"am": "ቦታ ማስያዣው አስቀድሞ ተሰርዟል",
+	},
+	"trip_has_bookings": {
+		"en": "Trip has bookings; cancel it instead",
+		"am": "ጉዞው የተያዙ ቦታዎች አሉት፤ በምትኩ ይሰርዙት",
+	},
+	"invalid_status_transition": {
+		"en": "The trip cannot move to this status",
+		"am": "ጉዞው ወደዚህ ሁኔታ መሸጋገር አይችልም",
+	},
+	"trip_not_bookable": {
+		"en": "The trip is no longer open for booking",
+		"am": "ጉዞው ለቦታ ማስያዝ ክፍት አይደለም",
+	},
+	"bus_in_use": {
+		"en": "Bus is still assigned to trips",
+		"am": "አውቶቡሱ አሁንም ለጉዞዎች ተመድቧል",
+	},
+	"phone_taken": {
+		"en": "Phone number belongs to another account",
+		"am": "የስልክ ቁጥሩ የሌላ መለያ ነው",
+	},
+	"email_taken": {
+		"en": "Email belongs to another account",
+		"am": "ኢሜይሉ የሌላ መለያ ነው",
+	},
+	"email_already_verified": {
+		"en": "Email address already verified",
+		"am": "የኢሜይል አድራሻው አስቀድሞ ተረጋግጧል",
+	},
+	"account_has_email": {
+		"en": "Account already has an email address",
+		"am": "መለያው አስቀድሞ የኢሜይል አድራሻ አለው",
+	},
+	"idempotency_key_reused": {
+		"en": "Idempotency-Key was already used for a different request",
+		"am": "Idempotency-Key ቀደም ሲል ለሌላ ጥያቄ ጥቅም ላይ ውሏል",
+	},
+	"request_in_progress": {
+		"en": "A request with this Idempotency-Key is still being processed",
+		"am": "ይህ Idempotency-Key ያለው ጥያቄ አሁንም በሂደት ላይ ነው",
+	},
+	"seats_available": {
+		"en": "The trip still has enough free seats; book them instead",
+		"am": "ጉዞው አሁንም በቂ ነፃ መቀመጫዎች አሉት፤ በምትኩ ቦታ ይያዙ",
+	},
+	"already_waitlisted": {
+		"en": "You are already on the waitlist for this trip",
+		"am": "ለዚህ ጉዞ አስቀድመው በተጠባባቂ ዝርዝር ውስጥ ነዎት",
+	},
+	"no_waitlist_offer": {
+		"en": "No seats are held for you, or the hold has expired",
+		"am": "ለእርስዎ የተያዘ መቀመጫ የለም ወይም የመያዣው ጊዜ አልፏል",
+	},
+
+	"internal_error": {
+		"en": "Something went wrong, please try again later",
+		"am": "ችግር ተፈጥሯል፣ እባክዎ ቆይተው እንደገና ይሞክሩ",
+	},
+}
@@ -0,0 +1,272 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/config"
+)
+
+// newAuth returns an Auth with the default settings on store.
+func newAuth(t *testing.T, store auth.Store) *auth.Auth {
+	a, err := auth.New(config.Default().Auth, store)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a
+}
+
+// sessions is a store in which every session but the revoked ones is active.
+type sessions struct {
+	auth.Store
+	revoked map[int]bool
+}
+
+func (s sessions) IsSessionActive(ctx context.Context, id int) (bool, error) {
+	return !s.revoked[id], nil
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func serveWithRoles(t *testing.T, h http.Handler, roles ...string) int {
+	a := newAuth(t, nil)
+	token, err := a.SignToken(auth.NewClaims(1, "user@example.com", roles, 0, time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	a.Middleware(h).ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestMiddlewareCarriesRoles(t *testing.T) {
+	var got *auth.Claims
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = auth.ClaimsFromContext(r.Context())
+	})
+
+	serveWithRoles(t, h, "operator")
+
+	if got == nil {
+		t.Fatal("expected claims in request context")
+	}
+	if !got.HasRole(auth.RoleOperator) || got.HasRole(auth.RoleAdmin) {
+		t.Errorf("unexpected roles in claims: %v", got.Roles)
+	}
+	if !got.HasPermission(auth.PermManageTrips) || got.HasPermission(auth.PermManageRoles) {
+		t.Errorf("unexpected permissions in claims: %v", got.Permissions)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	h := auth.RequireRole(auth.RoleAdmin, auth.RoleOperator)(okHandler())
+
+	cases := []struct {
+		roles []string
+		want  int
+	}{
+		{[]string{"admin"}, http.StatusOK},
+		{[]string{"customer", "operator"}, http.StatusOK},
+		{[]string{"customer"}, http.StatusForbidden},
+		{nil, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if got := serveWithRoles(t, h, tc.roles...); got != tc.want {
+			t.Errorf("roles %v: got status %v want %v", tc.roles, got, tc.want)
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	h := auth.RequirePermission(auth.PermViewTripBookings)(okHandler())
+
+	cases := []struct {
+		roles []string
+		want  int
+	}{
+		{[]string{"admin"}, http.StatusOK},
+		{[]string{"operator"}, http.StatusOK},
+		{[]string{"conductor"}, http.StatusOK},
+		{[]string{"customer"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if got := serveWithRoles(t, h, tc.roles...); got != tc.want {
+			t.Errorf("roles %v: got status %v want %v", tc.roles, got, tc.want)
+		}
+	}
+}
+
+func TestRequireRoleWithoutMiddleware(t *testing.T) {
+	rr := httptest.NewRecorder()
+	auth.RequireRole(auth.RoleAdmin)(okHandler()).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPermissionsFor(t *testing.T) {
+	got := auth.PermissionsFor([]string{"conductor", "customer", "unknown"})
+	want := []string{"bookings:create", "bookings:view-trip", "trips:update-status"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestMiddlewareRejectsRevokedSession(t *testing.T) {
+	a := newAuth(t, sessions{revoked: map[int]bool{2: true}})
+
+	for sessionID, want := range map[int]int{1: http.StatusOK, 2: http.StatusUnauthorized} {
+		claims := auth.NewClaims(1, "user@example.com", []string{"customer"}, 0, time.Minute)
+		claims.SessionID = sessionID
+		token, err := a.SignToken(claims)
+		if err != nil {
+			t.Fatalf("Failed to create token: %v", err)
+		}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		a.Middleware(okHandler()).ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("session %d: got status %v want %v", sessionID, rr.Code, want)
+		}
+	}
+}
+
+func TestMiddlewareRejectsBadTokensWith401(t *testing.T) {
+	a := newAuth(t, nil)
+	expired, err := a.SignToken(auth.NewClaims(1, "user@example.com", []string{"customer"}, 0, -time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	for token, code := range map[string]string{expired: "token_expired", "not-a-jwt": "invalid_token"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		a.Middleware(okHandler()).ServeHTTP(rr, req)
+
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if rr.Code != http.StatusUnauthorized || body.Error.Code != code {
+			t.Errorf("%s: got status %v code %q, want %v %q", code, rr.Code, body.Error.Code, http.StatusUnauthorized, code)
+		}
+	}
+}
+
+func TestTokenHash(t *testing.T) {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		t.Fatalf("NewToken failed: %v", err)
+	}
+	other, _, _ := auth.NewToken()
+	if token == other {
+		t.Error("expected refresh tokens to be random")
+	}
+	if hash != auth.HashToken(token) || len(hash) != 64 || hash == token {
+		t.Errorf("unexpected hash %q for token %q", hash, token)
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	valid := map[string]string{
+		"+251911234567":     "+251911234567",
+		"+251 91 123 4567":  "+251911234567",
+		"251911234567":      "+251911234567",
+		"00251911234567":    "+251911234567",
+		"0911234567":        "+251911234567",
+		"0911-23-45-67":     "+251911234567",
+		"911234567":         "+251911234567",
+		"0712345678":        "+251712345678",
+		"(+251) 712 345678": "+251712345678",
+	}
+	for raw, want := range valid {
+		if got, err := auth.NormalizePhone(raw); err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"", "0111234567", "+25191123456", "+2519112345678", "+254711234567", "09112345ab", "abc"} {
+		if got, err := auth.NormalizePhone(raw); err == nil {
+			t.Errorf("NormalizePhone(%q) = %q, expected an error", raw, got)
+		}
+	}
+}
+
+// Test vectors from RFC 6238 appendix B, truncated to six digits.
+func TestTOTPCode(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+	cases := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range cases {
+		got, err := auth.TOTPCode(secret, auth.TOTPStep(time.Unix(unix, 0)))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret failed: %v", err)
+	}
+	now := time.Now()
+	step := auth.TOTPStep(now)
+	previous, _ := auth.TOTPCode(secret, step-1)
+	stale, _ := auth.TOTPCode(secret, step-3)
+
+	if got, ok := auth.VerifyTOTP(secret, previous, now, 0); !ok || got != step-1 {
+		t.Errorf("expected the previous code to be accepted for clock drift, got %d %v", got, ok)
+	}
+	if _, ok := auth.VerifyTOTP(secret, stale, now, 0); ok {
+		t.Error("expected a code from three periods ago to be rejected")
+	}
+	if _, ok := auth.VerifyTOTP(secret, previous, now, step-1); ok {
+		t.Error("expected a replayed code to be rejected")
+	}
+
+	uri := auth.TOTPURI("BusTicket", "admin@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/BusTicket:admin@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("unexpected otpauth URI %q", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := auth.NewRecoveryCodes(10)
+	if err != nil || len(codes) != 10 {
+		t.Fatalf("NewRecoveryCodes = %v, %v", codes, err)
+	}
+	if len(codes[0]) != 19 || codes[0] == codes[1] {
+		t.Errorf("unexpected codes %v", codes)
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if auth.NormalizeRecoveryCode(typed) != codes[0] {
+		t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", typed, auth.NormalizeRecoveryCode(typed), codes[0])
+	}
+}
+
+func TestWithoutMFARoles(t *testing.T) {
+	a := newAuth(t, nil)
+	roles := []string{"admin", "conductor", "customer"}
+	if !a.MFARequired(roles) || a.MFARequired([]string{"conductor"}) {
+		t.Error("unexpected MFARequired result")
+	}
+	if got := a.WithoutMFARoles(roles); !reflect.DeepEqual(got, []string{"conductor", "customer"}) {
+		t.Errorf("WithoutMFARoles = %v", got)
+	}
+}
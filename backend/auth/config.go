@@ -0,0 +1,44 @@
+package auth
+
+import (
+    "fmt"
+
+    "ticket-booking-app/backend/config"
+)
+
+// Auth signs and checks the server's access tokens. main builds one with New
+// and hands it to the middleware and handlers that need it.
+type Auth struct {
+    // Keys signs and verifies access tokens.
+    Keys *KeyManager
+    // MFARequiredRoles lists the roles whose privileges are only granted to
+    // sessions that passed two-factor authentication.
+    MFARequiredRoles map[Role]bool
+    // Store is where Middleware checks sessions and OperatorMiddleware
+    // resolves trips and buses.
+    Store Store
+}
+
+// New returns an Auth configured from cfg that looks sessions and operator
+// resources up in store. Without a keys directory tokens are signed with a
+// temporary key, which is fine for tests and a single development instance
+// but invalidates every token on restart.
+func New(cfg config.AuthConfig, store Store) (*Auth, error) {
+    required := map[Role]bool{}
+    for _, role := range cfg.MFARequiredRoles {
+        if !ValidRole(role) {
+            return nil, fmt.Errorf("config: auth.mfa_required_roles: unknown role %q", role)
+        }
+        required[Role(role)] = true
+    }
+
+    keys := NewDevelopmentKeys(cfg.Issuer, cfg.Audience)
+    if cfg.KeysDir != "" {
+        keys = NewKeyManager(cfg.Issuer, cfg.Audience)
+        if err := LoadKeys(keys, cfg.KeysDir, cfg.SigningKey); err != nil {
+            return nil, fmt.Errorf("config: auth.keys_dir: %w", err)
+        }
+    }
+
+    return &Auth{Keys: keys, MFARequiredRoles: required, Store: store}, nil
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+    "crypto"
+    "crypto/ed25519"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "math/big"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+
+    "github.com/golang-jwt/jwt/v5"
+)
+
+// Default issuer and audience of access tokens.
+const (
+    DefaultIssuer   = "ticket-booking-app"
+    DefaultAudience = "ticket-booking-api"
+)
+
+// minHMACSecret is the shortest HS256 secret accepted, 256 bits.
+const minHMACSecret = 32
+
+var (
+    ErrUnknownKey   = errors.New("unknown signing key")
+    ErrNoSigningKey = errors.New("no signing key configured")
+)
+
+// Key is a JWT signing or verification key. Keys made from a public key only
+// verify tokens.
+type Key struct {
+    ID     string
+    Method jwt.SigningMethod
+    sign   interface{}
+    verify interface{}
+}
+
+// CanSign reports whether the key holds secret or private key material.
+func (k *Key) CanSign() bool {
+    return k.sign != nil
+}
+
+// NewHMACKey returns an HS256 key. The secret must be at least 32 bytes.
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+    if len(secret) < minHMACSecret {
+        return nil, fmt.Errorf("key %q: HMAC secret must be at least %d bytes", id, minHMACSecret)
+    }
+    return &Key{ID: id, Method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+}
+
+// NewPrivateKey returns an RS256 or EdDSA key for an RSA or Ed25519 private
+// key.
+func NewPrivateKey(id string, private crypto.Signer) (*Key, error) {
+    key, err := NewPublicKey(id, private.Public())
+    if err != nil {
+        return nil, err
+    }
+    key.sign = private
+    return key, nil
+}
+
+// NewPublicKey returns a verification-only key, for tokens signed by a key
+// this server does not hold, e.g. another instance's during rotation.
+func NewPublicKey(id string, public crypto.PublicKey) (*Key, error) {
+    switch pub := public.(type) {
+    case *rsa.PublicKey:
+        if pub.N.BitLen() < 2048 {
+            return nil, fmt.Errorf("key %q: RSA keys must be at least 2048 bits", id)
+        }
+        return &Key{ID: id, Method: jwt.SigningMethodRS256, verify: pub}, nil
+    case ed25519.PublicKey:
+        return &Key{ID: id, Method: jwt.SigningMethodEdDSA, verify: pub}, nil
+    default:
+        return nil, fmt.Errorf("key %q: unsupported key type %T", id, public)
+    }
+}
+
+// ParseKeyPEM reads a PKCS#8 or PKCS#1 private key, or a PKIX public key.
+func ParseKeyPEM(id string, data []byte) (*Key, error) {
+    block, _ := pem.Decode(data)
+    if block == nil {
+        return nil, fmt.Errorf("key %q: no PEM data", id)
+    }
+    switch block.Type {
+    case "PRIVATE KEY":
+        private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("key %q: %v", id, err)
+        }
+        signer, ok := private.(crypto.Signer)
+        if !ok {
+            return nil, fmt.Errorf("key %q: unsupported key type %T", id, private)
+        }
+        return NewPrivateKey(id, signer)
+    case "RSA PRIVATE KEY":
+        private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("key %q: %v", id, err)
+        }
+        return NewPrivateKey(id, private)
+    case "PUBLIC KEY":
+        public, err := x509.ParsePKIXPublicKey(block.Bytes)
+        if err != nil {
+            return nil, fmt.Errorf("key %q: %v", id, err)
+        }
+        return NewPublicKey(id, public)
+    default:
+        return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+    }
+}
+
+// KeyManager signs access tokens with one key and verifies them with any of
+// its keys, chosen by the token's "kid" header. To rotate, add the new key,
+// switch signing to it once every instance has it, and remove the old key
+// after the longest-lived token signed with it has expired.
+type KeyManager struct {
+    Issuer   string
+    Audience string
+
+    mu      sync.RWMutex
+    keys    map[string]*Key
+    signing *Key
+}
+
+func NewKeyManager(issuer, audience string) *KeyManager {
+    return &KeyManager{Issuer: issuer, Audience: audience, keys: map[string]*Key{}}
+}
+
+// Add makes key available for verification, replacing any key with the same
+// ID.
+func (m *KeyManager) Add(key *Key) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.keys[key.ID] = key
+}
+
+// Remove stops accepting tokens signed with the key. The signing key cannot
+// be removed.
+func (m *KeyManager) Remove(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.signing != nil && m.signing.ID == id {
+        return fmt.Errorf("key %q is the signing key", id)
+    }
+    delete(m.keys, id)
+    return nil
+}
+
+// SetSigningKey switches new tokens to the key with the given ID.
+func (m *KeyManager) SetSigningKey(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    key, ok := m.keys[id]
+    if !ok {
+        return fmt.Errorf("%w %q", ErrUnknownKey, id)
+    }
+    if !key.CanSign() {
+        return fmt.Errorf("key %q has no private key", id)
+    }
+    m.signing = key
+    return nil
+}
+
+// Sign fills in the issuer and audience and signs claims with the current
+// signing key.
+func (m *KeyManager) Sign(claims *Claims) (string, error) {
+    m.mu.RLock()
+    key := m.signing
+    m.mu.RUnlock()
+    if key == nil {
+        return "", ErrNoSigningKey
+    }
+
+    claims.Issuer = m.Issuer
+    claims.Audience = jwt.ClaimStrings{m.Audience}
+    token := jwt.NewWithClaims(key.Method, claims)
+    token.Header["kid"] = key.ID
+    return token.SignedString(key.sign)
+}
+
+// Parse verifies a token and returns its claims. The token must name one of
+// the manager's keys, be signed with that key's algorithm, and carry the
+// expected issuer, audience and an expiry.
+func (m *KeyManager) Parse(tokenString string) (*Claims, error) {
+    claims := &Claims{}
+    _, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+        kid, _ := token.Header["kid"].(string)
+        m.mu.RLock()
+        key, ok := m.keys[kid]
+        m.mu.RUnlock()
+        if !ok {
+            return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+        }
+        // The algorithm comes from the key, never from the token, so an
+        // RSA public key cannot be passed off as an HMAC secret
+        if token.Method.Alg() != key.Method.Alg() {
+            return nil, fmt.Errorf("key %q: unexpected algorithm %s", kid, token.Method.Alg())
+        }
+        return key.verify, nil
+    },
+        jwt.WithIssuer(m.Issuer),
+        jwt.WithAudience(m.Audience),
+        jwt.WithExpirationRequired(),
+        jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+    )
+    if err != nil {
+        return nil, err
+    }
+    return claims, nil
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+    KeyType string `json:"kty"`
+    Use     string `json:"use"`
+    KeyID   string `json:"kid"`
+    Alg     string `json:"alg"`
+    // RSA
+    N string `json:"n,omitempty"`
+    E string `json:"e,omitempty"`
+    // Ed25519
+    Curve string `json:"crv,omitempty"`
+    X     string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys other services can verify tokens with. HMAC
+// keys are secret and never included.
+func (m *KeyManager) JWKS() []JWK {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    keys := []JWK{}
+    for _, key := range m.keys {
+        jwk := JWK{Use: "sig", KeyID: key.ID, Alg: key.Method.Alg()}
+        switch pub := key.verify.(type) {
+        case *rsa.PublicKey:
+            jwk.KeyType = "RSA"
+            jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+            jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+        case ed25519.PublicKey:
+            jwk.KeyType = "OKP"
+            jwk.Curve = "Ed25519"
+            jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+        default:
+            continue
+        }
+        keys = append(keys, jwk)
+    }
+    sort.Slice(keys, func(i, j int) bool { return keys[i].KeyID < keys[j].KeyID })
+    return keys
+}
+
+// LoadKeys reads every key in dir. The file name without its extension is
+// the key ID: "<kid>.pem" holds a PEM private or public key and
+// "<kid>.secret" an HMAC secret. signingKey names the key to sign with.
+func LoadKeys(m *KeyManager, dir, signingKey string) error {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return err
+    }
+    for _, entry := range entries {
+        if entry.IsDir() {
+            continue
+        }
+        ext := filepath.Ext(entry.Name())
+        id := strings.TrimSuffix(entry.Name(), ext)
+        if ext != ".pem" && ext != ".secret" {
+            continue
+        }
+
+        data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+        if err != nil {
+            return err
+        }
+        var key *Key
+        if ext == ".pem" {
+            key, err = ParseKeyPEM(id, data)
+        } else {
+            key, err = NewHMACKey(id, []byte(strings.TrimSpace(string(data))))
+        }
+        if err != nil {
+            return err
+        }
+        m.Add(key)
+    }
+    return m.SetSigningKey(signingKey)
+}
+
+// NewDevelopmentKeys returns a key manager with a random HMAC key.
+func NewDevelopmentKeys(issuer, audience string) *KeyManager {
+    secret := make([]byte, minHMACSecret)
+    if _, err := rand.Read(secret); err != nil {
+        panic(err)
+    }
+    m := NewKeyManager(issuer, audience)
+    key, _ := NewHMACKey("development", secret)
+    m.Add(key)
+    m.SetSigningKey(key.ID)
+    return m
+}
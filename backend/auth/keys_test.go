@@ -0,0 +1,184 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func hmacKey(t *testing.T, id string) *auth.Key {
+	key, err := auth.NewHMACKey(id, []byte(strings.Repeat(id, 32)))
+	if err != nil {
+		t.Fatalf("NewHMACKey: %v", err)
+	}
+	return key
+}
+
+func newClaims() *auth.Claims {
+	return auth.NewClaims(1, "user@example.com", []string{"customer"}, 0, time.Minute)
+}
+
+func TestKeyRotation(t *testing.T) {
+	m := auth.NewKeyManager(auth.DefaultIssuer, auth.DefaultAudience)
+	m.Add(hmacKey(t, "old"))
+	m.SetSigningKey("old")
+	oldToken, _ := m.Sign(newClaims())
+
+	// Tokens signed before the switch keep working until the key is removed
+	m.Add(hmacKey(t, "new"))
+	if err := m.SetSigningKey("new"); err != nil {
+		t.Fatalf("SetSigningKey: %v", err)
+	}
+	newToken, _ := m.Sign(newClaims())
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := m.Parse(token); err != nil {
+			t.Errorf("%s token rejected: %v", name, err)
+		}
+	}
+
+	if err := m.Remove("new"); err == nil {
+		t.Error("expected removing the signing key to fail")
+	}
+	m.Remove("old")
+	if _, err := m.Parse(oldToken); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("token of removed key: got %v want %v", err, auth.ErrUnknownKey)
+	}
+}
+
+func TestParseChecksIssuerAndAudience(t *testing.T) {
+	key := hmacKey(t, "k")
+	signer := func(issuer, audience string) string {
+		m := auth.NewKeyManager(issuer, audience)
+		m.Add(key)
+		m.SetSigningKey("k")
+		token, _ := m.Sign(newClaims())
+		return token
+	}
+	m := auth.NewKeyManager(auth.DefaultIssuer, auth.DefaultAudience)
+	m.Add(key)
+
+	if _, err := m.Parse(signer(auth.DefaultIssuer, auth.DefaultAudience)); err != nil {
+		t.Errorf("valid token rejected: %v", err)
+	}
+	if _, err := m.Parse(signer("someone-else", auth.DefaultAudience)); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Errorf("wrong issuer: got %v", err)
+	}
+	if _, err := m.Parse(signer(auth.DefaultIssuer, "another-api")); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Errorf("wrong audience: got %v", err)
+	}
+
+	expired := auth.NewClaims(1, "user@example.com", nil, 0, -time.Minute)
+	m.SetSigningKey("k")
+	token, _ := m.Sign(expired)
+	if _, err := m.Parse(token); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("expired token: got %v", err)
+	}
+}
+
+func TestAsymmetricKeysAndJWKS(t *testing.T) {
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+
+	m := auth.NewKeyManager(auth.DefaultIssuer, auth.DefaultAudience)
+	m.Add(hmacKey(t, "hmac"))
+	for id, private := range map[string]interface{}{"rsa": rsaPrivate, "ed": edPrivate} {
+		der, _ := x509.MarshalPKCS8PrivateKey(private)
+		key, err := auth.ParseKeyPEM(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		if err != nil {
+			t.Fatalf("ParseKeyPEM(%s): %v", id, err)
+		}
+		m.Add(key)
+		m.SetSigningKey(id)
+		token, _ := m.Sign(newClaims())
+		if _, err := m.Parse(token); err != nil {
+			t.Errorf("%s token rejected: %v", id, err)
+		}
+	}
+
+	jwks := m.JWKS()
+	if len(jwks) != 2 || jwks[0].KeyID != "ed" || jwks[0].KeyType != "OKP" || jwks[1].KeyID != "rsa" || jwks[1].Alg != "RS256" {
+		t.Errorf("unexpected JWKS %+v", jwks)
+	}
+}
+
+func TestParseRejectsAlgorithmConfusion(t *testing.T) {
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&rsaPrivate.PublicKey)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	key, err := auth.ParseKeyPEM("rsa", publicPEM)
+	if err != nil {
+		t.Fatalf("ParseKeyPEM: %v", err)
+	}
+	m := auth.NewKeyManager(auth.DefaultIssuer, auth.DefaultAudience)
+	m.Add(key)
+	if err := m.SetSigningKey("rsa"); err == nil {
+		t.Error("expected a public key to be unusable for signing")
+	}
+
+	// An HS256 token "signed" with the published RSA key must not verify
+	claims := newClaims()
+	claims.Issuer = auth.DefaultIssuer
+	claims.Audience = jwt.ClaimStrings{auth.DefaultAudience}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "rsa"
+	token, _ := forged.SignedString(publicPEM)
+	if _, err := m.Parse(token); err == nil {
+		t.Error("expected an HS256 token for an RSA key to be rejected")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2024.secret"), []byte(strings.Repeat("a", 32)+"\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "2025.secret"), []byte(strings.Repeat("b", 32)), 0600)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0600)
+
+	m := auth.NewKeyManager(auth.DefaultIssuer, auth.DefaultAudience)
+	if err := auth.LoadKeys(m, dir, "2025"); err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	token, _ := m.Sign(newClaims())
+	if !strings.Contains(token, ".") {
+		t.Fatalf("unexpected token %q", token)
+	}
+	if err := auth.LoadKeys(auth.NewKeyManager("", ""), dir, "2026"); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("missing signing key: got %v want %v", err, auth.ErrUnknownKey)
+	}
+
+	os.WriteFile(filepath.Join(dir, "short.secret"), []byte("too short"), 0600)
+	if err := auth.LoadKeys(auth.NewKeyManager("", ""), dir, "2025"); err == nil {
+		t.Error("expected a short HMAC secret to be rejected")
+	}
+}
+
+func TestNew(t *testing.T) {
+	cfg := config.Default().Auth
+	cfg.MFARequiredRoles = []string{"admin", "captain"}
+	if _, err := auth.New(cfg, nil); err == nil || !strings.Contains(err.Error(), "captain") {
+		t.Errorf("expected an error for the unknown role, got %v", err)
+	}
+
+	cfg = config.Default().Auth
+	cfg.Issuer = "tickets.example.com"
+	cfg.MFARequiredRoles = []string{"admin"}
+	a, err := auth.New(cfg, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if a.Keys.Issuer != "tickets.example.com" || a.MFARequired([]string{"operator"}) {
+		t.Errorf("settings not applied: issuer %q, roles %v", a.Keys.Issuer, a.MFARequiredRoles)
+	}
+}
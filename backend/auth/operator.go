@@ -0,0 +1,89 @@
+package auth
+
+import (
+    "context"
+    "database/sql"
+    "log/slog"
+    "net/http"
+    "strconv"
+
+    "ticket-booking-app/backend/apierror"
+
+    "github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const operatorIDKey contextKey = "operatorID"
+
+// OperatorMiddleware restricts a route to staff accounts attached to an
+// operator. It must run after Middleware. Any {tripID} or {busID} route
+// variable is resolved and rejected with 403 unless it belongs to the
+// caller's operator, so handlers behind it only ever see their own
+// operator's resources.
+func (a *Auth) OperatorMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        claims := ClaimsFromContext(r.Context())
+        if claims == nil {
+            apierror.Write(w, r, apierror.ErrUnauthenticated)
+            return
+        }
+        if claims.OperatorID == 0 {
+            apierror.Write(w, r, apierror.ErrOperatorRequired)
+            return
+        }
+        operatorID := claims.OperatorID
+
+        vars := mux.Vars(r)
+        if raw, ok := vars["tripID"]; ok {
+            if !checkOwner(w, r, raw, operatorID, func(id int) (int, error) {
+                trip, err := a.Store.GetTripByID(r.Context(), id)
+                return trip.OperatorID, err
+            }) {
+                return
+            }
+        }
+        if raw, ok := vars["busID"]; ok {
+            if !checkOwner(w, r, raw, operatorID, func(id int) (int, error) {
+                bus, err := a.Store.GetBusByID(r.Context(), id)
+                return bus.OperatorID, err
+            }) {
+                return
+            }
+        }
+
+        ctx := context.WithValue(r.Context(), operatorIDKey, operatorID)
+        next.ServeHTTP(w, r.WithContext(ctx))
+    })
+}
+
+// checkOwner writes an error response and returns false unless the resource
+// identified by raw exists and is owned by operatorID.
+func checkOwner(w http.ResponseWriter, r *http.Request, raw string, operatorID int, owner func(id int) (int, error)) bool {
+    id, err := strconv.Atoi(raw)
+    if err != nil {
+        apierror.Write(w, r, apierror.ErrInvalidID)
+        return false
+    }
+    ownerID, err := owner(id)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            apierror.Write(w, r, apierror.ErrNotFound)
+        } else {
+            slog.ErrorContext(r.Context(), "Error checking owner", "id", id, "error", err)
+            apierror.Write(w, r, apierror.ErrInternal)
+        }
+        return false
+    }
+    if ownerID != operatorID {
+        apierror.Write(w, r, apierror.ErrOtherOperator)
+        return false
+    }
+    return true
+}
+
+// OperatorID returns the operator resolved by OperatorMiddleware.
+func OperatorID(ctx context.Context) int {
+    id, _ := ctx.Value(operatorIDKey).(int)
+    return id
+}
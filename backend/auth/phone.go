@@ -0,0 +1,46 @@
+package auth
+
+import (
+    "errors"
+    "strings"
+)
+
+var ErrInvalidPhone = errors.New("invalid Ethiopian mobile number")
+
+// NormalizePhone converts an Ethiopian mobile number in any of the common
+// forms (+251 9.., 251 9.., 00251 9.., 09.., 9..) to +251XXXXXXXXX. Spaces,
+// dashes, dots and parentheses are ignored. Mobile numbers start with 9
+// (Ethio Telecom) or 7 (Safaricom) after the country code.
+func NormalizePhone(raw string) (string, error) {
+    digits := strings.Map(func(r rune) rune {
+        switch r {
+        case ' ', '-', '.', '(', ')':
+            return -1
+        }
+        return r
+    }, raw)
+
+    var local string
+    switch {
+    case strings.HasPrefix(digits, "+251"):
+        local = digits[4:]
+    case strings.HasPrefix(digits, "00251"):
+        local = digits[5:]
+    case strings.HasPrefix(digits, "251") && len(digits) == 12:
+        local = digits[3:]
+    case strings.HasPrefix(digits, "0") && len(digits) == 10:
+        local = digits[1:]
+    default:
+        local = digits
+    }
+
+    if len(local) != 9 || (local[0] != '9' && local[0] != '7') {
+        return "", ErrInvalidPhone
+    }
+    for _, r := range local {
+        if r < '0' || r > '9' {
+            return "", ErrInvalidPhone
+        }
+    }
+    return "+251" + local, nil
+}
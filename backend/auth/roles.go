@@ -0,0 +1,104 @@
+package auth
+
+import (
+    "net/http"
+    "sort"
+
+    "ticket-booking-app/backend/apierror"
+)
+
+type Role string
+
+const (
+    RoleAdmin     Role = "admin"
+    RoleOperator  Role = "operator"
+    RoleConductor Role = "conductor"
+    RoleCustomer  Role = "customer"
+)
+
+type Permission string
+
+const (
+    PermBookTrips        Permission = "bookings:create"
+    PermViewTripBookings Permission = "bookings:view-trip"
+    PermUpdateTripStatus Permission = "trips:update-status"
+    PermManageTrips      Permission = "trips:manage"
+    PermManageBuses      Permission = "buses:manage"
+    PermManageUsers      Permission = "users:manage"
+    PermManageRoles      Permission = "roles:manage"
+)
+
+// rolePermissions is the source of truth for what each role may do. Admins
+// are granted every permission explicitly so that new permissions have to be
+// added here on purpose.
+var rolePermissions = map[Role][]Permission{
+    RoleAdmin: {
+        PermBookTrips, PermViewTripBookings, PermUpdateTripStatus, PermManageTrips,
+        PermManageBuses, PermManageUsers, PermManageRoles,
+    },
+    RoleOperator:  {PermViewTripBookings, PermUpdateTripStatus, PermManageTrips, PermManageBuses},
+    RoleConductor: {PermViewTripBookings, PermUpdateTripStatus},
+    RoleCustomer:  {PermBookTrips},
+}
+
+// ValidRole reports whether name is a role known to the system.
+func ValidRole(name string) bool {
+    _, ok := rolePermissions[Role(name)]
+    return ok
+}
+
+// PermissionsFor returns the sorted union of the permissions granted by roles.
+func PermissionsFor(roles []string) []string {
+    set := map[string]bool{}
+    for _, role := range roles {
+        for _, perm := range rolePermissions[Role(role)] {
+            set[string(perm)] = true
+        }
+    }
+    perms := make([]string, 0, len(set))
+    for perm := range set {
+        perms = append(perms, perm)
+    }
+    sort.Strings(perms)
+    return perms
+}
+
+// RequireRole only lets requests through whose token carries at least one of
+// roles. It must run after Middleware.
+func RequireRole(roles ...Role) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            claims := ClaimsFromContext(r.Context())
+            if claims == nil {
+                apierror.Write(w, r, apierror.ErrUnauthenticated)
+                return
+            }
+            for _, role := range roles {
+                if claims.HasRole(role) {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+            }
+            apierror.Write(w, r, apierror.ErrForbidden)
+        })
+    }
+}
+
+// RequirePermission only lets requests through whose token grants perm. It
+// must run after Middleware.
+func RequirePermission(perm Permission) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            claims := ClaimsFromContext(r.Context())
+            if claims == nil {
+                apierror.Write(w, r, apierror.ErrUnauthenticated)
+                return
+            }
+            if !claims.HasPermission(perm) {
+                apierror.Write(w, r, apierror.ErrForbidden)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+
+    "ticket-booking-app/backend/models"
+)
+
+// Store is the data the middleware checks requests against.
+// database.Store implements it.
+type Store interface {
+    IsSessionActive(ctx context.Context, sessionID int) (bool, error)
+    GetTripByID(ctx context.Context, id int) (models.Trip, error)
+    GetBusByID(ctx context.Context, id int) (models.Bus, error)
+}
+
+// NewToken returns a random opaque token, as used for refresh tokens and
+// emailed links, and the hash under which it is stored. Only the hash is
+// kept server side.
+func NewToken() (token, hash string, err error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", err
+    }
+    token = base64.RawURLEncoding.EncodeToString(b)
+    return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of a token from NewToken. The tokens have
+// enough entropy that a fast unsalted hash is sufficient.
+func HashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
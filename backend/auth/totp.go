@@ -0,0 +1,145 @@
+package auth
+
+import (
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/subtle"
+    "encoding/base32"
+    "encoding/binary"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// TOTP parameters (RFC 6238). These are the defaults every authenticator
+// app supports.
+const (
+    totpPeriod = 30
+    totpDigits = 6
+    // totpSkew is how many periods either side of the current one are
+    // accepted, to allow for clock drift.
+    totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random 160-bit secret in base32, the form
+// authenticator apps expect.
+func NewTOTPSecret() (string, error) {
+    b := make([]byte, 20)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI returns the otpauth:// URI that authenticator apps read from a QR
+// code.
+func TOTPURI(issuer, account, secret string) string {
+    v := url.Values{}
+    v.Set("secret", secret)
+    v.Set("issuer", issuer)
+    v.Set("algorithm", "SHA1")
+    v.Set("digits", fmt.Sprint(totpDigits))
+    v.Set("period", fmt.Sprint(totpPeriod))
+    label := url.PathEscape(issuer + ":" + account)
+    return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// TOTPStep returns the time step t falls in.
+func TOTPStep(t time.Time) int64 {
+    return t.Unix() / totpPeriod
+}
+
+// TOTPCode returns the code for a time step.
+func TOTPCode(secret string, step int64) (string, error) {
+    key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+    if err != nil {
+        return "", err
+    }
+    var msg [8]byte
+    binary.BigEndian.PutUint64(msg[:], uint64(step))
+    mac := hmac.New(sha1.New, key)
+    mac.Write(msg[:])
+    sum := mac.Sum(nil)
+
+    offset := sum[len(sum)-1] & 0x0f
+    value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+    mod := uint32(1)
+    for i := 0; i < totpDigits; i++ {
+        mod *= 10
+    }
+    return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// VerifyTOTP checks code against the steps around t and returns the step it
+// matched. Steps at or before lastStep are rejected so that a code cannot be
+// replayed.
+func VerifyTOTP(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+    code = strings.ReplaceAll(code, " ", "")
+    current := TOTPStep(t)
+    for step := current - totpSkew; step <= current+totpSkew; step++ {
+        if step <= lastStep {
+            continue
+        }
+        want, err := TOTPCode(secret, step)
+        if err != nil {
+            return 0, false
+        }
+        if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+            return step, true
+        }
+    }
+    return 0, false
+}
+
+// MFARequired reports whether any of roles requires two-factor
+// authentication.
+func (a *Auth) MFARequired(roles []string) bool {
+    for _, role := range roles {
+        if a.MFARequiredRoles[Role(role)] {
+            return true
+        }
+    }
+    return false
+}
+
+// WithoutMFARoles returns roles minus those that require two-factor
+// authentication.
+func (a *Auth) WithoutMFARoles(roles []string) []string {
+    kept := []string{}
+    for _, role := range roles {
+        if !a.MFARequiredRoles[Role(role)] {
+            kept = append(kept, role)
+        }
+    }
+    return kept
+}
+
+// NewRecoveryCodes returns n single-use recovery codes formatted as
+// xxxx-xxxx-xxxx-xxxx. They carry 80 bits of entropy, so HashToken is a
+// suitable way to store them.
+func NewRecoveryCodes(n int) ([]string, error) {
+    codes := make([]string, n)
+    for i := range codes {
+        b := make([]byte, 10)
+        if _, err := rand.Read(b); err != nil {
+            return nil, err
+        }
+        s := strings.ToLower(totpEncoding.EncodeToString(b))
+        codes[i] = s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16]
+    }
+    return codes, nil
+}
+
+// NormalizeRecoveryCode undoes the formatting users tend to change when
+// typing a recovery code back in.
+func NormalizeRecoveryCode(code string) string {
+    code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+    if len(code) != 16 {
+        return code
+    }
+    return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
@@ -0,0 +1,134 @@
+// Package availability streams seat availability. Handlers publish an Event
+// when seats of a trip are booked, held back or released, and the Hub hands
+// it to the clients watching that trip. With a PostgresRelay the events
+// reach the clients of every server process.
+package availability
+
+import (
+	"context"
+	"sync"
+)
+
+// Event types.
+const (
+	// Snapshot lists every free seat of the trip. Streams start with one.
+	Snapshot = "snapshot"
+	Booked   = "booked"
+	// Held seats were taken out of sale without a booking, e.g. by an
+	// administrator.
+	Held     = "held"
+	Released = "released"
+)
+
+// Event is a change to the free seats of a trip.
+type Event struct {
+	Type   string   `json:"type"`
+	TripID int      `json:"tripId"`
+	Seats  []string `json:"seats"`
+	// SeatsAvailable is the number of free seats after the change.
+	SeatsAvailable int `json:"seatsAvailable"`
+}
+
+// Relay carries events between the hubs of the server processes.
+type Relay interface {
+	// Publish sends e to every process, whose hub then delivers it.
+	Publish(ctx context.Context, e Event) error
+}
+
+// bufferSize is how many events a subscriber may fall behind before it is
+// dropped.
+const bufferSize = 32
+
+// Hub delivers events to the subscribers of their trip in this process. A
+// nil Hub drops events.
+type Hub struct {
+	// Relay, if set, carries published events to every process. Otherwise
+	// they are delivered in this process only.
+	Relay Relay
+
+	mu     sync.Mutex
+	subs   map[int]map[chan Event]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[int]map[chan Event]struct{}{}}
+}
+
+// Publish sends e to the subscribers of its trip.
+func (h *Hub) Publish(ctx context.Context, e Event) error {
+	if h == nil {
+		return nil
+	}
+	if h.Relay != nil {
+		return h.Relay.Publish(ctx, e)
+	}
+	h.Deliver(e)
+	return nil
+}
+
+// Deliver hands e to the subscribers of its trip in this process. A
+// subscriber that has fallen too far behind is dropped, so that its client
+// reconnects and starts over from a snapshot rather than miss events.
+func (h *Hub) Deliver(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[e.TripID] {
+		select {
+		case ch <- e:
+		default:
+			h.drop(e.TripID, ch)
+		}
+	}
+}
+
+// Subscribe returns the events of trip and a function to stop receiving
+// them. The channel is closed when the subscriber is dropped.
+func (h *Hub) Subscribe(tripID int) (<-chan Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan Event, bufferSize)
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subs[tripID] == nil {
+		h.subs[tripID] = map[chan Event]struct{}{}
+	}
+	h.subs[tripID][ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[tripID][ch]; ok {
+			h.drop(tripID, ch)
+		}
+	}
+}
+
+// DropAll drops every subscriber, e.g. after events may have been missed.
+func (h *Hub) DropAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for tripID, subs := range h.subs {
+		for ch := range subs {
+			h.drop(tripID, ch)
+		}
+	}
+}
+
+// Close drops every subscriber and refuses new ones, so that event streams
+// end when the server shuts down.
+func (h *Hub) Close() {
+	h.DropAll()
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+}
+
+func (h *Hub) drop(tripID int, ch chan Event) {
+	delete(h.subs[tripID], ch)
+	if len(h.subs[tripID]) == 0 {
+		delete(h.subs, tripID)
+	}
+	close(ch)
+}
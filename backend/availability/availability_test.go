@@ -0,0 +1,57 @@
+package availability
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHubDeliversToTheTripsSubscribers(t *testing.T) {
+	h := NewHub()
+	trip1, stop1 := h.Subscribe(1)
+	defer stop1()
+	trip2, stop2 := h.Subscribe(2)
+	defer stop2()
+
+	h.Publish(context.Background(), Event{Type: Booked, TripID: 1, Seats: []string{"A1"}, SeatsAvailable: 3})
+	select {
+	case e := <-trip1:
+		if e.Type != Booked || e.Seats[0] != "A1" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	default:
+		t.Fatal("event not delivered")
+	}
+	select {
+	case e := <-trip2:
+		t.Errorf("other trip received %+v", e)
+	default:
+	}
+
+	var disabled *Hub
+	if err := disabled.Publish(context.Background(), Event{TripID: 1}); err != nil {
+		t.Errorf("nil Hub: %v", err)
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	h := NewHub()
+	slow, stop := h.Subscribe(1)
+	for i := 0; i <= bufferSize; i++ {
+		h.Deliver(Event{Type: Released, TripID: 1})
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != bufferSize {
+		t.Errorf("received %d events before being dropped, want %d", n, bufferSize)
+	}
+	// Unsubscribing after being dropped is harmless
+	stop()
+
+	h.Close()
+	closed, _ := h.Subscribe(1)
+	if _, ok := <-closed; ok {
+		t.Error("subscribed to a closed hub")
+	}
+}
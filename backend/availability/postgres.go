@@ -0,0 +1,85 @@
+package availability
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// channel is the PostgreSQL notification channel events are sent on.
+const channel = "seat_events"
+
+// PostgresRelay sends events with NOTIFY and delivers those of every
+// process, its own included, to Hub.
+type PostgresRelay struct {
+	DB *sql.DB
+	// DSN connects the listening session, which stays open outside DB's
+	// pool.
+	DSN string
+	Hub *Hub
+}
+
+func NewPostgresRelay(db *sql.DB, dsn string, hub *Hub) *PostgresRelay {
+	return &PostgresRelay{DB: db, DSN: dsn, Hub: hub}
+}
+
+// Publish notifies the listening processes. Payloads are limited to 8000
+// bytes, far more than the seats of a bus take.
+func (r *PostgresRelay) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, string(payload))
+	return err
+}
+
+// Run listens for events and delivers them until ctx is done. After the
+// connection is lost every subscriber is dropped, as events may have been
+// missed in the meantime.
+func (r *PostgresRelay) Run(ctx context.Context) {
+	listener := pq.NewListener(r.DSN, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Seat event listener connection error", "error", err)
+		}
+	})
+	// Closing the listener also ends a Listen still waiting for the database
+	context.AfterFunc(ctx, func() { listener.Close() })
+	if err := listener.Listen(channel); err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Error listening for seat events", "error", err)
+		}
+		return
+	}
+
+	// Pinging detects a dead connection that would otherwise go unnoticed
+	// while no events are sent.
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				r.Hub.DropAll()
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				slog.ErrorContext(ctx, "Invalid seat event", "payload", n.Extra, "error", err)
+				continue
+			}
+			r.Hub.Deliver(e)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
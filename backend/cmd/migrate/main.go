@@ -0,0 +1,106 @@
+// Command migrate manages the database schema.
+//
+//	go run ./cmd/migrate [-config config.yaml] up
+//	go run ./cmd/migrate down [steps]
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate create add_bus_photos
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"ticket-booking-app/backend/config"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/migrations"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: migrate [-config file] up | down [steps] | status | create <name>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	dir := flag.String("dir", "migrations/sql", "directory new migrations are created in")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	// Creating a migration only touches files
+	if flag.Arg(0) == "create" {
+		if flag.NArg() != 2 {
+			usage()
+		}
+		up, down, err := migrations.Create(*dir, flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	m, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, migration := range done {
+			fmt.Printf("Applied %s\n", migration)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			if steps, err = strconv.Atoi(flag.Arg(1)); err != nil || steps < 1 {
+				usage()
+			}
+		}
+		done, err := m.Down(ctx, steps)
+		for _, migration := range done {
+			fmt.Printf("Reverted %s\n", migration)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-40s %s\n", s.Migration, applied)
+		}
+	default:
+		usage()
+	}
+}
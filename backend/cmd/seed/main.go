@@ -3,16 +3,43 @@ import (
     "encoding/json"
     "io/ioutil"
     "log"
+    "os"
+    "strconv"
 
     "github.com/lib/pq"
     _ "github.com/lib/pq"
+    "ticket-booking-app/backend/auth"
+    "ticket-booking-app/backend/config"
     "ticket-booking-app/backend/database"
     "ticket-booking-app/backend/models"
 )
 
+// seedUser is a users.json fixture. Every user is a customer; Roles lists
+// any further roles to grant. Operator names the bus company a staff
+// account works for.
+type seedUser struct {
+    models.User
+    Roles    []string `json:"roles"`
+    Operator string   `json:"operator"`
+}
+
+// seatLabels returns n seat labels in rows of four: A1 to A4, B1 to B4 and
+// so on.
+func seatLabels(n int) []string {
+    labels := make([]string, n)
+    for i := range labels {
+        labels[i] = string(rune('A'+i/4)) + strconv.Itoa(i%4+1)
+    }
+    return labels
+}
+
 func main() {
     // Connect to the database
-    db, err := database.ConnectToPostgres("ticket_booking")
+    cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+    if err != nil {
+        log.Fatal(err)
+    }
+    db, err := database.Open(cfg.Database)
     if err != nil {
         log.Fatal(err)
     }
@@ -27,10 +54,18 @@ func main() {
     if err != nil {
         log.Fatalf("Failed to clear trips table: %v", err)
     }
+    _, err = db.Exec("DELETE FROM buses")
+    if err != nil {
+        log.Fatalf("Failed to clear buses table: %v", err)
+    }
     _, err = db.Exec("DELETE FROM users")
     if err != nil {
         log.Fatalf("Failed to clear users table: %v", err)
     }
+    _, err = db.Exec("DELETE FROM operators")
+    if err != nil {
+        log.Fatalf("Failed to clear operators table: %v", err)
+    }
 
     // Read trips.json
     tripsFile, err := ioutil.ReadFile("../src/data/trips.json")
@@ -52,31 +87,68 @@ func main() {
             continue
         }
 
-        _, err = db.Exec(`INSERT INTO trips ("from", "to", date, departure_time, arrival_time, price, seats_available, bus_operator, duration, amenities, intermediate_stops, reviews) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
-            trip.From, trip.To, trip.Date, trip.DepartureTime, trip.ArrivalTime, trip.Price, trip.SeatsAvailable, trip.BusOperator, trip.Duration, pq.Array(trip.Amenities), pq.Array(trip.IntermediateStops), reviewsJSON)
+        // Bookings pick from the seat labels, so every trip needs them and
+        // the count on sale must match
+        if len(trip.Seats) == 0 {
+            trip.Seats = seatLabels(trip.SeatsAvailable)
+        }
+        trip.SeatsAvailable = len(trip.Seats)
+
+        _, err = db.Exec(`INSERT INTO trips ("from", "to", date, departure_time, arrival_time, price, seats_available, seats, bus_operator, duration, amenities, intermediate_stops, reviews) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+            trip.From, trip.To, trip.Date, trip.DepartureTime, trip.ArrivalTime, trip.Price, trip.SeatsAvailable, pq.Array(trip.Seats), trip.BusOperator, trip.Duration, pq.Array(trip.Amenities), pq.Array(trip.IntermediateStops), reviewsJSON)
         if err != nil {
             log.Printf("Failed to insert trip: %v", err)
         }
     }
 
+    // Create an operator for every bus company and hand it its trips
+    _, err = db.Exec(`INSERT INTO operators (name) SELECT DISTINCT bus_operator FROM trips`)
+    if err != nil {
+        log.Fatalf("Failed to create operators: %v", err)
+    }
+    _, err = db.Exec(`UPDATE trips SET operator_id = operators.id FROM operators WHERE operators.name = trips.bus_operator`)
+    if err != nil {
+        log.Fatalf("Failed to assign trips to operators: %v", err)
+    }
+
     // Read users.json
     usersFile, err := ioutil.ReadFile("../src/data/users.json")
     if err != nil {
         log.Fatalf("Failed to read users.json: %v", err)
     }
 
-    var users []models.User
+    var users []seedUser
     err = json.Unmarshal(usersFile, &users)
     if err != nil {
         log.Fatalf("Failed to unmarshal users.json: %v", err)
     }
 
-    // Insert users into the database
+    // Insert users into the database along with their roles
     for _, user := range users {
-        _, err := db.Exec(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3)`,
-            user.Name, user.Email, user.Password)
+        var id int
+        err := db.QueryRow(`INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id`,
+            user.Name, user.Email, user.Password).Scan(&id)
         if err != nil {
             log.Printf("Failed to insert user: %v", err)
+            continue
+        }
+        if user.Operator != "" {
+            res, err := db.Exec(`UPDATE users SET operator_id = operators.id FROM operators WHERE operators.name = $1 AND users.id = $2`, user.Operator, id)
+            if err != nil {
+                log.Printf("Failed to attach %s to %s: %v", user.Email, user.Operator, err)
+            } else if n, _ := res.RowsAffected(); n == 0 {
+                log.Printf("Skipping unknown operator %q for %s", user.Operator, user.Email)
+            }
+        }
+        for _, role := range append([]string{string(auth.RoleCustomer)}, user.Roles...) {
+            if !auth.ValidRole(role) {
+                log.Printf("Skipping unknown role %q for %s", role, user.Email)
+                continue
+            }
+            _, err := db.Exec(`INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`, id, role)
+            if err != nil {
+                log.Printf("Failed to grant role %q: %v", role, err)
+            }
         }
     }
 
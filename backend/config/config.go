@@ -1,3 +1,544 @@
+// Package config loads the server's settings from an optional YAML file
+// overridden by environment variables. Nothing here is global: main loads a
+// Config once and passes the relevant sections to the packages that need
+// them.
 package config
 
-var JWTKey = []byte("my_secret_key")
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	Auth          AuthConfig          `yaml:"auth"`
+	App           AppConfig           `yaml:"app"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Log           LogConfig           `yaml:"log"`
+	Tracing       TracingConfig       `yaml:"tracing"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Idempotency   IdempotencyConfig   `yaml:"idempotency"`
+}
+
+type ServerConfig struct {
+	// Addr is the address to listen on, e.g. ":8080".
+	Addr        string   `yaml:"addr"`
+	CORSOrigins []string `yaml:"cors_origins"`
+	// ReadTimeout, WriteTimeout and IdleTimeout bound each connection, see
+	// http.Server. Zero means no limit.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once the server is asked to stop.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	// URL, if set, is used as is and the other fields are ignored.
+	URL      string `yaml:"url"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+	// MigrateOnStart applies pending migrations when the server starts.
+	// Otherwise run cmd/migrate before deploying.
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+	// MaxOpenConns and MaxIdleConns size the connection pool, see sql.DB.
+	// Zero means unlimited open and the database/sql default idle
+	// connections.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// ConnectTimeout is how long the server keeps retrying to reach the
+	// database at startup, e.g. while both are starting together. Zero
+	// tries once.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+type AuthConfig struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// KeysDir holds the token signing keys, see auth.LoadKeys. Without it a
+	// temporary key is generated and tokens do not survive a restart.
+	KeysDir    string `yaml:"keys_dir"`
+	SigningKey string `yaml:"signing_key"`
+	// MFARequiredRoles are the roles only granted to sessions that passed
+	// two-factor authentication.
+	MFARequiredRoles []string `yaml:"mfa_required_roles"`
+}
+
+type AppConfig struct {
+	// Name identifies the service to users, e.g. in authenticator apps.
+	Name string `yaml:"name"`
+	// URL is the address of the web app, used to build the links mailed to
+	// users.
+	URL string `yaml:"url"`
+	// UnverifiedBookingLimit is how many active bookings an account may hold
+	// before its email address or phone number has been verified.
+	UnverifiedBookingLimit int `yaml:"unverified_booking_limit"`
+	// WaitlistHold is how long seats freed on a sold-out trip are held for
+	// the next user on its waitlist before moving on.
+	WaitlistHold time.Duration `yaml:"waitlist_hold"`
+}
+
+type LogConfig struct {
+	// Level is the least severe level logged: debug, info, warn or error.
+	// At debug, database statements and request headers are logged too.
+	Level string `yaml:"level"`
+	// Format is json, for log collectors, or text, for reading in a
+	// terminal.
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	// Exporter is where spans go: none, stdout for local debugging without
+	// a collector, or otlp.
+	Exporter string `yaml:"exporter"`
+	// OTLPEndpoint is the collector's OTLP/HTTP URL, e.g.
+	// http://localhost:4318. If empty, the OTEL_EXPORTER_OTLP_* environment
+	// variables apply.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Traces
+	// started by a caller follow the caller's decision.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type RateLimitConfig struct {
+	// Store keeps the rate limit buckets: memory, for a single server
+	// process, or postgres, shared by every process using the database.
+	Store string `yaml:"store"`
+	// AuthPerIP is how many requests one client address may make to the
+	// authentication endpoints per minute. Zero disables the limit.
+	AuthPerIP int `yaml:"auth_per_ip"`
+	// LoginPerAccount is how many login attempts one email address may get
+	// per minute, from any address. Zero disables the limit.
+	LoginPerAccount int `yaml:"login_per_account"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies.
+	// Behind them the client address is taken from X-Forwarded-For.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type IdempotencyConfig struct {
+	// Store keeps the idempotency keys: memory, for a single server
+	// process, or postgres, shared by every process using the database.
+	Store string `yaml:"store"`
+	// TTL is how long a key is remembered after its first use. A retry
+	// after that is handled as a new request.
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type NotificationsConfig struct {
+	// File receives messages for channels without a provider.
+	File string     `yaml:"file"`
+	SMTP SMTPConfig `yaml:"smtp"`
+	SMS  SMSConfig  `yaml:"sms"`
+}
+
+type SMTPConfig struct {
+	Addr     string `yaml:"addr"`
+	From     string `yaml:"from"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type SMSConfig struct {
+	URL    string `yaml:"url"`
+	APIKey string `yaml:"api_key"`
+	Sender string `yaml:"sender"`
+}
+
+// Default returns the settings for local development.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			CORSOrigins:     []string{"*"},
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Name:            "ticket_booking",
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+		},
+		Auth: AuthConfig{
+			Issuer:           "ticket-booking-app",
+			Audience:         "ticket-booking-api",
+			MFARequiredRoles: []string{"admin", "operator"},
+		},
+		App: AppConfig{
+			Name:                   "Bus Ticket Booking",
+			URL:                    "http://localhost:5173",
+			UnverifiedBookingLimit: 1,
+			WaitlistHold:           30 * time.Minute,
+		},
+		Notifications: NotificationsConfig{
+			File: "notifications.log",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Store:           "memory",
+			AuthPerIP:       30,
+			LoginPerAccount: 10,
+		},
+		Idempotency: IdempotencyConfig{
+			Store: "memory",
+			TTL:   24 * time.Hour,
+		},
+	}
+}
+
+// Load returns the defaults, overridden by the YAML file at path if path is
+// not empty, overridden by the environment, and validated.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("config: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil {
+			return cfg, fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	cfg.App.URL = strings.TrimSuffix(cfg.App.URL, "/")
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings from environment variables.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	str := func(name string, dst *string) {
+		if v, ok := lookup(name); ok {
+			*dst = v
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := lookup(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("config: %s: %q is not a number", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	fraction := func(name string, dst *float64) {
+		if v, ok := lookup(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("config: %s: %q is not a number", name, v))
+				return
+			}
+			*dst = f
+		}
+	}
+	flag := func(name string, dst *bool) {
+		if v, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("config: %s: %q is not true or false", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := lookup(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("config: %s: %q is not a duration such as 30s", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := lookup(name); ok {
+			*dst = splitList(v)
+		}
+	}
+
+	str("SERVER_ADDR", &c.Server.Addr)
+	if port, ok := lookup("PORT"); ok {
+		c.Server.Addr = ":" + port
+	}
+	list("CORS_ALLOWED_ORIGINS", &c.Server.CORSOrigins)
+	duration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	duration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	duration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	duration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	str("DATABASE_URL", &c.Database.URL)
+	str("DB_HOST", &c.Database.Host)
+	num("DB_PORT", &c.Database.Port)
+	str("DB_USER", &c.Database.User)
+	str("DB_PASSWORD", &c.Database.Password)
+	str("DB_NAME", &c.Database.Name)
+	str("DB_SSLMODE", &c.Database.SSLMode)
+	flag("DB_MIGRATE_ON_START", &c.Database.MigrateOnStart)
+	num("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	num("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+	duration("DB_CONNECT_TIMEOUT", &c.Database.ConnectTimeout)
+
+	str("JWT_ISSUER", &c.Auth.Issuer)
+	str("JWT_AUDIENCE", &c.Auth.Audience)
+	str("JWT_KEYS_DIR", &c.Auth.KeysDir)
+	str("JWT_SIGNING_KEY", &c.Auth.SigningKey)
+	// "none" requires two-factor authentication for no role
+	list("MFA_REQUIRED_ROLES", &c.Auth.MFARequiredRoles)
+	if len(c.Auth.MFARequiredRoles) == 1 && c.Auth.MFARequiredRoles[0] == "none" {
+		c.Auth.MFARequiredRoles = []string{}
+	}
+
+	str("APP_NAME", &c.App.Name)
+	str("APP_URL", &c.App.URL)
+	num("UNVERIFIED_BOOKING_LIMIT", &c.App.UnverifiedBookingLimit)
+	duration("WAITLIST_HOLD", &c.App.WaitlistHold)
+
+	str("NOTIFICATIONS_FILE", &c.Notifications.File)
+	str("SMTP_ADDR", &c.Notifications.SMTP.Addr)
+	str("SMTP_FROM", &c.Notifications.SMTP.From)
+	str("SMTP_USERNAME", &c.Notifications.SMTP.Username)
+	str("SMTP_PASSWORD", &c.Notifications.SMTP.Password)
+	str("SMS_API_URL", &c.Notifications.SMS.URL)
+	str("SMS_API_KEY", &c.Notifications.SMS.APIKey)
+	str("SMS_SENDER", &c.Notifications.SMS.Sender)
+
+	str("LOG_LEVEL", &c.Log.Level)
+	str("LOG_FORMAT", &c.Log.Format)
+
+	str("TRACING_EXPORTER", &c.Tracing.Exporter)
+	str("TRACING_OTLP_ENDPOINT", &c.Tracing.OTLPEndpoint)
+	fraction("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+
+	str("RATE_LIMIT_STORE", &c.RateLimit.Store)
+	num("RATE_LIMIT_AUTH_PER_IP", &c.RateLimit.AuthPerIP)
+	num("RATE_LIMIT_LOGIN_PER_ACCOUNT", &c.RateLimit.LoginPerAccount)
+	list("TRUSTED_PROXIES", &c.RateLimit.TrustedProxies)
+
+	str("IDEMPOTENCY_STORE", &c.Idempotency.Store)
+	duration("IDEMPOTENCY_TTL", &c.Idempotency.TTL)
+
+	return errors.Join(errs...)
+}
+
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+var sslModes = map[string]bool{"disable": true, "require": true, "verify-ca": true, "verify-full": true}
+
+// Validate reports every invalid setting at once, named by its path in the
+// config file.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("config: %s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Addr == "" {
+		invalid("server.addr", "is required")
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		invalid("server.cors_origins", "needs at least one origin")
+	}
+	nonNegative := func(field string, d time.Duration) {
+		if d < 0 {
+			invalid(field, "must not be negative")
+		}
+	}
+	nonNegative("server.read_timeout", c.Server.ReadTimeout)
+	nonNegative("server.write_timeout", c.Server.WriteTimeout)
+	nonNegative("server.idle_timeout", c.Server.IdleTimeout)
+	nonNegative("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	if c.Database.URL == "" {
+		if c.Database.Name == "" {
+			invalid("database.name", "is required")
+		}
+		if c.Database.Port < 0 || c.Database.Port > 65535 {
+			invalid("database.port", "%d is not a valid port", c.Database.Port)
+		}
+		if c.Database.SSLMode != "" && !sslModes[c.Database.SSLMode] {
+			invalid("database.sslmode", "%q is not one of disable, require, verify-ca, verify-full", c.Database.SSLMode)
+		}
+	}
+	if c.Database.MaxOpenConns < 0 {
+		invalid("database.max_open_conns", "must not be negative")
+	}
+	if c.Database.MaxIdleConns < 0 {
+		invalid("database.max_idle_conns", "must not be negative")
+	}
+	nonNegative("database.conn_max_lifetime", c.Database.ConnMaxLifetime)
+	nonNegative("database.conn_max_idle_time", c.Database.ConnMaxIdleTime)
+	nonNegative("database.connect_timeout", c.Database.ConnectTimeout)
+
+	if c.Auth.Issuer == "" {
+		invalid("auth.issuer", "is required")
+	}
+	if c.Auth.Audience == "" {
+		invalid("auth.audience", "is required")
+	}
+	if c.Auth.KeysDir != "" && c.Auth.SigningKey == "" {
+		invalid("auth.signing_key", "is required when auth.keys_dir is set")
+	}
+
+	if u, err := url.Parse(c.App.URL); err != nil || u.Scheme == "" || u.Host == "" {
+		invalid("app.url", "%q is not an absolute URL", c.App.URL)
+	}
+	if c.App.UnverifiedBookingLimit < 0 {
+		invalid("app.unverified_booking_limit", "must not be negative")
+	}
+	if c.App.WaitlistHold <= 0 {
+		invalid("app.waitlist_hold", "must be positive")
+	}
+
+	if c.Notifications.SMTP.Addr != "" && c.Notifications.SMTP.From == "" {
+		invalid("notifications.smtp.from", "is required when notifications.smtp.addr is set")
+	}
+	if c.Notifications.SMS.URL != "" {
+		if u, err := url.Parse(c.Notifications.SMS.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("notifications.sms.url", "%q is not an absolute URL", c.Notifications.SMS.URL)
+		}
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level", "%q is not one of debug, info, warn, error", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		invalid("log.format", "%q is not json or text", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		invalid("tracing.exporter", "%q is not one of none, stdout, otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("tracing.otlp_endpoint", "%q is not an absolute URL", c.Tracing.OTLPEndpoint)
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "postgres" {
+		invalid("rate_limit.store", "%q is not memory or postgres", c.RateLimit.Store)
+	}
+	if c.RateLimit.AuthPerIP < 0 {
+		invalid("rate_limit.auth_per_ip", "must not be negative")
+	}
+	if c.RateLimit.LoginPerAccount < 0 {
+		invalid("rate_limit.login_per_account", "must not be negative")
+	}
+	for _, proxy := range c.RateLimit.TrustedProxies {
+		if _, err := ParsePrefix(proxy); err != nil {
+			invalid("rate_limit.trusted_proxies", "%q is not an address or CIDR range", proxy)
+		}
+	}
+
+	if c.Idempotency.Store != "memory" && c.Idempotency.Store != "postgres" {
+		invalid("idempotency.store", "%q is not memory or postgres", c.Idempotency.Store)
+	}
+	if c.Idempotency.TTL <= 0 {
+		invalid("idempotency.ttl", "must be positive")
+	}
+	return errors.Join(errs...)
+}
+
+// ParsePrefix parses an address, such as 10.0.0.1, or a CIDR range, such as
+// 10.0.0.0/8. An address is a range of one.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// DSN returns the lib/pq connection string. Unset fields are left out so
+// that lib/pq's defaults and PG* environment variables apply.
+func (d DatabaseConfig) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	var parts []string
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+		parts = append(parts, fmt.Sprintf("%s='%s'", key, value))
+	}
+	add("host", d.Host)
+	if d.Port != 0 {
+		add("port", strconv.Itoa(d.Port))
+	}
+	add("user", d.User)
+	add("password", d.Password)
+	add("dbname", d.Name)
+	add("sslmode", d.SSLMode)
+	return strings.Join(parts, " ")
+}
+
+// WithName returns a copy of d connecting to another database on the same
+// server.
+func (d DatabaseConfig) WithName(name string) DatabaseConfig {
+	if d.URL != "" {
+		if u, err := url.Parse(d.URL); err == nil {
+			u.Path = "/" + name
+			d.URL = u.String()
+		}
+	}
+	d.Name = name
+	return d
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileThenEnvironment(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  addr: ":9000"
+database:
+  host: db.internal
+  user: booking
+  name: from_file
+app:
+  url: https://tickets.example.com/
+`)
+	t.Setenv("DB_NAME", "from_env")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://tickets.example.com, https://admin.example.com")
+	t.Setenv("MFA_REQUIRED_ROLES", "none")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Addr != ":9000" || cfg.Database.Host != "db.internal" || cfg.Database.Name != "from_env" {
+		t.Errorf("file and environment not applied: %+v", cfg)
+	}
+	if want := []string{"https://tickets.example.com", "https://admin.example.com"}; !reflect.DeepEqual(cfg.Server.CORSOrigins, want) {
+		t.Errorf("CORS origins: got %v want %v", cfg.Server.CORSOrigins, want)
+	}
+	if len(cfg.Auth.MFARequiredRoles) != 0 {
+		t.Errorf("expected no MFA roles, got %v", cfg.Auth.MFARequiredRoles)
+	}
+	if cfg.App.URL != "https://tickets.example.com" || cfg.App.UnverifiedBookingLimit != 1 {
+		t.Errorf("app settings: %+v", cfg.App)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, "server:\n  adr: \":9000\"\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "adr") {
+		t.Errorf("expected an error naming the unknown field, got %v", err)
+	}
+}
+
+func TestLoadDurations(t *testing.T) {
+	path := writeConfig(t, "server:\n  write_timeout: 1m\ndatabase:\n  conn_max_lifetime: 90s\n")
+	t.Setenv("SERVER_SHUTDOWN_TIMEOUT", "5s")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.WriteTimeout != time.Minute || cfg.Database.ConnMaxLifetime != 90*time.Second || cfg.Server.ShutdownTimeout != 5*time.Second {
+		t.Errorf("durations not applied: %+v %+v", cfg.Server, cfg.Database)
+	}
+
+	t.Setenv("DB_CONNECT_TIMEOUT", "30")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "DB_CONNECT_TIMEOUT") {
+		t.Errorf("expected an error for DB_CONNECT_TIMEOUT, got %v", err)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	t.Setenv("DB_PORT", "five")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "DB_PORT") {
+		t.Errorf("expected an error for DB_PORT, got %v", err)
+	}
+
+	cfg := Default()
+	cfg.Database.SSLMode = "prefer"
+	cfg.Auth.KeysDir = "/etc/keys"
+	cfg.App.URL = "tickets.example.com"
+	cfg.Server.IdleTimeout = -time.Second
+	cfg.Tracing.SampleRatio = 1.5
+	cfg.RateLimit.TrustedProxies = []string{"10.0.0.0/8", "proxy.internal"}
+	cfg.Idempotency.TTL = 0
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, field := range []string{"server.idle_timeout", "database.sslmode", "auth.signing_key", "app.url", "tracing.sample_ratio", "rate_limit.trusted_proxies", "idempotency.ttl"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected an error for %s in %v", field, err)
+		}
+	}
+}
+
+func TestDSN(t *testing.T) {
+	d := DatabaseConfig{Host: "db", Port: 5433, User: "app", Password: "it's secret", Name: "tickets", SSLMode: "require"}
+	want := `host='db' port='5433' user='app' password='it\'s secret' dbname='tickets' sslmode='require'`
+	if got := d.DSN(); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+	if got := (DatabaseConfig{Name: "tickets"}).DSN(); got != "dbname='tickets'" {
+		t.Errorf("unset fields should be left to lib/pq defaults, got %s", got)
+	}
+
+	url := DatabaseConfig{URL: "postgres://app@db:5432/tickets?sslmode=require"}.WithName("postgres")
+	if url.DSN() != "postgres://app@db:5432/postgres?sslmode=require" {
+		t.Errorf("WithName: got %s", url.DSN())
+	}
+}
@@ -0,0 +1,312 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"ticket-booking-app/backend/models"
+)
+
+var (
+	ErrBookingAlreadyCancelled = errors.New("booking already cancelled")
+	// ErrTripHasBookings is returned when deleting a trip that bookings
+	// refer to.
+	ErrTripHasBookings = errors.New("trip has bookings")
+)
+
+// whereClause accumulates optional filter conditions. Every "?" in a
+// condition refers to that condition's single argument and is numbered when
+// the condition is added.
+type whereClause struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *whereClause) add(cond string, arg interface{}) {
+	w.args = append(w.args, arg)
+	w.conds = append(w.conds, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(w.args))))
+}
+
+func (w *whereClause) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// limitOffset appends LIMIT/OFFSET placeholders after the filter arguments.
+func (w *whereClause) limitOffset(limit, offset int) (string, []interface{}) {
+	n := len(w.args)
+	args := append(append([]interface{}{}, w.args...), limit, offset)
+	return fmt.Sprintf(" LIMIT $%d OFFSET $%d", n+1, n+2), args
+}
+
+type TripFilter struct {
+	From       string
+	To         string
+	Date       string
+	OperatorID int
+}
+
+func (s *PostgresStore) ListTrips(ctx context.Context, filter TripFilter, limit, offset int) ([]models.Trip, int, error) {
+	var where whereClause
+	if filter.From != "" {
+		where.add(`LOWER("from") = LOWER(?)`, filter.From)
+	}
+	if filter.To != "" {
+		where.add(`LOWER("to") = LOWER(?)`, filter.To)
+	}
+	if filter.Date != "" {
+		where.add(`date = ?::date`, filter.Date)
+	}
+	if filter.OperatorID != 0 {
+		where.add(`operator_id = ?`, filter.OperatorID)
+	}
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM trips"+where.String(), where.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, args := where.limitOffset(limit, offset)
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+tripColumns+" FROM trips"+where.String()+" ORDER BY date DESC, departure_time, id"+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	trips := []models.Trip{}
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		trips = append(trips, trip)
+	}
+	return trips, total, rows.Err()
+}
+
+// DeleteTrip fails with a foreign key violation if the trip has bookings.
+func (s *PostgresStore) DeleteTrip(ctx context.Context, id int) error {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM trips WHERE id = $1", id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrTripHasBookings
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgresStore) ListUsers(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	var where whereClause
+	if query != "" {
+		where.add(`(name ILIKE ? OR email ILIKE ? OR phone ILIKE ?)`, "%"+query+"%")
+	}
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where.String(), where.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, args := where.limitOffset(limit, offset)
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, name, COALESCE(email, ''), COALESCE(operator_id, 0), email_verified, COALESCE(phone, '') FROM users"+where.String()+" ORDER BY id"+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.OperatorID, &user.EmailVerified, &user.Phone); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+type BookingFilter struct {
+	UserID int
+	TripID int
+	Status string
+}
+
+func (s *PostgresStore) ListBookings(ctx context.Context, filter BookingFilter, limit, offset int) ([]models.Booking, int, error) {
+	var where whereClause
+	if filter.UserID != 0 {
+		where.add(`user_id = ?`, filter.UserID)
+	}
+	if filter.TripID != 0 {
+		where.add(`trip_id = ?`, filter.TripID)
+	}
+	if filter.Status != "" {
+		where.add(`status = ?`, filter.Status)
+	}
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM bookings"+where.String(), where.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, args := where.limitOffset(limit, offset)
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+bookingColumns+" FROM bookings"+where.String()+" ORDER BY id DESC"+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		bookings = append(bookings, booking)
+	}
+	return bookings, total, rows.Err()
+}
+
+func (s *PostgresStore) GetBookingByID(ctx context.Context, id int) (models.Booking, error) {
+	return scanBooking(s.DB.QueryRowContext(ctx, "SELECT "+bookingColumns+" FROM bookings WHERE id = $1", id))
+}
+
+// CancelBooking marks a booking cancelled and returns its seats to the trip's
+// inventory in one transaction.
+func (s *PostgresStore) CancelBooking(ctx context.Context, id int) (models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	defer tx.Rollback()
+
+	booking, err := scanBooking(tx.QueryRowContext(ctx, "SELECT "+bookingColumns+" FROM bookings WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		return booking, err
+	}
+	if booking.Status == models.BookingCancelled {
+		return booking, ErrBookingAlreadyCancelled
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE bookings SET status = $1, cancelled_at = NOW() WHERE id = $2", models.BookingCancelled, id)
+	if err != nil {
+		return booking, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE trips SET seats = array_cat(seats, $1), seats_available = seats_available + $2 WHERE id = $3",
+		pq.Array(booking.Seats), len(booking.Seats), booking.TripID)
+	if err != nil {
+		return booking, err
+	}
+
+	booking.Status = models.BookingCancelled
+	return booking, tx.Commit()
+}
+
+// AdjustTripSeats replaces the seats on sale for a trip and returns those
+// on sale before. The trip is locked meanwhile, so no booking slips in
+// between. Seats in a booking that is not cancelled or held by a waitlist
+// offer cannot be put on sale; they are reported in a *SeatsTakenError.
+func (s *PostgresStore) AdjustTripSeats(ctx context.Context, tripID int, seats []string) ([]string, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before pq.StringArray
+	if err := tx.QueryRowContext(ctx, "SELECT seats FROM trips WHERE id = $1 FOR UPDATE", tripID).Scan(&before); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT seat FROM (
+			SELECT unnest(seats) AS seat FROM bookings WHERE trip_id = $1 AND status <> $2
+			UNION ALL
+			SELECT unnest(offered_seats) FROM waitlist_entries WHERE trip_id = $1 AND status = $3
+		) AS held
+		WHERE seat = ANY($4)
+		ORDER BY seat`, tripID, models.BookingCancelled, models.WaitlistOffered, pq.Array(seats))
+	if err != nil {
+		return nil, err
+	}
+	var taken []string
+	for rows.Next() {
+		var seat string
+		if err := rows.Scan(&seat); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		taken = append(taken, seat)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(taken) > 0 {
+		return nil, &SeatsTakenError{Seats: taken}
+	}
+
+	// Seats held by waitlist offers are already off the count, so it moves
+	// by the change in seats on sale
+	_, err = tx.ExecContext(ctx, "UPDATE trips SET seats = $1, seats_available = seats_available + $2 - COALESCE(cardinality(seats), 0) WHERE id = $3",
+		pq.Array(seats), len(seats), tripID)
+	if err != nil {
+		return nil, err
+	}
+	return []string(before), tx.Commit()
+}
+
+// RecordAudit appends an entry to the audit trail. details is stored as JSON.
+func (s *PostgresStore) RecordAudit(ctx context.Context, actorUserID int, action, entity string, entityID int, details interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, "INSERT INTO audit_log (actor_user_id, action, entity, entity_id, details) VALUES ($1, $2, $3, $4, $5)",
+		nullInt(actorUserID), action, entity, nullInt(entityID), detailsJSON)
+	return err
+}
+
+func (s *PostgresStore) ListAudit(ctx context.Context, entity string, limit, offset int) ([]models.AuditEntry, int, error) {
+	var where whereClause
+	if entity != "" {
+		where.add(`entity = ?`, entity)
+	}
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where.String(), where.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, args := where.limitOffset(limit, offset)
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, COALESCE(actor_user_id, 0), action, entity, COALESCE(entity_id, 0), details, created_at FROM audit_log"+where.String()+" ORDER BY id DESC"+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.ActorUserID, &entry.Action, &entry.Entity, &entry.EntityID, &details, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entry.Details = details
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
@@ -1,91 +1,142 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
+	"ticket-booking-app/backend/config"
 	"ticket-booking-app/backend/models"
 )
 
-var DB *sql.DB
-
-func InitDB() {
-	dbName := os.Getenv("TEST_DB_NAME")
-	if dbName == "" {
-		dbName = "ticket_booking"
-	}
-	connStr := fmt.Sprintf("dbname=%s sslmode=disable", dbName)
-
-	var err error
-	DB, err = sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = DB.Ping()
-	if err != nil {
-		log.Fatal(err)
-	}
+// PostgresStore implements Store on a PostgreSQL database migrated by the
+// migrations package.
+type PostgresStore struct {
+	DB *sql.DB
+}
 
-	fmt.Printf("Successfully connected to database: %s\n", dbName)
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
 }
 
-func ConnectToPostgres(dbName string) (*sql.DB, error) {
-	connStr := fmt.Sprintf("dbname=%s sslmode=disable", dbName)
-	db, err := sql.Open("postgres", connStr)
+// Open connects to a database and checks that it is reachable.
+func Open(cfg config.DatabaseConfig) (*sql.DB, error) {
+	connector, err := pq.NewConnector(cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
+	db := sql.OpenDB(timedConnector{connector})
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func CreateUser(user models.User) (int, error) {
+// Connect opens the database like Open, retrying with exponential backoff
+// while it is unreachable, e.g. because it is starting at the same time as
+// the server. It gives up after cfg.ConnectTimeout or when ctx is done.
+func Connect(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+
+	delay := 500 * time.Millisecond
+	for {
+		db, err := Open(cfg)
+		if err == nil {
+			return db, nil
+		}
+		slog.Warn("Database not reachable, retrying", "delay", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("database: giving up connecting: %w", err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 10*time.Second)
+	}
+}
+
+func (s *PostgresStore) CreateUser(ctx context.Context, user models.User) (int, error) {
 	var id int
-	err := DB.QueryRow("INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id",
-		user.Name, user.Email, user.Password).Scan(&id)
+	if user.Language == "" {
+		user.Language = "en"
+	}
+	err := s.DB.QueryRowContext(ctx, "INSERT INTO users (name, email, password, language, phone, phone_verified) VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6) RETURNING id",
+		user.Name, user.Email, user.Password, user.Language, user.Phone, user.PhoneVerified).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func GetUserByEmail(email string) (models.User, error) {
+// userColumns is the column list read by scanUser. Email and phone are
+// optional and read as empty strings when missing.
+const userColumns = `id, name, COALESCE(email, ''), password, COALESCE(operator_id, 0), language, email_verified, COALESCE(phone, ''), phone_verified, totp_enabled, totp_secret, totp_last_step`
+
+func scanUser(row rowScanner) (models.User, error) {
 	var user models.User
-	row := DB.QueryRow("SELECT id, name, email, password FROM users WHERE email = $1", email)
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password)
-	if err != nil {
-		return user, err
-	}
-	return user, nil
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.OperatorID, &user.Language, &user.EmailVerified, &user.Phone, &user.PhoneVerified, &user.TOTPEnabled, &user.TOTPSecret, &user.TOTPLastStep)
+	return user, err
+}
+
+func (s *PostgresStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(s.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE email = $1", email))
+}
+
+func (s *PostgresStore) GetUserByID(ctx context.Context, id int) (models.User, error) {
+	return scanUser(s.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+// GetUserByPhone looks a user up by normalized phone number.
+func (s *PostgresStore) GetUserByPhone(ctx context.Context, phone string) (models.User, error) {
+	return scanUser(s.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE phone = $1", phone))
 }
 
-func GetTripByID(id int) (models.Trip, error) {
+// tripColumns is the column list read by scanTrip.
+const tripColumns = `id, "from", "to", date, departure_time, arrival_time, price, seats_available, bus_operator, duration, amenities, intermediate_stops, reviews, seats, COALESCE(operator_id, 0), COALESCE(bus_id, 0), status, delay_minutes, status_reason`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTrip(row rowScanner) (models.Trip, error) {
 	var trip models.Trip
-	var seats pq.StringArray
-	row := DB.QueryRow(`SELECT id, "from", "to", date, "departure_time", "arrival_time", price, seats, seats_available FROM trips WHERE id = $1`, id)
-	err := row.Scan(&trip.ID, &trip.From, &trip.To, &trip.Date, &trip.DepartureTime, &trip.ArrivalTime, &trip.Price, &seats, &trip.SeatsAvailable)
+	var amenities, intermediateStops, seats pq.StringArray
+	var reviewsJSON []byte
+	err := row.Scan(&trip.ID, &trip.From, &trip.To, &trip.Date, &trip.DepartureTime, &trip.ArrivalTime, &trip.Price, &trip.SeatsAvailable, &trip.BusOperator, &trip.Duration, &amenities, &intermediateStops, &reviewsJSON, &seats, &trip.OperatorID, &trip.BusID, &trip.Status, &trip.DelayMinutes, &trip.StatusReason)
 	if err != nil {
 		return trip, err
 	}
+	trip.Amenities = []string(amenities)
+	trip.IntermediateStops = []string(intermediateStops)
 	trip.Seats = []string(seats)
+	json.Unmarshal(reviewsJSON, &trip.Reviews)
 	return trip, nil
 }
 
+func (s *PostgresStore) GetTripByID(ctx context.Context, id int) (models.Trip, error) {
+	row := s.DB.QueryRowContext(ctx, `SELECT `+tripColumns+` FROM trips WHERE id = $1`, id)
+	return scanTrip(row)
+}
+
 
-func SearchTrips(from, to, date string, flexibleDateRange int) ([]models.Trip, error) {
+func (s *PostgresStore) SearchTrips(ctx context.Context, from, to, date string, flexibleDateRange int) ([]models.Trip, error) {
 	var trips []models.Trip
-	query := `SELECT id, "from", "to", date, departure_time, arrival_time, price, seats_available, bus_operator, duration, amenities, intermediate_stops, reviews, seats FROM trips WHERE LOWER("from") = LOWER($1) AND LOWER("to") = LOWER($2)`
+	query := `SELECT ` + tripColumns + ` FROM trips WHERE status <> 'cancelled' AND LOWER("from") = LOWER($1) AND LOWER("to") = LOWER($2)`
 	args := []interface{}{from, to}
 
 	if date != "" {
@@ -98,24 +149,17 @@ func SearchTrips(from, to, date string, flexibleDateRange int) ([]models.Trip, e
 		}
 	}
 
-	rows, err := DB.Query(query, args...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var trip models.Trip
-		var amenities, intermediateStops, seats pq.StringArray
-		var reviewsJSON []byte
-		err := rows.Scan(&trip.ID, &trip.From, &trip.To, &trip.Date, &trip.DepartureTime, &trip.ArrivalTime, &trip.Price, &trip.SeatsAvailable, &trip.BusOperator, &trip.Duration, &amenities, &intermediateStops, &reviewsJSON, &seats)
+		trip, err := scanTrip(rows)
 		if err != nil {
 			return nil, err
 		}
-		trip.Amenities = []string(amenities)
-		trip.IntermediateStops = []string(intermediateStops)
-		trip.Seats = []string(seats)
-		json.Unmarshal(reviewsJSON, &trip.Reviews)
 		trips = append(trips, trip)
 	}
 
@@ -123,59 +167,120 @@ func SearchTrips(from, to, date string, flexibleDateRange int) ([]models.Trip, e
 }
 
 
-func CreateTrip(trip models.Trip) (models.Trip, error) {
+func (s *PostgresStore) CreateTrip(ctx context.Context, trip models.Trip) (models.Trip, error) {
 	var id int
-	var seats pq.StringArray = trip.Seats
-	err := DB.QueryRow("INSERT INTO trips (\"from\", \"to\", date, \"departure_time\", \"arrival_time\", price, seats, seats_available) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
-		trip.From, trip.To, trip.Date, trip.DepartureTime, trip.ArrivalTime, trip.Price, pq.Array(seats), trip.SeatsAvailable).Scan(&id)
+	reviewsJSON, err := json.Marshal(trip.Reviews)
+	if err != nil {
+		return trip, err
+	}
+	err = s.DB.QueryRowContext(ctx, `INSERT INTO trips ("from", "to", date, departure_time, arrival_time, price, seats, seats_available, bus_operator, duration, amenities, intermediate_stops, reviews, operator_id, bus_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`,
+		trip.From, trip.To, trip.Date, trip.DepartureTime, trip.ArrivalTime, trip.Price, stringArray(trip.Seats), trip.SeatsAvailable, trip.BusOperator, trip.Duration, stringArray(trip.Amenities), stringArray(trip.IntermediateStops), reviewsJSON, nullInt(trip.OperatorID), nullInt(trip.BusID)).Scan(&id)
 	if err != nil {
 		return trip, err
 	}
 	trip.ID = id
+	trip.Status = models.TripScheduled
 	return trip, nil
 }
 
+// stringArray converts a slice for a NOT NULL TEXT[] column; a nil slice
+// would otherwise be written as NULL.
+func stringArray(values []string) pq.StringArray {
+	if values == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(values)
+}
+
+// nullInt maps the zero value of an optional foreign key to NULL.
+func nullInt(value int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value), Valid: value != 0}
+}
+
+
+
+// ErrSeatTaken is returned when a seat asked for is no longer on sale.
+var ErrSeatTaken = errors.New("seat already taken")
+
+// SeatsTakenError is an ErrSeatTaken that lists the seats in question.
+type SeatsTakenError struct {
+	Seats []string
+}
+
+func (e *SeatsTakenError) Error() string {
+	return ErrSeatTaken.Error() + ": " + strings.Join(e.Seats, ", ")
+}
+
+func (e *SeatsTakenError) Is(target error) bool {
+	return target == ErrSeatTaken
+}
+
+// bookingColumns is the column list read by scanBooking.
+const bookingColumns = `id, user_id, trip_id, seats, status, refund_eligible, created_at`
+
+func scanBooking(row rowScanner) (models.Booking, error) {
+	var booking models.Booking
+	var seats pq.StringArray
+	err := row.Scan(&booking.ID, &booking.UserID, &booking.TripID, &seats, &booking.Status, &booking.RefundEligible, &booking.CreatedAt)
+	booking.Seats = []string(seats)
+	return booking, err
+}
+
+// CreateBooking books seats on a trip and takes them off sale in one
+// transaction. The trip stays locked until it commits, so of two bookings
+// for the same seat only the first succeeds; the other fails with
+// ErrSeatTaken. Trips no longer open for booking fail with
+// ErrTripNotBookable.
+func (s *PostgresStore) CreateBooking(ctx context.Context, booking models.Booking) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
+	if err := lockBookableTrip(ctx, tx, booking.TripID); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE trips SET
+			seats = ARRAY(SELECT seat FROM unnest(seats) WITH ORDINALITY AS free(seat, n) WHERE seat <> ALL($1) ORDER BY n),
+			seats_available = seats_available - $2
+		WHERE id = $3 AND seats @> $1`,
+		pq.Array(booking.Seats), len(booking.Seats), booking.TripID)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, ErrSeatTaken
+	}
 
-func CreateBooking(booking models.Booking) (int, error) {
 	var id int
-	err := DB.QueryRow("INSERT INTO bookings (user_id, trip_id, seats) VALUES ($1, $2, $3) RETURNING id",
+	err = tx.QueryRowContext(ctx, "INSERT INTO bookings (user_id, trip_id, seats) VALUES ($1, $2, $3) RETURNING id",
 		booking.UserID, booking.TripID, pq.Array(booking.Seats)).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
-	return id, nil
-}
-
-func UpdateTripSeats(tripID int, newSeats []string, seatsAvailable int) error {
-	_, err := DB.Exec("UPDATE trips SET seats = $1, seats_available = $2 WHERE id = $3",
-		pq.Array(newSeats), seatsAvailable, tripID)
-	return err
+	return id, tx.Commit()
 }
 
-func GetUserProfile(email string) (models.User, []models.Booking, error) {
-	var user models.User
-	userRow := DB.QueryRow("SELECT id, name, email FROM users WHERE email = $1", email)
-	err := userRow.Scan(&user.ID, &user.Name, &user.Email)
+func (s *PostgresStore) GetUserProfile(ctx context.Context, userID int) (models.User, []models.Booking, error) {
+	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
 		return user, nil, err
 	}
 
 	var bookings []models.Booking
-	rows, err := DB.Query("SELECT id, user_id, trip_id, seats FROM bookings WHERE user_id = $1", user.ID)
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+bookingColumns+" FROM bookings WHERE user_id = $1", user.ID)
 	if err != nil {
 		return user, nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var booking models.Booking
-		var seats pq.StringArray
-		err := rows.Scan(&booking.ID, &booking.UserID, &booking.TripID, &seats)
+		booking, err := scanBooking(rows)
 		if err != nil {
 			return user, nil, err
 		}
-		booking.Seats = []string(seats)
 		bookings = append(bookings, booking)
 	}
 
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"log/slog"
+	"strings"
+	"time"
+
+	"ticket-booking-app/backend/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("ticket-booking-app/backend/database")
+
+// timedConnector hands out connections that time their statements.
+type timedConnector struct {
+	driver.Connector
+}
+
+func (c timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if full, ok := conn.(fullConn); ok {
+		return timedConn{full}, nil
+	}
+	return conn, nil
+}
+
+// fullConn is what lib/pq connections implement; only such connections
+// are timed.
+type fullConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// timedConn reports the duration of every statement, including those run
+// in transactions, to the metrics package, and logs it at debug level with
+// the request ID carried by the statement's context. Statements run on
+// behalf of a traced request get a child span of their own.
+type timedConn struct {
+	fullConn
+}
+
+func (c timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	res, err := c.fullConn.ExecContext(ctx, query, args)
+	observe(ctx, span, query, time.Since(start), err)
+	return res, err
+}
+
+func (c timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, span := startSpan(ctx, query)
+	start := time.Now()
+	rows, err := c.fullConn.QueryContext(ctx, query, args)
+	observe(ctx, span, query, time.Since(start), err)
+	return rows, err
+}
+
+// startSpan starts a span for query if ctx belongs to a trace. Statements
+// of the background workers' polling loops are not traced on their own.
+func startSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+	op := operation(query)
+	return tracer.Start(ctx, strings.ToUpper(op),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", op),
+			// Arguments are bound separately, so the statement holds no values
+			attribute.String("db.statement", query),
+		))
+}
+
+func observe(ctx context.Context, span trace.Span, query string, d time.Duration, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	op := operation(query)
+	metrics.ObserveQuery(op, d, err)
+	attrs := []slog.Attr{
+		slog.String("operation", op),
+		slog.Float64("duration_ms", float64(d.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+}
+
+// operation returns the SQL command of query in lower case, e.g. "select".
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return strings.ToLower(fields[0])
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// LoginFailures counts the failed logins to an email address since the last
+// successful one.
+type LoginFailures struct {
+	Count int
+	Last  time.Time
+}
+
+// GetLoginFailures returns the failed logins to email, none if there are
+// no failures on record.
+func (s *PostgresStore) GetLoginFailures(ctx context.Context, email string) (LoginFailures, error) {
+	var f LoginFailures
+	err := s.DB.QueryRowContext(ctx, "SELECT failures, last_failure_at FROM login_failures WHERE email = $1", email).Scan(&f.Count, &f.Last)
+	if err == sql.ErrNoRows {
+		return LoginFailures{}, nil
+	}
+	return f, err
+}
+
+// RecordLoginFailure counts a failed login to email at the given time and
+// returns the failures so far. Failures before since are forgotten.
+func (s *PostgresStore) RecordLoginFailure(ctx context.Context, email string, at, since time.Time) (LoginFailures, error) {
+	var f LoginFailures
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO login_failures (email, failures, last_failure_at) VALUES ($1, 1, $2)
+		ON CONFLICT (email) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = $2
+		RETURNING failures, last_failure_at`, email, at, since).Scan(&f.Count, &f.Last)
+	return f, err
+}
+
+// ClearLoginFailures forgets the failed logins to email, after a successful
+// one.
+func (s *PostgresStore) ClearLoginFailures(ctx context.Context, email string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM login_failures WHERE email = $1", email)
+	return err
+}
@@ -0,0 +1,1429 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ticket-booking-app/backend/models"
+)
+
+// MemoryStore is an in-process Store for tests. It enforces the same
+// uniqueness and foreign key rules as the schema, so handlers see the same
+// failures they would against PostgreSQL.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID map[string]int
+
+	users         map[int]*models.User
+	roles         map[int]map[string]bool
+	recoveryCodes map[int]map[string]bool // user -> code hash -> used
+	operators     map[int]*models.Operator
+	buses         map[int]*models.Bus
+	trips         map[int]*memTrip
+	bookings      map[int]*models.Booking
+	waitlist      map[int]*models.WaitlistEntry
+	sessions      map[int]*models.Session
+	refreshTokens map[string]*memRefreshToken
+	userTokens    map[int]*memUserToken
+	otps          map[int]*memOTP
+	loginFailures map[string]LoginFailures
+	audit         []models.AuditEntry
+}
+
+type memTrip struct {
+	models.Trip
+	statusUpdatedAt time.Time
+}
+
+type memRefreshToken struct {
+	sessionID int
+	used      bool
+}
+
+type memUserToken struct {
+	UserToken
+	purpose   string
+	hash      string
+	expiresAt time.Time
+	used      bool
+}
+
+type memOTP struct {
+	OTPCode
+	phone     string
+	purpose   string
+	expiresAt time.Time
+	createdAt time.Time
+	consumed  bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		nextID:        map[string]int{},
+		users:         map[int]*models.User{},
+		roles:         map[int]map[string]bool{},
+		recoveryCodes: map[int]map[string]bool{},
+		operators:     map[int]*models.Operator{},
+		buses:         map[int]*models.Bus{},
+		trips:         map[int]*memTrip{},
+		bookings:      map[int]*models.Booking{},
+		waitlist:      map[int]*models.WaitlistEntry{},
+		sessions:      map[int]*models.Session{},
+		refreshTokens: map[string]*memRefreshToken{},
+		userTokens:    map[int]*memUserToken{},
+		otps:          map[int]*memOTP{},
+		loginFailures: map[string]LoginFailures{},
+	}
+}
+
+// id returns the next value of a table's serial column.
+func (s *MemoryStore) id(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// violation reports a broken constraint, standing in for the error
+// PostgreSQL would return.
+func violation(format string, args ...interface{}) error {
+	return fmt.Errorf("memory store: "+format, args...)
+}
+
+// timestamp formats a time the way a TIMESTAMPTZ column scans into a string.
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+// page returns the items of a list between offset and offset+limit.
+func page[T any](items []T, limit, offset int) []T {
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return append([]T{}, items[offset:end]...)
+}
+
+// sortedIDs returns the keys of a table in ascending order.
+func sortedIDs[T any](table map[int]T) []int {
+	ids := make([]int, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Users
+
+func (s *MemoryStore) CreateUser(ctx context.Context, user models.User) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.Email == "" && user.Phone == "" {
+		return 0, violation("user needs an email or a phone")
+	}
+	if err := s.checkUnique(0, user.Email, user.Phone); err != nil {
+		return 0, err
+	}
+	if user.Language == "" {
+		user.Language = "en"
+	}
+	id := s.id("users")
+	s.users[id] = &models.User{
+		ID:            id,
+		Name:          user.Name,
+		Email:         user.Email,
+		Password:      user.Password,
+		Language:      user.Language,
+		Phone:         user.Phone,
+		PhoneVerified: user.PhoneVerified,
+	}
+	return id, nil
+}
+
+// checkUnique fails if another user than userID has the email or phone.
+func (s *MemoryStore) checkUnique(userID int, email, phone string) error {
+	for _, u := range s.users {
+		if u.ID == userID {
+			continue
+		}
+		if email != "" && u.Email == email {
+			return violation("duplicate email %q", email)
+		}
+		if phone != "" && u.Phone == phone {
+			return violation("duplicate phone %q", phone)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) findUser(match func(*models.User) bool) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if match(u) {
+			return *u, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
+
+func (s *MemoryStore) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return s.findUser(func(u *models.User) bool { return email != "" && u.Email == email })
+}
+
+func (s *MemoryStore) GetUserByID(ctx context.Context, id int) (models.User, error) {
+	return s.findUser(func(u *models.User) bool { return u.ID == id })
+}
+
+func (s *MemoryStore) GetUserByPhone(ctx context.Context, phone string) (models.User, error) {
+	return s.findUser(func(u *models.User) bool { return phone != "" && u.Phone == phone })
+}
+
+func (s *MemoryStore) GetUserProfile(ctx context.Context, userID int) (models.User, []models.Booking, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return user, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var bookings []models.Booking
+	for _, id := range sortedIDs(s.bookings) {
+		if b := s.bookings[id]; b.UserID == userID {
+			bookings = append(bookings, cloneBooking(b))
+		}
+	}
+	return user, bookings, nil
+}
+
+func (s *MemoryStore) ListUsers(ctx context.Context, query string, limit, offset int) ([]models.User, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.ToLower(query)
+	var matched []models.User
+	for _, id := range sortedIDs(s.users) {
+		u := s.users[id]
+		if query != "" && !strings.Contains(strings.ToLower(u.Name), query) &&
+			!strings.Contains(strings.ToLower(u.Email), query) && !strings.Contains(u.Phone, query) {
+			continue
+		}
+		matched = append(matched, models.User{ID: u.ID, Name: u.Name, Email: u.Email, OperatorID: u.OperatorID, EmailVerified: u.EmailVerified, Phone: u.Phone})
+	}
+	return page(matched, limit, offset), len(matched), nil
+}
+
+// updateUser applies fn to a user, doing nothing if there is none, like an
+// UPDATE matching no rows.
+func (s *MemoryStore) updateUser(userID int, fn func(*models.User) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[userID]; ok {
+		return fn(u)
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetUserOperator(ctx context.Context, userID, operatorID int) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		if _, ok := s.operators[operatorID]; operatorID != 0 && !ok {
+			return violation("operator %d does not exist", operatorID)
+		}
+		u.OperatorID = operatorID
+		return nil
+	})
+}
+
+func (s *MemoryStore) SetUserPhone(ctx context.Context, userID int, phone string) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		if err := s.checkUnique(userID, "", phone); err != nil {
+			return err
+		}
+		u.Phone, u.PhoneVerified = phone, true
+		return nil
+	})
+}
+
+func (s *MemoryStore) SetPhoneVerified(ctx context.Context, userID int) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		u.PhoneVerified = true
+		return nil
+	})
+}
+
+func (s *MemoryStore) SetUserEmail(ctx context.Context, userID int, email, passwordHash string) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		if err := s.checkUnique(userID, email, ""); err != nil {
+			return err
+		}
+		u.Email, u.Password, u.EmailVerified = email, passwordHash, false
+		return nil
+	})
+}
+
+func (s *MemoryStore) SetEmailVerified(ctx context.Context, userID int) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		u.EmailVerified = true
+		return nil
+	})
+}
+
+func (s *MemoryStore) ResetPassword(ctx context.Context, userID int, passwordHash string) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		u.Password = passwordHash
+		now := time.Now()
+		for _, session := range s.sessions {
+			if session.UserID == userID && session.RevokedAt == nil {
+				session.RevokedAt = &now
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roles := []string{}
+	for role := range s.roles[userID] {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+func (s *MemoryStore) GrantRole(ctx context.Context, userID int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return violation("user %d does not exist", userID)
+	}
+	if s.roles[userID] == nil {
+		s.roles[userID] = map[string]bool{}
+	}
+	s.roles[userID][role] = true
+	return nil
+}
+
+func (s *MemoryStore) RevokeRole(ctx context.Context, userID int, role string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	had := s.roles[userID][role]
+	delete(s.roles[userID], role)
+	return had, nil
+}
+
+func (s *MemoryStore) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		if !u.TOTPEnabled {
+			u.TOTPSecret = secret
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) EnableTOTP(ctx context.Context, userID int, step int64, recoveryHashes []string) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		u.TOTPEnabled, u.TOTPLastStep = true, step
+		s.replaceRecoveryCodes(userID, recoveryHashes)
+		return nil
+	})
+}
+
+func (s *MemoryStore) DisableTOTP(ctx context.Context, userID int) error {
+	return s.updateUser(userID, func(u *models.User) error {
+		u.TOTPEnabled, u.TOTPSecret, u.TOTPLastStep = false, "", 0
+		delete(s.recoveryCodes, userID)
+		return nil
+	})
+}
+
+func (s *MemoryStore) RecordTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok || u.TOTPLastStep >= step {
+		return false, nil
+	}
+	u.TOTPLastStep = step
+	return true, nil
+}
+
+func (s *MemoryStore) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok && len(hashes) > 0 {
+		return violation("user %d does not exist", userID)
+	}
+	s.replaceRecoveryCodes(userID, hashes)
+	return nil
+}
+
+func (s *MemoryStore) replaceRecoveryCodes(userID int, hashes []string) {
+	codes := map[string]bool{}
+	for _, h := range hashes {
+		codes[h] = false
+	}
+	s.recoveryCodes[userID] = codes
+}
+
+func (s *MemoryStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, ok := s.recoveryCodes[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	s.recoveryCodes[userID][hash] = true
+	return true, nil
+}
+
+func (s *MemoryStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, used := range s.recoveryCodes[userID] {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Trips
+
+func cloneTrip(t *memTrip) models.Trip {
+	trip := t.Trip
+	trip.Seats = cloneStrings(t.Seats)
+	trip.Amenities = cloneStrings(t.Amenities)
+	trip.IntermediateStops = cloneStrings(t.IntermediateStops)
+	trip.Reviews = append([]models.Review(nil), t.Reviews...)
+	return trip
+}
+
+// sameDate compares a stored trip date with a YYYY-MM-DD date.
+func sameDate(stored, date string) bool {
+	return strings.HasPrefix(stored, date)
+}
+
+func (s *MemoryStore) checkTripRefs(trip models.Trip) error {
+	if _, ok := s.operators[trip.OperatorID]; trip.OperatorID != 0 && !ok {
+		return violation("operator %d does not exist", trip.OperatorID)
+	}
+	if _, ok := s.buses[trip.BusID]; trip.BusID != 0 && !ok {
+		return violation("bus %d does not exist", trip.BusID)
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateTrip(ctx context.Context, trip models.Trip) (models.Trip, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkTripRefs(trip); err != nil {
+		return trip, err
+	}
+	trip.ID = s.id("trips")
+	trip.Status = models.TripScheduled
+	trip.DelayMinutes = 0
+	trip.StatusReason = ""
+	trip.Seats = stringArray(trip.Seats)
+	trip.Amenities = stringArray(trip.Amenities)
+	trip.IntermediateStops = stringArray(trip.IntermediateStops)
+	stored := &memTrip{Trip: trip, statusUpdatedAt: time.Now()}
+	s.trips[trip.ID] = stored
+	return cloneTrip(stored), nil
+}
+
+func (s *MemoryStore) GetTripByID(ctx context.Context, id int) (models.Trip, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.trips[id]
+	if !ok {
+		return models.Trip{}, sql.ErrNoRows
+	}
+	return cloneTrip(t), nil
+}
+
+func (s *MemoryStore) SearchTrips(ctx context.Context, from, to, date string, flexibleDateRange int) ([]models.Trip, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var day time.Time
+	if date != "" {
+		var err error
+		if day, err = time.Parse("2006-01-02", date); err != nil {
+			return nil, err
+		}
+	}
+
+	var trips []models.Trip
+	for _, id := range sortedIDs(s.trips) {
+		t := s.trips[id]
+		if t.Status == models.TripCancelled || !strings.EqualFold(t.From, from) || !strings.EqualFold(t.To, to) {
+			continue
+		}
+		if date != "" {
+			tripDay, err := time.Parse("2006-01-02", strings.SplitN(t.Date, "T", 2)[0])
+			if err != nil {
+				return nil, err
+			}
+			days := int(tripDay.Sub(day).Hours() / 24)
+			if days < -flexibleDateRange || days > flexibleDateRange {
+				continue
+			}
+		}
+		trips = append(trips, cloneTrip(t))
+	}
+	return trips, nil
+}
+
+func (s *MemoryStore) ListTrips(ctx context.Context, filter TripFilter, limit, offset int) ([]models.Trip, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []models.Trip
+	for _, t := range s.trips {
+		if filter.From != "" && !strings.EqualFold(t.From, filter.From) ||
+			filter.To != "" && !strings.EqualFold(t.To, filter.To) ||
+			filter.Date != "" && !sameDate(t.Date, filter.Date) ||
+			filter.OperatorID != 0 && t.OperatorID != filter.OperatorID {
+			continue
+		}
+		matched = append(matched, cloneTrip(t))
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.Date != b.Date {
+			return a.Date > b.Date
+		}
+		if a.DepartureTime != b.DepartureTime {
+			return a.DepartureTime < b.DepartureTime
+		}
+		return a.ID < b.ID
+	})
+	return page(matched, limit, offset), len(matched), nil
+}
+
+func (s *MemoryStore) ListTripsByOperator(ctx context.Context, operatorID int) ([]models.Trip, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trips := []models.Trip{}
+	for _, id := range sortedIDs(s.trips) {
+		if t := s.trips[id]; t.OperatorID == operatorID {
+			trips = append(trips, cloneTrip(t))
+		}
+	}
+	sort.SliceStable(trips, func(i, j int) bool {
+		if trips[i].Date != trips[j].Date {
+			return trips[i].Date < trips[j].Date
+		}
+		return trips[i].DepartureTime < trips[j].DepartureTime
+	})
+	return trips, nil
+}
+
+func (s *MemoryStore) UpdateTrip(ctx context.Context, trip models.Trip) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trips[trip.ID]
+	if !ok {
+		return nil
+	}
+	if _, ok := s.buses[trip.BusID]; trip.BusID != 0 && !ok {
+		return violation("bus %d does not exist", trip.BusID)
+	}
+	t.From, t.To, t.Date = trip.From, trip.To, trip.Date
+	t.DepartureTime, t.ArrivalTime = trip.DepartureTime, trip.ArrivalTime
+	t.Price, t.Duration = trip.Price, trip.Duration
+	t.Amenities = stringArray(cloneStrings(trip.Amenities))
+	t.IntermediateStops = stringArray(cloneStrings(trip.IntermediateStops))
+	t.BusID = trip.BusID
+	return nil
+}
+
+func (s *MemoryStore) AdjustTripSeats(ctx context.Context, tripID int, seats []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trips[tripID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	held := map[string]bool{}
+	for _, b := range s.bookings {
+		if b.TripID == tripID && b.Status != models.BookingCancelled {
+			for _, seat := range b.Seats {
+				held[seat] = true
+			}
+		}
+	}
+	for _, e := range s.waitlist {
+		if e.TripID == tripID && e.Status == models.WaitlistOffered {
+			for _, seat := range e.OfferedSeats {
+				held[seat] = true
+			}
+		}
+	}
+	var taken []string
+	for _, seat := range seats {
+		if held[seat] {
+			taken = append(taken, seat)
+		}
+	}
+	if len(taken) > 0 {
+		sort.Strings(taken)
+		return nil, &SeatsTakenError{Seats: taken}
+	}
+
+	before := cloneStrings(t.Seats)
+	t.SeatsAvailable += len(seats) - len(t.Seats)
+	t.Seats = cloneStrings(seats)
+	return before, nil
+}
+
+func (s *MemoryStore) DeleteTrip(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.trips[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, b := range s.bookings {
+		if b.TripID == id {
+			return ErrTripHasBookings
+		}
+	}
+	for entryID, e := range s.waitlist {
+		if e.TripID == id {
+			delete(s.waitlist, entryID)
+		}
+	}
+	delete(s.trips, id)
+	return nil
+}
+
+func (s *MemoryStore) GetTripStatus(ctx context.Context, tripID int) (models.TripStatusUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.trips[tripID]
+	if !ok {
+		return models.TripStatusUpdate{TripID: tripID}, sql.ErrNoRows
+	}
+	return models.TripStatusUpdate{
+		TripID:       tripID,
+		Status:       t.Status,
+		DelayMinutes: t.DelayMinutes,
+		Reason:       t.StatusReason,
+		UpdatedAt:    timestamp(t.statusUpdatedAt),
+	}, nil
+}
+
+func (s *MemoryStore) UpdateTripStatus(ctx context.Context, update models.TripStatusUpdate) (models.TripStatusUpdate, []models.Booking, []models.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trips[update.TripID]
+	if !ok {
+		return update, nil, nil, sql.ErrNoRows
+	}
+	if !t.Status.CanTransitionTo(update.Status) {
+		return update, nil, nil, ErrInvalidTransition
+	}
+	if update.Status != models.TripDelayed {
+		update.DelayMinutes = 0
+	}
+
+	t.Status, t.DelayMinutes, t.StatusReason = update.Status, update.DelayMinutes, update.Reason
+	t.statusUpdatedAt = time.Now()
+	update.UpdatedAt = timestamp(t.statusUpdatedAt)
+
+	var (
+		cancelled []models.Booking
+		ended     []models.WaitlistEntry
+	)
+	if update.Status == models.TripCancelled {
+		for _, id := range sortedIDs(s.bookings) {
+			b := s.bookings[id]
+			if b.TripID == update.TripID && b.Status == models.BookingConfirmed {
+				b.Status, b.RefundEligible = models.BookingCancelled, true
+				cancelled = append(cancelled, cloneBooking(b))
+			}
+		}
+		for _, id := range sortedIDs(s.waitlist) {
+			e := s.waitlist[id]
+			if e.TripID == update.TripID && activeWaitlistEntry(e) {
+				e.Status = models.WaitlistCancelled
+				ended = append(ended, cloneWaitlistEntry(e))
+			}
+		}
+	}
+	return update, cancelled, ended, nil
+}
+
+// Bookings
+
+func cloneBooking(b *models.Booking) models.Booking {
+	booking := *b
+	booking.Seats = cloneStrings(b.Seats)
+	return booking
+}
+
+func (s *MemoryStore) CreateBooking(ctx context.Context, booking models.Booking) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trips[booking.TripID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	if !t.Status.Bookable() {
+		return 0, ErrTripNotBookable
+	}
+	if booking.Seats == nil {
+		return 0, violation("booking seats must not be null")
+	}
+	for _, seat := range booking.Seats {
+		if !slices.Contains(t.Seats, seat) {
+			return 0, ErrSeatTaken
+		}
+	}
+	if _, ok := s.users[booking.UserID]; !ok {
+		return 0, violation("user %d does not exist", booking.UserID)
+	}
+	free := []string{}
+	for _, seat := range t.Seats {
+		if !slices.Contains(booking.Seats, seat) {
+			free = append(free, seat)
+		}
+	}
+	t.Seats = free
+	t.SeatsAvailable -= len(booking.Seats)
+
+	id := s.id("bookings")
+	s.bookings[id] = &models.Booking{
+		ID:        id,
+		UserID:    booking.UserID,
+		TripID:    booking.TripID,
+		Seats:     cloneStrings(booking.Seats),
+		Status:    models.BookingConfirmed,
+		CreatedAt: timestamp(time.Now()),
+	}
+	return id, nil
+}
+
+func (s *MemoryStore) GetBookingByID(ctx context.Context, id int) (models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bookings[id]
+	if !ok {
+		return models.Booking{}, sql.ErrNoRows
+	}
+	return cloneBooking(b), nil
+}
+
+func (s *MemoryStore) GetBookingsByTrip(ctx context.Context, tripID int) ([]models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bookings := []models.Booking{}
+	for _, id := range sortedIDs(s.bookings) {
+		if b := s.bookings[id]; b.TripID == tripID {
+			bookings = append(bookings, cloneBooking(b))
+		}
+	}
+	return bookings, nil
+}
+
+func (s *MemoryStore) ListBookings(ctx context.Context, filter BookingFilter, limit, offset int) ([]models.Booking, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := sortedIDs(s.bookings)
+	var matched []models.Booking
+	for i := len(ids) - 1; i >= 0; i-- {
+		b := s.bookings[ids[i]]
+		if filter.UserID != 0 && b.UserID != filter.UserID ||
+			filter.TripID != 0 && b.TripID != filter.TripID ||
+			filter.Status != "" && b.Status != filter.Status {
+			continue
+		}
+		matched = append(matched, cloneBooking(b))
+	}
+	return page(matched, limit, offset), len(matched), nil
+}
+
+func (s *MemoryStore) CountActiveBookings(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, b := range s.bookings {
+		if b.UserID == userID && b.Status == models.BookingConfirmed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) CancelBooking(ctx context.Context, id int) (models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.bookings[id]
+	if !ok {
+		return models.Booking{}, sql.ErrNoRows
+	}
+	if b.Status == models.BookingCancelled {
+		return cloneBooking(b), ErrBookingAlreadyCancelled
+	}
+	b.Status = models.BookingCancelled
+	if t, ok := s.trips[b.TripID]; ok {
+		t.Seats = append(cloneStrings(t.Seats), b.Seats...)
+		t.SeatsAvailable += len(b.Seats)
+	}
+	return cloneBooking(b), nil
+}
+
+// Waitlist
+
+func cloneWaitlistEntry(e *models.WaitlistEntry) models.WaitlistEntry {
+	entry := *e
+	entry.OfferedSeats = cloneStrings(e.OfferedSeats)
+	if e.OfferExpiresAt != nil {
+		expires := *e.OfferExpiresAt
+		entry.OfferExpiresAt = &expires
+	}
+	return entry
+}
+
+func activeWaitlistEntry(e *models.WaitlistEntry) bool {
+	return e.Status == models.WaitlistWaiting || e.Status == models.WaitlistOffered
+}
+
+func (s *MemoryStore) JoinWaitlist(ctx context.Context, tripID, userID, seats int) (models.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return models.WaitlistEntry{}, violation("user %d does not exist", userID)
+	}
+	t, ok := s.trips[tripID]
+	if !ok {
+		return models.WaitlistEntry{}, violation("trip %d does not exist", tripID)
+	}
+	if !t.Status.Bookable() {
+		return models.WaitlistEntry{}, ErrTripNotBookable
+	}
+	if seats <= 0 {
+		return models.WaitlistEntry{}, violation("waitlist seats must be positive")
+	}
+	for _, e := range s.waitlist {
+		if e.TripID == tripID && e.UserID == userID && activeWaitlistEntry(e) {
+			return models.WaitlistEntry{}, ErrAlreadyWaitlisted
+		}
+	}
+	id := s.id("waitlist_entries")
+	s.waitlist[id] = &models.WaitlistEntry{
+		ID:        id,
+		TripID:    tripID,
+		UserID:    userID,
+		Seats:     seats,
+		Status:    models.WaitlistWaiting,
+		CreatedAt: time.Now(),
+	}
+	return cloneWaitlistEntry(s.waitlist[id]), nil
+}
+
+func (s *MemoryStore) GetWaitlistEntry(ctx context.Context, tripID, userID int) (models.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *models.WaitlistEntry
+	for _, e := range s.waitlist {
+		if e.TripID == tripID && e.UserID == userID && activeWaitlistEntry(e) {
+			found = e
+		}
+	}
+	if found == nil {
+		return models.WaitlistEntry{}, sql.ErrNoRows
+	}
+	entry := cloneWaitlistEntry(found)
+	if entry.Status == models.WaitlistWaiting {
+		for _, e := range s.waitlist {
+			if e.TripID == tripID && e.Status == models.WaitlistWaiting && e.ID <= entry.ID {
+				entry.Position++
+			}
+		}
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) LeaveWaitlist(ctx context.Context, id int) (models.WaitlistEntry, error) {
+	return s.endWaitlistEntry(id, models.WaitlistLeft, models.WaitlistWaiting, models.WaitlistOffered)
+}
+
+func (s *MemoryStore) ExpireWaitlistOffer(ctx context.Context, id int) (models.WaitlistEntry, error) {
+	return s.endWaitlistEntry(id, models.WaitlistExpired, models.WaitlistOffered)
+}
+
+func (s *MemoryStore) endWaitlistEntry(id int, status string, from ...string) (models.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.waitlist[id]
+	if !ok || !slices.Contains(from, e.Status) {
+		return models.WaitlistEntry{}, sql.ErrNoRows
+	}
+	entry := cloneWaitlistEntry(e)
+	e.Status = status
+	if entry.Status == models.WaitlistOffered {
+		if t, ok := s.trips[e.TripID]; ok {
+			t.Seats = append(cloneStrings(t.Seats), e.OfferedSeats...)
+			t.SeatsAvailable += len(e.OfferedSeats)
+		}
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) OfferWaitlistSeats(ctx context.Context, tripID int, expires time.Time) ([]models.WaitlistEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.trips[tripID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	var waiting []models.WaitlistEntry
+	for _, id := range sortedIDs(s.waitlist) {
+		if e := s.waitlist[id]; e.TripID == tripID && e.Status == models.WaitlistWaiting {
+			waiting = append(waiting, cloneWaitlistEntry(e))
+		}
+	}
+	offers, free := offerSeats(waiting, t.Seats, expires)
+	for _, entry := range offers {
+		e := s.waitlist[entry.ID]
+		e.Status = entry.Status
+		e.OfferedSeats = cloneStrings(entry.OfferedSeats)
+		e.OfferExpiresAt = &expires
+		t.SeatsAvailable -= len(entry.OfferedSeats)
+	}
+	if len(offers) > 0 {
+		t.Seats = cloneStrings(free)
+	}
+	return offers, nil
+}
+
+func (s *MemoryStore) ConfirmWaitlistOffer(ctx context.Context, id int, now time.Time) (models.Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.waitlist[id]
+	if !ok {
+		return models.Booking{}, sql.ErrNoRows
+	}
+	if t, ok := s.trips[e.TripID]; ok && !t.Status.Bookable() {
+		return models.Booking{}, ErrTripNotBookable
+	}
+	if e.Status != models.WaitlistOffered || !now.Before(*e.OfferExpiresAt) {
+		return models.Booking{}, ErrNoWaitlistOffer
+	}
+	bookingID := s.id("bookings")
+	s.bookings[bookingID] = &models.Booking{
+		ID:        bookingID,
+		UserID:    e.UserID,
+		TripID:    e.TripID,
+		Seats:     cloneStrings(e.OfferedSeats),
+		Status:    models.BookingConfirmed,
+		CreatedAt: timestamp(now),
+	}
+	e.Status = models.WaitlistBooked
+	e.BookingID = bookingID
+	return cloneBooking(s.bookings[bookingID]), nil
+}
+
+// Operators
+
+func cloneBus(b *models.Bus) models.Bus {
+	bus := *b
+	bus.Seats = cloneStrings(b.Seats)
+	bus.Amenities = cloneStrings(b.Amenities)
+	return bus
+}
+
+func (s *MemoryStore) CreateOperator(ctx context.Context, name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.operators {
+		if o.Name == name {
+			return 0, violation("duplicate operator %q", name)
+		}
+	}
+	id := s.id("operators")
+	s.operators[id] = &models.Operator{ID: id, Name: name}
+	return id, nil
+}
+
+func (s *MemoryStore) GetOperatorByID(ctx context.Context, id int) (models.Operator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.operators[id]
+	if !ok {
+		return models.Operator{}, sql.ErrNoRows
+	}
+	return *o, nil
+}
+
+// checkPlate fails if a bus other than busID has the plate number.
+func (s *MemoryStore) checkPlate(busID int, plate string) error {
+	for _, b := range s.buses {
+		if b.ID != busID && b.PlateNumber == plate {
+			return violation("duplicate plate number %q", plate)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateBus(ctx context.Context, bus models.Bus) (models.Bus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.operators[bus.OperatorID]; !ok {
+		return bus, violation("operator %d does not exist", bus.OperatorID)
+	}
+	if err := s.checkPlate(0, bus.PlateNumber); err != nil {
+		return bus, err
+	}
+	bus.ID = s.id("buses")
+	bus.Seats = stringArray(cloneStrings(bus.Seats))
+	bus.Amenities = stringArray(cloneStrings(bus.Amenities))
+	s.buses[bus.ID] = &bus
+	return cloneBus(&bus), nil
+}
+
+func (s *MemoryStore) GetBusByID(ctx context.Context, id int) (models.Bus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buses[id]
+	if !ok {
+		return models.Bus{}, sql.ErrNoRows
+	}
+	return cloneBus(b), nil
+}
+
+func (s *MemoryStore) ListBusesByOperator(ctx context.Context, operatorID int) ([]models.Bus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buses := []models.Bus{}
+	for _, id := range sortedIDs(s.buses) {
+		if b := s.buses[id]; b.OperatorID == operatorID {
+			buses = append(buses, cloneBus(b))
+		}
+	}
+	return buses, nil
+}
+
+func (s *MemoryStore) UpdateBus(ctx context.Context, bus models.Bus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buses[bus.ID]
+	if !ok {
+		return nil
+	}
+	if err := s.checkPlate(bus.ID, bus.PlateNumber); err != nil {
+		return err
+	}
+	b.PlateNumber, b.Capacity = bus.PlateNumber, bus.Capacity
+	b.Seats = stringArray(cloneStrings(bus.Seats))
+	b.Amenities = stringArray(cloneStrings(bus.Amenities))
+	return nil
+}
+
+func (s *MemoryStore) DeleteBus(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.trips {
+		if t.BusID == id {
+			return ErrBusInUse
+		}
+	}
+	delete(s.buses, id)
+	return nil
+}
+
+// Sessions
+
+func (s *MemoryStore) CreateSession(ctx context.Context, userID int, userAgent, ip string, expiresAt time.Time, tokenHash string, mfa bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return 0, violation("user %d does not exist", userID)
+	}
+	if _, ok := s.refreshTokens[tokenHash]; ok {
+		return 0, violation("duplicate refresh token")
+	}
+	now := time.Now()
+	id := s.id("sessions")
+	s.sessions[id] = &models.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  expiresAt,
+		MFA:        mfa,
+	}
+	s.refreshTokens[tokenHash] = &memRefreshToken{sessionID: id}
+	return id, nil
+}
+
+func cloneSession(session *models.Session) models.Session {
+	c := *session
+	if session.RevokedAt != nil {
+		revoked := *session.RevokedAt
+		c.RevokedAt = &revoked
+	}
+	return c
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, oldHash, newHash string) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[oldHash]
+	if !ok {
+		return models.Session{}, sql.ErrNoRows
+	}
+	session := s.sessions[token.sessionID]
+	now := time.Now()
+	if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return models.Session{}, ErrSessionRevoked
+	}
+	if token.used {
+		session.RevokedAt = &now
+		return models.Session{}, ErrRefreshTokenReused
+	}
+	if _, ok := s.refreshTokens[newHash]; ok {
+		return models.Session{}, violation("duplicate refresh token")
+	}
+
+	token.used = true
+	s.refreshTokens[newHash] = &memRefreshToken{sessionID: session.ID}
+	session.LastUsedAt = now
+	return cloneSession(session), nil
+}
+
+func (s *MemoryStore) GetSessionByRefreshToken(ctx context.Context, tokenHash string) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return models.Session{}, sql.ErrNoRows
+	}
+	return cloneSession(s.sessions[token.sessionID]), nil
+}
+
+func (s *MemoryStore) ListSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, cloneSession(session))
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, userID, sessionID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionID]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return true, nil
+}
+
+func (s *MemoryStore) IsSessionActive(ctx context.Context, sessionID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return false, nil
+	}
+	return session.RevokedAt == nil && session.ExpiresAt.After(time.Now()), nil
+}
+
+func (s *MemoryStore) SetSessionMFA(ctx context.Context, sessionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[sessionID]; ok {
+		session.MFA = true
+	}
+	return nil
+}
+
+// Tokens
+
+func (s *MemoryStore) CreateUserToken(ctx context.Context, userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return violation("user %d does not exist", userID)
+	}
+	for id, t := range s.userTokens {
+		if t.UserID == userID && t.purpose == purpose && !t.used {
+			delete(s.userTokens, id)
+		}
+	}
+	for _, t := range s.userTokens {
+		if t.hash == tokenHash {
+			return violation("duplicate user token")
+		}
+	}
+	id := s.id("user_tokens")
+	s.userTokens[id] = &memUserToken{
+		UserToken: UserToken{ID: id, UserID: userID},
+		purpose:   purpose,
+		hash:      tokenHash,
+		expiresAt: expiresAt,
+	}
+	return nil
+}
+
+// activeUserToken returns the unused, unexpired token with the hash.
+func (s *MemoryStore) activeUserToken(purpose, tokenHash string) (*memUserToken, bool) {
+	now := time.Now()
+	for _, t := range s.userTokens {
+		if t.purpose == purpose && t.hash == tokenHash && !t.used && t.expiresAt.After(now) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (s *MemoryStore) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.activeUserToken(purpose, tokenHash)
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	t.used = true
+	return t.UserID, nil
+}
+
+func (s *MemoryStore) LookupUserToken(ctx context.Context, purpose, tokenHash string) (UserToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.activeUserToken(purpose, tokenHash)
+	if !ok {
+		return UserToken{}, sql.ErrNoRows
+	}
+	return t.UserToken, nil
+}
+
+func (s *MemoryStore) RecordUserTokenFailure(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.userTokens[id]; ok {
+		t.Attempts++
+	}
+	return nil
+}
+
+func (s *MemoryStore) ConsumeUserTokenByID(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.userTokens[id]
+	if !ok || t.used {
+		return false, nil
+	}
+	t.used = true
+	return true, nil
+}
+
+func (s *MemoryStore) CreateOTP(ctx context.Context, phone, purpose string, userID int, codeHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; userID != 0 && !ok {
+		return violation("user %d does not exist", userID)
+	}
+	now := time.Now()
+	for _, otp := range s.otps {
+		if otp.phone == phone && otp.purpose == purpose && !otp.consumed && otp.expiresAt.After(now) {
+			otp.expiresAt = now
+		}
+	}
+	id := s.id("otp_codes")
+	s.otps[id] = &memOTP{
+		OTPCode:   OTPCode{ID: id, UserID: userID, CodeHash: codeHash},
+		phone:     phone,
+		purpose:   purpose,
+		expiresAt: expiresAt,
+		createdAt: now,
+	}
+	return nil
+}
+
+func (s *MemoryStore) CountOTPsSince(ctx context.Context, phone string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, otp := range s.otps {
+		if otp.phone == phone && otp.createdAt.After(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) FailedOTPAttemptsSince(ctx context.Context, phone string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, otp := range s.otps {
+		if otp.phone == phone && otp.createdAt.After(since) {
+			n += otp.Attempts
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) GetActiveOTP(ctx context.Context, phone, purpose string) (OTPCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var latest *memOTP
+	for _, id := range sortedIDs(s.otps) {
+		otp := s.otps[id]
+		if otp.phone == phone && otp.purpose == purpose && !otp.consumed && otp.expiresAt.After(now) {
+			latest = otp
+		}
+	}
+	if latest == nil {
+		return OTPCode{}, sql.ErrNoRows
+	}
+	return latest.OTPCode, nil
+}
+
+func (s *MemoryStore) RecordOTPFailure(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if otp, ok := s.otps[id]; ok {
+		otp.Attempts++
+	}
+	return nil
+}
+
+func (s *MemoryStore) ConsumeOTP(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	otp, ok := s.otps[id]
+	if !ok || otp.consumed {
+		return false, nil
+	}
+	otp.consumed = true
+	return true, nil
+}
+
+// Lockouts
+
+func (s *MemoryStore) GetLoginFailures(ctx context.Context, email string) (LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loginFailures[email], nil
+}
+
+func (s *MemoryStore) RecordLoginFailure(ctx context.Context, email string, at, since time.Time) (LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.loginFailures[email]
+	if f.Last.Before(since) {
+		f.Count = 0
+	}
+	f.Count++
+	f.Last = at
+	s.loginFailures[email] = f
+	return f, nil
+}
+
+func (s *MemoryStore) ClearLoginFailures(ctx context.Context, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.loginFailures, email)
+	return nil
+}
+
+// Audit
+
+func (s *MemoryStore) RecordAudit(ctx context.Context, actorUserID int, action, entity string, entityID int, details interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[actorUserID]; actorUserID != 0 && !ok {
+		return violation("user %d does not exist", actorUserID)
+	}
+	s.audit = append(s.audit, models.AuditEntry{
+		ID:          s.id("audit_log"),
+		ActorUserID: actorUserID,
+		Action:      action,
+		Entity:      entity,
+		EntityID:    entityID,
+		Details:     detailsJSON,
+		CreatedAt:   timestamp(time.Now()),
+	})
+	return nil
+}
+
+func (s *MemoryStore) ListAudit(ctx context.Context, entity string, limit, offset int) ([]models.AuditEntry, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []models.AuditEntry
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		if entity == "" || s.audit[i].Entity == entity {
+			matched = append(matched, s.audit[i])
+		}
+	}
+	return page(matched, limit, offset), len(matched), nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetTOTPSecret stores a new, not yet enabled, TOTP secret for a user who
+// has not enabled two-factor authentication.
+func (s *PostgresStore) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET totp_secret = $1 WHERE id = $2 AND NOT totp_enabled", secret, userID)
+	return err
+}
+
+// EnableTOTP turns two-factor authentication on after the user proved their
+// app works by entering the code of step, and stores their recovery codes.
+func (s *PostgresStore) EnableTOTP(ctx context.Context, userID int, step int64, recoveryHashes []string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled = TRUE, totp_last_step = $1 WHERE id = $2", step, userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled = FALSE, totp_secret = '', totp_last_step = 0 WHERE id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordTOTPStep stores step as the last accepted time step. It reports
+// false if an equal or later step was already used, i.e. the code is being
+// replayed by a concurrent request.
+func (s *PostgresStore) RecordTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1", step, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReplaceRecoveryCodes invalidates a user's recovery codes and stores new
+// ones.
+func (s *PostgresStore) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks one of the user's unused recovery codes as used and
+// reports whether it was found.
+func (s *PostgresStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL", userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *PostgresStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&n)
+	return n, err
+}
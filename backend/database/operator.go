@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/lib/pq"
+	"ticket-booking-app/backend/models"
+)
+
+// ErrBusInUse is returned when deleting a bus that trips are assigned to.
+var ErrBusInUse = errors.New("bus is assigned to trips")
+
+func (s *PostgresStore) CreateOperator(ctx context.Context, name string) (int, error) {
+	var id int
+	err := s.DB.QueryRowContext(ctx, "INSERT INTO operators (name) VALUES ($1) RETURNING id", name).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *PostgresStore) GetOperatorByID(ctx context.Context, id int) (models.Operator, error) {
+	var operator models.Operator
+	err := s.DB.QueryRowContext(ctx, "SELECT id, name FROM operators WHERE id = $1", id).Scan(&operator.ID, &operator.Name)
+	return operator, err
+}
+
+// SetUserOperator attaches a user account to an operator, making it an
+// operator staff account. Passing 0 detaches it again.
+func (s *PostgresStore) SetUserOperator(ctx context.Context, userID, operatorID int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET operator_id = $1 WHERE id = $2", nullInt(operatorID), userID)
+	return err
+}
+
+func (s *PostgresStore) ListTripsByOperator(ctx context.Context, operatorID int) ([]models.Trip, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+tripColumns+` FROM trips WHERE operator_id = $1 ORDER BY date, departure_time`, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trips := []models.Trip{}
+	for rows.Next() {
+		trip, err := scanTrip(rows)
+		if err != nil {
+			return nil, err
+		}
+		trips = append(trips, trip)
+	}
+	return trips, rows.Err()
+}
+
+// UpdateTrip overwrites the editable schedule fields of a trip. Seat
+// inventory and ownership are left untouched.
+func (s *PostgresStore) UpdateTrip(ctx context.Context, trip models.Trip) error {
+	_, err := s.DB.ExecContext(ctx, `UPDATE trips SET "from" = $1, "to" = $2, date = $3, departure_time = $4, arrival_time = $5, price = $6, duration = $7, amenities = $8, intermediate_stops = $9, bus_id = $10 WHERE id = $11`,
+		trip.From, trip.To, trip.Date, trip.DepartureTime, trip.ArrivalTime, trip.Price, trip.Duration, stringArray(trip.Amenities), stringArray(trip.IntermediateStops), nullInt(trip.BusID), trip.ID)
+	return err
+}
+
+func (s *PostgresStore) GetBookingsByTrip(ctx context.Context, tripID int) ([]models.Booking, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+bookingColumns+" FROM bookings WHERE trip_id = $1 ORDER BY id", tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, booking)
+	}
+	return bookings, rows.Err()
+}
+
+func (s *PostgresStore) CreateBus(ctx context.Context, bus models.Bus) (models.Bus, error) {
+	err := s.DB.QueryRowContext(ctx, "INSERT INTO buses (operator_id, plate_number, capacity, seats, amenities) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		bus.OperatorID, bus.PlateNumber, bus.Capacity, stringArray(bus.Seats), stringArray(bus.Amenities)).Scan(&bus.ID)
+	return bus, err
+}
+
+func scanBus(row rowScanner) (models.Bus, error) {
+	var bus models.Bus
+	var seats, amenities pq.StringArray
+	err := row.Scan(&bus.ID, &bus.OperatorID, &bus.PlateNumber, &bus.Capacity, &seats, &amenities)
+	bus.Seats = []string(seats)
+	bus.Amenities = []string(amenities)
+	return bus, err
+}
+
+func (s *PostgresStore) GetBusByID(ctx context.Context, id int) (models.Bus, error) {
+	row := s.DB.QueryRowContext(ctx, "SELECT id, operator_id, plate_number, capacity, seats, amenities FROM buses WHERE id = $1", id)
+	return scanBus(row)
+}
+
+func (s *PostgresStore) ListBusesByOperator(ctx context.Context, operatorID int) ([]models.Bus, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, operator_id, plate_number, capacity, seats, amenities FROM buses WHERE operator_id = $1 ORDER BY id", operatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buses := []models.Bus{}
+	for rows.Next() {
+		bus, err := scanBus(rows)
+		if err != nil {
+			return nil, err
+		}
+		buses = append(buses, bus)
+	}
+	return buses, rows.Err()
+}
+
+func (s *PostgresStore) UpdateBus(ctx context.Context, bus models.Bus) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE buses SET plate_number = $1, capacity = $2, seats = $3, amenities = $4 WHERE id = $5",
+		bus.PlateNumber, bus.Capacity, stringArray(bus.Seats), stringArray(bus.Amenities), bus.ID)
+	return err
+}
+
+// DeleteBus removes a bus. It fails with ErrBusInUse while trips reference
+// it.
+func (s *PostgresStore) DeleteBus(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM buses WHERE id = $1", id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrBusInUse
+	}
+	return err
+}
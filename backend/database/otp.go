@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Purposes of one-time SMS codes.
+const (
+	OTPLogin = "login"
+	OTPLink  = "link"
+)
+
+type OTPCode struct {
+	ID       int
+	UserID   int
+	CodeHash string
+	Attempts int
+}
+
+// CreateOTP stores a new code for phone. Earlier codes for the same purpose
+// stop working.
+func (s *PostgresStore) CreateOTP(ctx context.Context, phone, purpose string, userID int, codeHash string, expiresAt time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE otp_codes SET expires_at = NOW() WHERE phone = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()", phone, purpose); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO otp_codes (phone, purpose, user_id, code_hash, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		phone, purpose, nullInt(userID), codeHash, expiresAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CountOTPsSince returns how many codes were sent to phone since the given
+// time.
+func (s *PostgresStore) CountOTPsSince(ctx context.Context, phone string, since time.Time) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM otp_codes WHERE phone = $1 AND created_at > $2", phone, since).Scan(&n)
+	return n, err
+}
+
+// FailedOTPAttemptsSince returns the number of wrong codes entered for phone
+// on codes sent since the given time.
+func (s *PostgresStore) FailedOTPAttemptsSince(ctx context.Context, phone string, since time.Time) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, "SELECT COALESCE(SUM(attempts), 0) FROM otp_codes WHERE phone = $1 AND created_at > $2", phone, since).Scan(&n)
+	return n, err
+}
+
+// GetActiveOTP returns the latest unused, unexpired code for phone.
+func (s *PostgresStore) GetActiveOTP(ctx context.Context, phone, purpose string) (OTPCode, error) {
+	var otp OTPCode
+	var userID sql.NullInt64
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, user_id, code_hash, attempts FROM otp_codes
+		WHERE phone = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC LIMIT 1`, phone, purpose).Scan(&otp.ID, &userID, &otp.CodeHash, &otp.Attempts)
+	otp.UserID = int(userID.Int64)
+	return otp, err
+}
+
+func (s *PostgresStore) RecordOTPFailure(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE otp_codes SET attempts = attempts + 1 WHERE id = $1", id)
+	return err
+}
+
+// ConsumeOTP marks a code used. It reports false if another request used it
+// first.
+func (s *PostgresStore) ConsumeOTP(ctx context.Context, id int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE otp_codes SET consumed_at = NOW() WHERE id = $1 AND consumed_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetUserPhone attaches a verified phone number to a user.
+func (s *PostgresStore) SetUserPhone(ctx context.Context, userID int, phone string) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET phone = $1, phone_verified = TRUE WHERE id = $2", phone, userID)
+	return err
+}
+
+func (s *PostgresStore) SetPhoneVerified(ctx context.Context, userID int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET phone_verified = TRUE WHERE id = $1", userID)
+	return err
+}
+
+// SetUserEmail adds an email and password to an account, e.g. one created
+// by phone. The new address starts unverified.
+func (s *PostgresStore) SetUserEmail(ctx context.Context, userID int, email, passwordHash string) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET email = $1, password = $2, email_verified = FALSE WHERE id = $3", email, passwordHash, userID)
+	return err
+}
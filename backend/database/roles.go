@@ -0,0 +1,37 @@
+package database
+
+import "context"
+
+func (s *PostgresStore) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// GrantRole is idempotent: granting a role the user already has is a no-op.
+func (s *PostgresStore) GrantRole(ctx context.Context, userID int, role string) error {
+	_, err := s.DB.ExecContext(ctx, "INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, role)
+	return err
+}
+
+// RevokeRole reports whether the user actually had the role.
+func (s *PostgresStore) RevokeRole(ctx context.Context, userID int, role string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role = $2", userID, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
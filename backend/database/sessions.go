@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ticket-booking-app/backend/models"
+)
+
+var (
+	ErrSessionRevoked     = errors.New("session revoked or expired")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// CreateSession starts a session and stores its first refresh token.
+func (s *PostgresStore) CreateSession(ctx context.Context, userID int, userAgent, ip string, expiresAt time.Time, tokenHash string, mfa bool) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO sessions (user_id, user_agent, ip, expires_at, mfa) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		userID, userAgent, ip, expiresAt, mfa).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO refresh_tokens (session_id, token_hash) VALUES ($1, $2)", id, tokenHash); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// RotateRefreshToken exchanges the refresh token with hash oldHash for
+// newHash and returns the session it belongs to.
+//
+// Presenting a token that has already been used means it was stolen or
+// replayed: the whole session is revoked and ErrRefreshTokenReused returned.
+// An unknown token gives sql.ErrNoRows.
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, oldHash, newHash string) (models.Session, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Session{}, err
+	}
+	defer tx.Rollback()
+
+	var session models.Session
+	var usedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, s.user_agent, s.ip, s.created_at, s.last_used_at, s.expires_at, s.revoked_at, s.mfa, rt.used_at
+		FROM refresh_tokens rt JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt, s`, oldHash).
+		Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt, &session.MFA, &usedAt)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+		return models.Session{}, ErrSessionRevoked
+	}
+	if usedAt.Valid {
+		if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1", session.ID); err != nil {
+			return models.Session{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return models.Session{}, err
+		}
+		return models.Session{}, ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1", oldHash); err != nil {
+		return models.Session{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO refresh_tokens (session_id, token_hash) VALUES ($1, $2)", session.ID, newHash); err != nil {
+		return models.Session{}, err
+	}
+	if err := tx.QueryRowContext(ctx, "UPDATE sessions SET last_used_at = NOW() WHERE id = $1 RETURNING last_used_at", session.ID).Scan(&session.LastUsedAt); err != nil {
+		return models.Session{}, err
+	}
+	return session, tx.Commit()
+}
+
+// GetSessionByRefreshToken returns the session a refresh token belongs to,
+// whether or not the token has been used.
+func (s *PostgresStore) GetSessionByRefreshToken(ctx context.Context, tokenHash string) (models.Session, error) {
+	var session models.Session
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, s.user_agent, s.ip, s.created_at, s.last_used_at, s.expires_at, s.revoked_at, s.mfa
+		FROM refresh_tokens rt JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = $1`, tokenHash).
+		Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt, &session.MFA)
+	return session, err
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+func (s *PostgresStore) ListSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at, mfa
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt, &session.MFA); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession revokes one of the user's sessions and reports whether an
+// active session was found.
+func (s *PostgresStore) RevokeSession(ctx context.Context, userID, sessionID int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// IsSessionActive reports whether the session exists, has not been revoked
+// and has not expired.
+func (s *PostgresStore) IsSessionActive(ctx context.Context, sessionID int) (bool, error) {
+	var active bool
+	err := s.DB.QueryRowContext(ctx, "SELECT revoked_at IS NULL AND expires_at > NOW() FROM sessions WHERE id = $1", sessionID).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return active, err
+}
+
+// SetSessionMFA records that a session has passed two-factor
+// authentication.
+func (s *PostgresStore) SetSessionMFA(ctx context.Context, sessionID int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE sessions SET mfa = TRUE WHERE id = $1", sessionID)
+	return err
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"ticket-booking-app/backend/models"
+)
+
+// The repositories below describe everything the server stores. Lookups of a
+// single row that does not exist fail with sql.ErrNoRows, whichever
+// implementation is used. The methods are documented on PostgresStore.
+
+// Users stores accounts, their roles and their second factors.
+type Users interface {
+	CreateUser(ctx context.Context, user models.User) (int, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	GetUserByID(ctx context.Context, id int) (models.User, error)
+	GetUserByPhone(ctx context.Context, phone string) (models.User, error)
+	GetUserProfile(ctx context.Context, userID int) (models.User, []models.Booking, error)
+	ListUsers(ctx context.Context, query string, limit, offset int) ([]models.User, int, error)
+	SetUserOperator(ctx context.Context, userID, operatorID int) error
+	SetUserPhone(ctx context.Context, userID int, phone string) error
+	SetPhoneVerified(ctx context.Context, userID int) error
+	SetUserEmail(ctx context.Context, userID int, email, passwordHash string) error
+	SetEmailVerified(ctx context.Context, userID int) error
+	ResetPassword(ctx context.Context, userID int, passwordHash string) error
+
+	GetUserRoles(ctx context.Context, userID int) ([]string, error)
+	GrantRole(ctx context.Context, userID int, role string) error
+	RevokeRole(ctx context.Context, userID int, role string) (bool, error)
+
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, step int64, recoveryHashes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	RecordTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+}
+
+// Trips stores the trip schedule, seat inventory and trip status.
+type Trips interface {
+	CreateTrip(ctx context.Context, trip models.Trip) (models.Trip, error)
+	GetTripByID(ctx context.Context, id int) (models.Trip, error)
+	SearchTrips(ctx context.Context, from, to, date string, flexibleDateRange int) ([]models.Trip, error)
+	ListTrips(ctx context.Context, filter TripFilter, limit, offset int) ([]models.Trip, int, error)
+	ListTripsByOperator(ctx context.Context, operatorID int) ([]models.Trip, error)
+	UpdateTrip(ctx context.Context, trip models.Trip) error
+	AdjustTripSeats(ctx context.Context, tripID int, seats []string) ([]string, error)
+	DeleteTrip(ctx context.Context, id int) error
+	GetTripStatus(ctx context.Context, tripID int) (models.TripStatusUpdate, error)
+	UpdateTripStatus(ctx context.Context, update models.TripStatusUpdate) (models.TripStatusUpdate, []models.Booking, []models.WaitlistEntry, error)
+}
+
+// Bookings stores passengers' bookings.
+type Bookings interface {
+	CreateBooking(ctx context.Context, booking models.Booking) (int, error)
+	GetBookingByID(ctx context.Context, id int) (models.Booking, error)
+	GetBookingsByTrip(ctx context.Context, tripID int) ([]models.Booking, error)
+	ListBookings(ctx context.Context, filter BookingFilter, limit, offset int) ([]models.Booking, int, error)
+	CountActiveBookings(ctx context.Context, userID int) (int, error)
+	CancelBooking(ctx context.Context, id int) (models.Booking, error)
+}
+
+// Operators stores bus operators and their fleets.
+type Operators interface {
+	CreateOperator(ctx context.Context, name string) (int, error)
+	GetOperatorByID(ctx context.Context, id int) (models.Operator, error)
+	CreateBus(ctx context.Context, bus models.Bus) (models.Bus, error)
+	GetBusByID(ctx context.Context, id int) (models.Bus, error)
+	ListBusesByOperator(ctx context.Context, operatorID int) ([]models.Bus, error)
+	UpdateBus(ctx context.Context, bus models.Bus) error
+	DeleteBus(ctx context.Context, id int) error
+}
+
+// Sessions stores login sessions and their refresh tokens.
+type Sessions interface {
+	CreateSession(ctx context.Context, userID int, userAgent, ip string, expiresAt time.Time, tokenHash string, mfa bool) (int, error)
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string) (models.Session, error)
+	GetSessionByRefreshToken(ctx context.Context, tokenHash string) (models.Session, error)
+	ListSessions(ctx context.Context, userID int) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID int) (bool, error)
+	IsSessionActive(ctx context.Context, sessionID int) (bool, error)
+	SetSessionMFA(ctx context.Context, sessionID int) error
+}
+
+// Tokens stores single-use tokens: emailed links, login challenges and SMS
+// codes.
+type Tokens interface {
+	CreateUserToken(ctx context.Context, userID int, purpose, tokenHash string, expiresAt time.Time) error
+	ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (int, error)
+	LookupUserToken(ctx context.Context, purpose, tokenHash string) (UserToken, error)
+	RecordUserTokenFailure(ctx context.Context, id int) error
+	ConsumeUserTokenByID(ctx context.Context, id int) (bool, error)
+
+	CreateOTP(ctx context.Context, phone, purpose string, userID int, codeHash string, expiresAt time.Time) error
+	CountOTPsSince(ctx context.Context, phone string, since time.Time) (int, error)
+	FailedOTPAttemptsSince(ctx context.Context, phone string, since time.Time) (int, error)
+	GetActiveOTP(ctx context.Context, phone, purpose string) (OTPCode, error)
+	RecordOTPFailure(ctx context.Context, id int) error
+	ConsumeOTP(ctx context.Context, id int) (bool, error)
+}
+
+// Lockouts counts failed logins per email address.
+type Lockouts interface {
+	GetLoginFailures(ctx context.Context, email string) (LoginFailures, error)
+	RecordLoginFailure(ctx context.Context, email string, at, since time.Time) (LoginFailures, error)
+	ClearLoginFailures(ctx context.Context, email string) error
+}
+
+// Waitlists stores the users waiting for seats on sold-out trips and the
+// seats held for them.
+type Waitlists interface {
+	JoinWaitlist(ctx context.Context, tripID, userID, seats int) (models.WaitlistEntry, error)
+	GetWaitlistEntry(ctx context.Context, tripID, userID int) (models.WaitlistEntry, error)
+	LeaveWaitlist(ctx context.Context, id int) (models.WaitlistEntry, error)
+	OfferWaitlistSeats(ctx context.Context, tripID int, expires time.Time) ([]models.WaitlistEntry, error)
+	ConfirmWaitlistOffer(ctx context.Context, id int, now time.Time) (models.Booking, error)
+	ExpireWaitlistOffer(ctx context.Context, id int) (models.WaitlistEntry, error)
+}
+
+// Audit stores the trail of administrative changes.
+type Audit interface {
+	RecordAudit(ctx context.Context, actorUserID int, action, entity string, entityID int, details interface{}) error
+	ListAudit(ctx context.Context, entity string, limit, offset int) ([]models.AuditEntry, int, error)
+}
+
+// Store is every repository together, as used by the HTTP handlers.
+// PostgresStore is the production implementation and MemoryStore keeps
+// everything in process for tests.
+type Store interface {
+	Users
+	Trips
+	Bookings
+	Waitlists
+	Operators
+	Sessions
+	Tokens
+	Lockouts
+	Audit
+}
+
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"ticket-booking-app/backend/models"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid trip status transition")
+	// ErrTripNotBookable is returned for bookings and waitlist entries on
+	// trips that are cancelled, departed or arrived.
+	ErrTripNotBookable = errors.New("trip is not open for booking")
+)
+
+func (s *PostgresStore) GetTripStatus(ctx context.Context, tripID int) (models.TripStatusUpdate, error) {
+	status := models.TripStatusUpdate{TripID: tripID}
+	err := s.DB.QueryRowContext(ctx, "SELECT status, delay_minutes, status_reason, status_updated_at FROM trips WHERE id = $1", tripID).
+		Scan(&status.Status, &status.DelayMinutes, &status.Reason, &status.UpdatedAt)
+	return status, err
+}
+
+// UpdateTripStatus moves a trip to a new state, enforcing the allowed
+// transitions. Cancelling a trip cancels all of its confirmed bookings and
+// marks them eligible for a full refund; those bookings are returned so the
+// caller can notify the passengers. It also ends the trip's waitlist, so
+// that no seats are offered or booked on it afterwards, and returns the
+// ended entries so that those users can be told too.
+func (s *PostgresStore) UpdateTripStatus(ctx context.Context, update models.TripStatusUpdate) (models.TripStatusUpdate, []models.Booking, []models.WaitlistEntry, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return update, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var current models.TripStatus
+	err = tx.QueryRowContext(ctx, "SELECT status FROM trips WHERE id = $1 FOR UPDATE", update.TripID).Scan(&current)
+	if err != nil {
+		return update, nil, nil, err
+	}
+	if !current.CanTransitionTo(update.Status) {
+		return update, nil, nil, ErrInvalidTransition
+	}
+
+	// Only a delay carries a delay; any other state clears it
+	if update.Status != models.TripDelayed {
+		update.DelayMinutes = 0
+	}
+
+	err = tx.QueryRowContext(ctx, "UPDATE trips SET status = $1, delay_minutes = $2, status_reason = $3, status_updated_at = NOW() WHERE id = $4 RETURNING status_updated_at",
+		update.Status, update.DelayMinutes, update.Reason, update.TripID).Scan(&update.UpdatedAt)
+	if err != nil {
+		return update, nil, nil, err
+	}
+
+	var (
+		cancelled []models.Booking
+		ended     []models.WaitlistEntry
+	)
+	if update.Status == models.TripCancelled {
+		rows, err := tx.QueryContext(ctx, "UPDATE bookings SET status = $1, refund_eligible = TRUE, cancelled_at = NOW() WHERE trip_id = $2 AND status = $3 RETURNING "+bookingColumns,
+			models.BookingCancelled, update.TripID, models.BookingConfirmed)
+		if err != nil {
+			return update, nil, nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			booking, err := scanBooking(rows)
+			if err != nil {
+				return update, nil, nil, err
+			}
+			cancelled = append(cancelled, booking)
+		}
+		if err := rows.Err(); err != nil {
+			return update, nil, nil, err
+		}
+
+		ended, err = endWaitlist(ctx, tx, update.TripID)
+		if err != nil {
+			return update, nil, nil, err
+		}
+	}
+
+	return update, cancelled, ended, tx.Commit()
+}
+
+// endWaitlist cancels the waiting and offered entries of a trip and returns
+// them.
+func endWaitlist(ctx context.Context, tx *sql.Tx, tripID int) ([]models.WaitlistEntry, error) {
+	rows, err := tx.QueryContext(ctx, "UPDATE waitlist_entries SET status = $1 WHERE trip_id = $2 AND status IN ('waiting', 'offered') RETURNING "+waitlistColumns,
+		models.WaitlistCancelled, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ended []models.WaitlistEntry
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		ended = append(ended, entry)
+	}
+	return ended, rows.Err()
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Purposes of single-use user tokens.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	// TokenMFALogin is the challenge between the password and second factor
+	// steps of a login.
+	TokenMFALogin = "mfa_login"
+)
+
+type UserToken struct {
+	ID       int
+	UserID   int
+	Attempts int
+}
+
+// CreateUserToken stores a token for user. Earlier unused tokens for the same
+// purpose are discarded, so only the most recent link mailed works.
+func (s *PostgresStore) CreateUserToken(ctx context.Context, userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL", userID, purpose); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		userID, purpose, tokenHash, expiresAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeUserToken marks a token used and returns its user. Unknown, expired
+// and already used tokens all give sql.ErrNoRows.
+func (s *PostgresStore) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (int, error) {
+	var userID int
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`, purpose, tokenHash).Scan(&userID)
+	return userID, err
+}
+
+// LookupUserToken returns an unused, unexpired token without consuming it,
+// for flows that allow a few failed attempts before the token is used.
+func (s *PostgresStore) LookupUserToken(ctx context.Context, purpose, tokenHash string) (UserToken, error) {
+	var t UserToken
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, user_id, attempts FROM user_tokens
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > NOW()`,
+		purpose, tokenHash).Scan(&t.ID, &t.UserID, &t.Attempts)
+	return t, err
+}
+
+func (s *PostgresStore) RecordUserTokenFailure(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE user_tokens SET attempts = attempts + 1 WHERE id = $1", id)
+	return err
+}
+
+// ConsumeUserTokenByID marks a token used, reporting false if it already
+// was.
+func (s *PostgresStore) ConsumeUserTokenByID(ctx context.Context, id int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE user_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *PostgresStore) SetEmailVerified(ctx context.Context, userID int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET email_verified = TRUE WHERE id = $1", userID)
+	return err
+}
+
+// ResetPassword sets a new password hash and revokes all of the user's
+// sessions, since whoever knew the old password may be logged in.
+func (s *PostgresStore) ResetPassword(ctx context.Context, userID int, passwordHash string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", passwordHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CountActiveBookings returns the number of confirmed bookings of a user.
+func (s *PostgresStore) CountActiveBookings(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM bookings WHERE user_id = $1 AND status = 'confirmed'", userID).Scan(&n)
+	return n, err
+}
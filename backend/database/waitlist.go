@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ticket-booking-app/backend/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAlreadyWaitlisted = errors.New("already on the waitlist")
+	ErrNoWaitlistOffer   = errors.New("no seats held for the waitlist entry")
+)
+
+const waitlistColumns = `id, trip_id, user_id, seats, status, offered_seats, offer_expires_at, COALESCE(booking_id, 0), created_at`
+
+func scanWaitlistEntry(row rowScanner) (models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	var offered pq.StringArray
+	var expires sql.NullTime
+	err := row.Scan(&entry.ID, &entry.TripID, &entry.UserID, &entry.Seats, &entry.Status, &offered, &expires, &entry.BookingID, &entry.CreatedAt)
+	entry.OfferedSeats = []string(offered)
+	if expires.Valid {
+		entry.OfferExpiresAt = &expires.Time
+	}
+	return entry, err
+}
+
+// JoinWaitlist adds a user to the end of a trip's waitlist. A user has at
+// most one active entry per trip; another attempt fails with
+// ErrAlreadyWaitlisted. Trips no longer open for booking have no waitlist
+// and fail with ErrTripNotBookable.
+func (s *PostgresStore) JoinWaitlist(ctx context.Context, tripID, userID, seats int) (models.WaitlistEntry, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockBookableTrip(ctx, tx, tripID); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	entry, err := scanWaitlistEntry(tx.QueryRowContext(ctx, `
+		INSERT INTO waitlist_entries (trip_id, user_id, seats) VALUES ($1, $2, $3)
+		ON CONFLICT (trip_id, user_id) WHERE status IN ('waiting', 'offered') DO NOTHING
+		RETURNING `+waitlistColumns, tripID, userID, seats))
+	if err == sql.ErrNoRows {
+		return entry, ErrAlreadyWaitlisted
+	}
+	if err != nil {
+		return entry, err
+	}
+	return entry, tx.Commit()
+}
+
+// lockBookableTrip locks a trip's row for the rest of tx, so that its status
+// cannot change, and fails with ErrTripNotBookable unless it is open for
+// booking.
+func lockBookableTrip(ctx context.Context, tx *sql.Tx, tripID int) error {
+	var status models.TripStatus
+	if err := tx.QueryRowContext(ctx, "SELECT status FROM trips WHERE id = $1 FOR UPDATE", tripID).Scan(&status); err != nil {
+		return err
+	}
+	if !status.Bookable() {
+		return ErrTripNotBookable
+	}
+	return nil
+}
+
+// GetWaitlistEntry returns the active entry of a user for a trip, with its
+// position if it is waiting.
+func (s *PostgresStore) GetWaitlistEntry(ctx context.Context, tripID, userID int) (models.WaitlistEntry, error) {
+	entry, err := scanWaitlistEntry(s.DB.QueryRowContext(ctx, "SELECT "+waitlistColumns+" FROM waitlist_entries WHERE trip_id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')", tripID, userID))
+	if err != nil || entry.Status != models.WaitlistWaiting {
+		return entry, err
+	}
+	err = s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM waitlist_entries WHERE trip_id = $1 AND status = 'waiting' AND id <= $2", tripID, entry.ID).Scan(&entry.Position)
+	return entry, err
+}
+
+// LeaveWaitlist ends an active entry and returns it as it was. Seats held
+// for it go back to the trip.
+func (s *PostgresStore) LeaveWaitlist(ctx context.Context, id int) (models.WaitlistEntry, error) {
+	return s.endWaitlistEntry(ctx, id, models.WaitlistLeft, models.WaitlistWaiting, models.WaitlistOffered)
+}
+
+// ExpireWaitlistOffer ends an entry that is still offered seats and returns
+// it as it was. The seats go back to the trip.
+func (s *PostgresStore) ExpireWaitlistOffer(ctx context.Context, id int) (models.WaitlistEntry, error) {
+	return s.endWaitlistEntry(ctx, id, models.WaitlistExpired, models.WaitlistOffered)
+}
+
+// endWaitlistEntry moves an entry in one of the from states to status,
+// returning its held seats to the trip. Entries in other states are
+// reported as sql.ErrNoRows.
+func (s *PostgresStore) endWaitlistEntry(ctx context.Context, id int, status string, from ...string) (models.WaitlistEntry, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	defer tx.Rollback()
+
+	entry, err := scanWaitlistEntry(tx.QueryRowContext(ctx, "SELECT "+waitlistColumns+" FROM waitlist_entries WHERE id = $1 AND status = ANY($2) FOR UPDATE", id, pq.Array(from)))
+	if err != nil {
+		return entry, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE waitlist_entries SET status = $1 WHERE id = $2", status, id); err != nil {
+		return entry, err
+	}
+	if entry.Status == models.WaitlistOffered {
+		_, err = tx.ExecContext(ctx, "UPDATE trips SET seats = array_cat(seats, $1), seats_available = seats_available + $2 WHERE id = $3",
+			pq.Array(entry.OfferedSeats), len(entry.OfferedSeats), entry.TripID)
+		if err != nil {
+			return entry, err
+		}
+	}
+	return entry, tx.Commit()
+}
+
+// OfferWaitlistSeats takes the free seats of a trip off sale and holds them
+// until expires for the waiting entries in turn. Entries that want more
+// seats than are left keep their place for the next seats freed. It returns
+// the entries offered seats.
+func (s *PostgresStore) OfferWaitlistSeats(ctx context.Context, tripID int, expires time.Time) ([]models.WaitlistEntry, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var free pq.StringArray
+	if err := tx.QueryRowContext(ctx, "SELECT seats FROM trips WHERE id = $1 FOR UPDATE", tripID).Scan(&free); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT "+waitlistColumns+" FROM waitlist_entries WHERE trip_id = $1 AND status = 'waiting' ORDER BY id FOR UPDATE", tripID)
+	if err != nil {
+		return nil, err
+	}
+	var waiting []models.WaitlistEntry
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		waiting = append(waiting, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	offers, free := offerSeats(waiting, free, expires)
+	if len(offers) == 0 {
+		return nil, nil
+	}
+	held := 0
+	for _, entry := range offers {
+		_, err := tx.ExecContext(ctx, "UPDATE waitlist_entries SET status = $1, offered_seats = $2, offer_expires_at = $3 WHERE id = $4",
+			entry.Status, pq.Array(entry.OfferedSeats), entry.OfferExpiresAt, entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		held += len(entry.OfferedSeats)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE trips SET seats = $1, seats_available = seats_available - $2 WHERE id = $3", pq.Array(free), held, tripID); err != nil {
+		return nil, err
+	}
+	return offers, tx.Commit()
+}
+
+// offerSeats hands out free seats to the waiting entries in order and
+// returns the entries offered seats along with the seats left.
+func offerSeats(waiting []models.WaitlistEntry, free []string, expires time.Time) ([]models.WaitlistEntry, []string) {
+	var offers []models.WaitlistEntry
+	for _, entry := range waiting {
+		if len(free) == 0 {
+			break
+		}
+		if entry.Seats > len(free) {
+			continue
+		}
+		entry.Status = models.WaitlistOffered
+		entry.OfferedSeats = append([]string{}, free[:entry.Seats]...)
+		entry.OfferExpiresAt = &expires
+		entry.Position = 0
+		free = free[entry.Seats:]
+		offers = append(offers, entry)
+	}
+	return offers, free
+}
+
+// ConfirmWaitlistOffer books the seats held for an entry, unless its offer
+// expired before now or the trip is no longer open for booking.
+func (s *PostgresStore) ConfirmWaitlistOffer(ctx context.Context, id int, now time.Time) (models.Booking, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	defer tx.Rollback()
+
+	// The trip is locked before the entry, in the same order as
+	// UpdateTripStatus, so a cancellation either ends the offer first or
+	// waits for the booking and cancels it
+	var tripID int
+	if err := tx.QueryRowContext(ctx, "SELECT trip_id FROM waitlist_entries WHERE id = $1", id).Scan(&tripID); err != nil {
+		return models.Booking{}, err
+	}
+	if err := lockBookableTrip(ctx, tx, tripID); err != nil {
+		return models.Booking{}, err
+	}
+	entry, err := scanWaitlistEntry(tx.QueryRowContext(ctx, "SELECT "+waitlistColumns+" FROM waitlist_entries WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if entry.Status != models.WaitlistOffered || !now.Before(*entry.OfferExpiresAt) {
+		return models.Booking{}, ErrNoWaitlistOffer
+	}
+
+	booking := models.Booking{UserID: entry.UserID, TripID: entry.TripID, Seats: entry.OfferedSeats, Status: models.BookingConfirmed}
+	err = tx.QueryRowContext(ctx, "INSERT INTO bookings (user_id, trip_id, seats) VALUES ($1, $2, $3) RETURNING id, created_at",
+		booking.UserID, booking.TripID, pq.Array(booking.Seats)).Scan(&booking.ID, &booking.CreatedAt)
+	if err != nil {
+		return booking, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE waitlist_entries SET status = $1, booking_id = $2 WHERE id = $3", models.WaitlistBooked, booking.ID, id); err != nil {
+		return booking, err
+	}
+	return booking, tx.Commit()
+}
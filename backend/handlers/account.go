@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	passwordResetTTL     = time.Hour
+)
+
+// sendAccountEmail creates a single-use token for user and mails them a link
+// to path in the web app carrying it. Account emails go to the email address
+// only, never to SMS.
+func (s *Server) sendAccountEmail(ctx context.Context, user models.User, purpose string, ttl time.Duration, event notifications.Event, path string) error {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	if err := s.Store.CreateUserToken(ctx, user.ID, purpose, hash, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	if s.Outbox == nil {
+		return nil
+	}
+	link := s.App.URL + path + "?token=" + url.QueryEscape(token)
+	to := notifications.Recipient{Name: user.Name, Email: user.Email, Language: user.Language}
+	return notifications.Notify(ctx, s.Outbox, to, event, notifications.Data{Name: user.Name, Link: link})
+}
+
+func (s *Server) sendVerificationEmail(ctx context.Context, user models.User) error {
+	return s.sendAccountEmail(ctx, user, database.TokenVerifyEmail, emailVerificationTTL, notifications.EventVerifyEmail, "/verify-email")
+}
+
+// RequestEmailVerificationHandler mails a new verification link to the
+// caller, invalidating earlier ones.
+func (s *Server) RequestEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+	if user.EmailVerified {
+		apierror.Write(w, r, apierror.ErrEmailVerified)
+		return
+	}
+
+	if err := s.sendVerificationEmail(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending verification email", "user_id", user.ID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
+
+func (s *Server) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	userID, err := s.Store.ConsumeUserToken(r.Context(), database.TokenVerifyEmail, auth.HashToken(req.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrInvalidLink)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+	if err := s.Store.SetEmailVerified(r.Context(), userID); err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email address verified"})
+}
+
+// RequestPasswordResetHandler mails a reset link if an account exists for
+// the email. The response is the same either way so that it cannot be used
+// to find out which addresses are registered.
+func (s *Server) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email" validate:"required,max=254"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	user, err := s.Store.GetUserByEmail(r.Context(), req.Email)
+	switch {
+	case err == nil:
+		if err := s.sendAccountEmail(r.Context(), user, database.TokenResetPassword, passwordResetTTL, notifications.EventPasswordReset, "/reset-password"); err != nil {
+			slog.ErrorContext(r.Context(), "Error sending password reset email", "user_id", user.ID, "error", err)
+		}
+	case err != sql.ErrNoRows:
+		slog.ErrorContext(r.Context(), "Error looking up user for password reset", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If an account exists for this email, a reset link has been sent"})
+}
+
+// ResetPasswordHandler sets a new password using a reset token. All of the
+// user's sessions are logged out.
+func (s *Server) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,password"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		serverError(w, r, "Failed to hash password", err)
+		return
+	}
+
+	userID, err := s.Store.ConsumeUserToken(r.Context(), database.TokenResetPassword, auth.HashToken(req.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrInvalidLink)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+	if err := s.Store.ResetPassword(r.Context(), userID, string(hashedPassword)); err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting password", "user_id", userID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	// Following the emailed link proves control of the address
+	if err := s.Store.SetEmailVerified(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error marking email verified", "user_id", userID, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset"})
+}
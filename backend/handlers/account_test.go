@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+
+	"github.com/gorilla/mux"
+)
+
+func newAccountRouter(srv *handlers.Server) *mux.Router {
+	r := newSessionRouter(srv)
+	r.HandleFunc("/api/auth/signup", srv.SignupHandler).Methods("POST")
+	r.HandleFunc("/api/auth/verify-email", srv.VerifyEmailHandler).Methods("POST")
+	r.Handle("/api/auth/verify-email/request", srv.Auth.Middleware(http.HandlerFunc(srv.RequestEmailVerificationHandler))).Methods("POST")
+	r.HandleFunc("/api/auth/password-reset", srv.ResetPasswordHandler).Methods("POST")
+	r.HandleFunc("/api/auth/password-reset/request", srv.RequestPasswordResetHandler).Methods("POST")
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+	return r
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// mailedToken returns the token of the most recent link emailed to address.
+func mailedToken(t *testing.T, outbox *notifications.MemoryStore, address string) string {
+	all := outbox.All()
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].To != address {
+			continue
+		}
+		m := linkToken.FindStringSubmatch(all[i].Body)
+		if m == nil {
+			continue
+		}
+		token, _ := url.QueryUnescape(m[1])
+		return token
+	}
+	t.Fatalf("no link emailed to %s", address)
+	return ""
+}
+
+func TestEmailVerificationAndBookingLimit(t *testing.T) {
+	srv := newTestServer()
+	outbox := notifications.NewMemoryStore()
+	srv.Outbox = outbox
+	r := newAccountRouter(srv)
+
+	rr := operatorRequest(r, "POST", "/api/auth/signup", "", map[string]string{"name": "New User", "email": "new@example.com", "password": "secret-pass1"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("signup returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	firstToken := mailedToken(t, outbox, "new@example.com")
+	tok := login(t, r, "new@example.com", "secret-pass1")
+
+	trip, _ := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 3, Seats: []string{"A1", "A2", "A3"}})
+	book := func(seat string) int {
+		return operatorRequest(r, "POST", "/api/bookings", tok.Token, map[string]interface{}{"trip_id": trip.ID, "seats": []string{seat}}).Code
+	}
+	if code := book("A1"); code != http.StatusOK {
+		t.Fatalf("first booking: got status %v want %v", code, http.StatusOK)
+	}
+	if code := book("A2"); code != http.StatusForbidden {
+		t.Errorf("booking beyond the unverified limit: got status %v want %v", code, http.StatusForbidden)
+	}
+
+	// Requesting a new link invalidates the first one
+	rr = operatorRequest(r, "POST", "/api/auth/verify-email/request", tok.Token, nil)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("verification request: got status %v want %v", rr.Code, http.StatusAccepted)
+	}
+	secondToken := mailedToken(t, outbox, "new@example.com")
+	if rr := operatorRequest(r, "POST", "/api/auth/verify-email", "", map[string]string{"token": firstToken}); rr.Code != http.StatusBadRequest {
+		t.Errorf("superseded token: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := operatorRequest(r, "POST", "/api/auth/verify-email", "", map[string]string{"token": secondToken}); rr.Code != http.StatusOK {
+		t.Fatalf("verify: got status %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr := operatorRequest(r, "POST", "/api/auth/verify-email", "", map[string]string{"token": secondToken}); rr.Code != http.StatusBadRequest {
+		t.Errorf("reused token: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	if code := book("A2"); code != http.StatusOK {
+		t.Errorf("booking after verification: got status %v want %v", code, http.StatusOK)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	srv := newTestServer()
+	outbox := notifications.NewMemoryStore()
+	srv.Outbox = outbox
+	r := newAccountRouter(srv)
+	createPasswordUser(t, srv, "forgetful@example.com", "old-password")
+	before := login(t, r, "forgetful@example.com", "old-password")
+
+	// Unknown addresses get the same answer and no email
+	rr := operatorRequest(r, "POST", "/api/auth/password-reset/request", "", map[string]string{"email": "nobody@example.com"})
+	if rr.Code != http.StatusAccepted || len(outbox.All()) != 0 {
+		t.Fatalf("unknown email: got status %v and %d emails", rr.Code, len(outbox.All()))
+	}
+
+	rr = operatorRequest(r, "POST", "/api/auth/password-reset/request", "", map[string]string{"email": "forgetful@example.com"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("reset request: got status %v want %v", rr.Code, http.StatusAccepted)
+	}
+	token := mailedToken(t, outbox, "forgetful@example.com")
+
+	rr = operatorRequest(r, "POST", "/api/auth/password-reset", "", map[string]string{"token": token, "password": "new-password1"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("reset: got status %v want %v", rr.Code, http.StatusOK)
+	}
+	login(t, r, "forgetful@example.com", "new-password1")
+
+	// Existing sessions are logged out and the token cannot be reused
+	if rr := operatorRequest(r, "GET", "/api/sessions", before.Token, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("session from before the reset: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	rr = operatorRequest(r, "POST", "/api/auth/password-reset", "", map[string]string{"token": token, "password": "another-one1"})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("reused reset token: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestExpiredTokenIsRejected(t *testing.T) {
+	srv := newTestServer()
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Late", Email: "late@example.com", Password: "x"})
+	token, hash, _ := auth.NewToken()
+	srv.Store.CreateUserToken(context.Background(), userID, database.TokenVerifyEmail, hash, time.Now().Add(-time.Minute))
+
+	rr := operatorRequest(newAccountRouter(srv), "POST", "/api/auth/verify-email", "", map[string]string{"token": token})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expired token: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
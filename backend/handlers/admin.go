@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/availability"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/metrics"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+	"ticket-booking-app/backend/validate"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pagination reads the page and pageSize query parameters, falling back to
+// the defaults for missing or out-of-range values.
+func pagination(r *http.Request) (page, pageSize int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ = strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+	return page, pageSize
+}
+
+func writePage(w http.ResponseWriter, items interface{}, total, page, pageSize int) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Page{Items: items, Total: total, Page: page, PageSize: pageSize})
+}
+
+// audit records a mutating admin call. A failure to write the audit trail is
+// logged rather than surfaced, since the change itself has already happened.
+func (s *Server) audit(r *http.Request, action, entity string, entityID int, details interface{}) {
+	actorID := 0
+	if claims := auth.ClaimsFromContext(r.Context()); claims != nil {
+		actorID = claims.UserID
+	}
+	if err := s.Store.RecordAudit(r.Context(), actorID, action, entity, entityID, details); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to record audit entry", "action", action, "entity", entity, "entity_id", entityID, "error", err)
+	}
+}
+
+// pathID parses a numeric route variable, writing a 400 if it is malformed.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrInvalidID)
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *Server) AdminListTripsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	operatorID, _ := strconv.Atoi(q.Get("operatorId"))
+	filter := database.TripFilter{From: q.Get("from"), To: q.Get("to"), Date: q.Get("date"), OperatorID: operatorID}
+	page, pageSize := pagination(r)
+
+	trips, total, err := s.Store.ListTrips(r.Context(), filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing trips", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	writePage(w, trips, total, page, pageSize)
+}
+
+func (s *Server) AdminGetTripHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	trip, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trip)
+}
+
+func (s *Server) AdminCreateTripHandler(w http.ResponseWriter, r *http.Request) {
+	var req tripRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	trip := req.trip()
+	if trip.OperatorID != 0 && trip.BusOperator == "" {
+		operator, err := s.Store.GetOperatorByID(r.Context(), trip.OperatorID)
+		if err != nil {
+			apierror.Write(w, r, apierror.ErrOperatorNotFound)
+			return
+		}
+		trip.BusOperator = operator.Name
+	}
+	if trip.SeatsAvailable == 0 {
+		trip.SeatsAvailable = len(trip.Seats)
+	}
+
+	trip, err := s.Store.CreateTrip(r.Context(), trip)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating trip", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	s.audit(r, "trip.create", "trip", trip.ID, trip)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(trip)
+}
+
+func (s *Server) AdminUpdateTripHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req tripRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	trip := req.trip()
+	trip.ID = id
+
+	before, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+	if err := s.Store.UpdateTrip(r.Context(), trip); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating trip", "trip_id", id, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	s.audit(r, "trip.update", "trip", id, trip)
+
+	updated, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	s.rescheduleReminders(r.Context(), before, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (s *Server) AdminDeleteTripHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.Store.DeleteTrip(r.Context(), id); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		case database.ErrTripHasBookings:
+			apierror.Write(w, r, apierror.ErrTripHasBookings)
+		default:
+			serverError(w, r, "Error deleting trip", err)
+		}
+		return
+	}
+	s.audit(r, "trip.delete", "trip", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminAdjustSeatsHandler replaces a trip's list of available seats, e.g. to
+// block seats out of sale or to release them again.
+func (s *Server) AdminAdjustSeatsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	// An empty list of seats takes the whole trip out of sale, so seats is
+	// checked for presence rather than with required.
+	var req struct {
+		Seats  []string `json:"seats" validate:"max=100,unique"`
+		Reason string   `json:"reason" validate:"max=500"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Seats == nil {
+		apierror.Write(w, r, apierror.ErrValidation.WithDetails(validate.Errors{"seats": "is required"}))
+		return
+	}
+
+	before, err := s.Store.AdjustTripSeats(r.Context(), id, req.Seats)
+	if err != nil {
+		var taken *database.SeatsTakenError
+		switch {
+		case err == sql.ErrNoRows:
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		case errors.As(err, &taken):
+			apierror.Write(w, r, apierror.ErrSeatTaken.WithDetails(map[string][]string{"seats": taken.Seats}))
+		default:
+			serverError(w, r, "Error adjusting seats", err)
+		}
+		return
+	}
+	trip, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	s.audit(r, "trip.adjust_seats", "trip", id, map[string]interface{}{
+		"before": before,
+		"after":  req.Seats,
+		"reason": req.Reason,
+	})
+	// Seats dropped from the list are held back from sale, added ones released
+	s.publishSeats(r.Context(), availability.Held, id, takenSeats(req.Seats, before), trip.SeatsAvailable)
+	if released := takenSeats(before, req.Seats); len(released) > 0 {
+		s.publishSeats(r.Context(), availability.Released, id, released, trip.SeatsAvailable)
+		s.offerWaitlist(r.Context(), id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trip)
+}
+
+func (s *Server) AdminListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := pagination(r)
+
+	users, total, err := s.Store.ListUsers(r.Context(), r.URL.Query().Get("q"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing users", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	writePage(w, users, total, page, pageSize)
+}
+
+func (s *Server) AdminListBookingsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, _ := strconv.Atoi(q.Get("userId"))
+	tripID, _ := strconv.Atoi(q.Get("tripId"))
+	filter := database.BookingFilter{UserID: userID, TripID: tripID, Status: q.Get("status")}
+	page, pageSize := pagination(r)
+
+	bookings, total, err := s.Store.ListBookings(r.Context(), filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing bookings", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	writePage(w, bookings, total, page, pageSize)
+}
+
+func (s *Server) AdminGetBookingHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	booking, err := s.Store.GetBookingByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrBookingNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(booking)
+}
+
+func (s *Server) AdminCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req reasonRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	booking, err := s.Store.CancelBooking(r.Context(), id)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			apierror.Write(w, r, apierror.ErrBookingNotFound)
+		case database.ErrBookingAlreadyCancelled:
+			apierror.Write(w, r, apierror.ErrBookingCancelled)
+		default:
+			slog.ErrorContext(r.Context(), "Error cancelling booking", "booking_id", id, "error", err)
+			apierror.Write(w, r, apierror.ErrInternal)
+		}
+		return
+	}
+	metrics.BookingsCancelled(metrics.CancelledByAdmin, 1)
+	s.audit(r, "booking.force_cancel", "booking", id, map[string]interface{}{"reason": req.Reason, "seats": booking.Seats})
+	s.cancelReminders(r.Context(), id)
+
+	if trip, err := s.Store.GetTripByID(r.Context(), booking.TripID); err == nil {
+		s.publishSeats(r.Context(), availability.Released, trip.ID, booking.Seats, trip.SeatsAvailable)
+		s.notifyBooking(r.Context(), booking, trip, notifications.EventBookingCancelled, req.Reason)
+		s.offerWaitlist(r.Context(), trip.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(booking)
+}
+
+func (s *Server) AdminListAuditHandler(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := pagination(r)
+
+	entries, total, err := s.Store.ListAudit(r.Context(), r.URL.Query().Get("entity"), pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing audit log", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	writePage(w, entries, total, page, pageSize)
+}
@@ -0,0 +1,246 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+func newAdminRouter(srv *handlers.Server) *mux.Router {
+	r := mux.NewRouter()
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(srv.Auth.Middleware, auth.RequireRole(auth.RoleAdmin))
+	admin.HandleFunc("/trips", srv.AdminListTripsHandler).Methods("GET")
+	admin.HandleFunc("/trips/{id}", srv.AdminUpdateTripHandler).Methods("PUT")
+	admin.HandleFunc("/trips/{id}", srv.AdminDeleteTripHandler).Methods("DELETE")
+	admin.HandleFunc("/trips/{id}/seats", srv.AdminAdjustSeatsHandler).Methods("PUT")
+	admin.HandleFunc("/users", srv.AdminListUsersHandler).Methods("GET")
+	admin.HandleFunc("/bookings/{id}/cancel", srv.AdminCancelBookingHandler).Methods("POST")
+	admin.HandleFunc("/audit", srv.AdminListAuditHandler).Methods("GET")
+	manageUsers := auth.RequirePermission(auth.PermManageUsers)
+	admin.Handle("/users/{id}/operator", manageUsers(http.HandlerFunc(srv.SetUserOperatorHandler))).Methods("PUT")
+	admin.Handle("/users/{id}/operator", manageUsers(http.HandlerFunc(srv.RemoveUserOperatorHandler))).Methods("DELETE")
+	return r
+}
+
+func createAdmin(t *testing.T, srv *handlers.Server) string {
+	userID, err := srv.Store.CreateUser(context.Background(), models.User{Name: "Admin", Email: "admin@example.com", Password: "x"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := srv.Store.GrantRole(context.Background(), userID, string(auth.RoleAdmin)); err != nil {
+		t.Fatalf("Failed to grant role: %v", err)
+	}
+	token, err := srv.Auth.SignToken(auth.NewClaims(userID, "admin@example.com", []string{string(auth.RoleAdmin)}, 0, 5*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	return token
+}
+
+func TestAdminForceCancelBooking(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	token := createAdmin(t, srv)
+
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Customer", Email: "customer@example.com", Password: "x"})
+	trip, err := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 3, Seats: []string{"A1", "A2", "A3"}})
+	if err != nil {
+		t.Fatalf("Failed to create trip: %v", err)
+	}
+	bookingID, err := srv.Store.CreateBooking(context.Background(), models.Booking{UserID: userID, TripID: trip.ID, Seats: []string{"A1", "A2"}})
+	if err != nil {
+		t.Fatalf("Failed to create booking: %v", err)
+	}
+
+	path := "/api/admin/bookings/" + strconv.Itoa(bookingID) + "/cancel"
+	rr := operatorRequest(r, "POST", path, token, map[string]string{"reason": "duplicate"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	updated, _ := srv.Store.GetTripByID(context.Background(), trip.ID)
+	if updated.SeatsAvailable != 3 || len(updated.Seats) != 3 {
+		t.Errorf("expected seats to be returned to the trip, got %d available %v", updated.SeatsAvailable, updated.Seats)
+	}
+
+	rr = operatorRequest(r, "POST", path, token, nil)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code for second cancel: got %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	rr = operatorRequest(r, "GET", "/api/admin/audit?entity=booking", token, nil)
+	var page struct {
+		Items []models.AuditEntry `json:"items"`
+		Total int                 `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if page.Total != 1 || page.Items[0].Action != "booking.force_cancel" || page.Items[0].EntityID != bookingID {
+		t.Errorf("expected one force_cancel audit entry, got %+v", page)
+	}
+}
+
+func TestAdminListUsersPagination(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	token := createAdmin(t, srv)
+
+	for i := 0; i < 5; i++ {
+		srv.Store.CreateUser(context.Background(), models.User{Name: "Passenger " + strconv.Itoa(i), Email: "p" + strconv.Itoa(i) + "@example.com", Password: "x"})
+	}
+
+	rr := operatorRequest(r, "GET", "/api/admin/users?q=passenger&page=2&pageSize=2", token, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var page struct {
+		Items    []models.User `json:"items"`
+		Total    int           `json:"total"`
+		Page     int           `json:"page"`
+		PageSize int           `json:"pageSize"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if page.Total != 5 || len(page.Items) != 2 || page.Page != 2 || page.Items[0].Name != "Passenger 2" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+}
+
+func TestAdminAttachesOperatorStaff(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	token := createAdmin(t, srv)
+
+	ctx := context.Background()
+	operatorID, _ := srv.Store.CreateOperator(ctx, "Selam Bus")
+	userID, _ := srv.Store.CreateUser(ctx, models.User{Name: "Staff", Email: "staff@selam.example.com", Password: "x"})
+	path := "/api/admin/users/" + strconv.Itoa(userID) + "/operator"
+
+	if rr := operatorRequest(r, "PUT", path, token, map[string]int{"operatorId": operatorID + 1}); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown operator: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := operatorRequest(r, "PUT", "/api/admin/users/999/operator", token, map[string]int{"operatorId": operatorID}); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown user: got status %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr := operatorRequest(r, "PUT", path, token, map[string]int{"operatorId": operatorID})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("attach: got %d %s", rr.Code, rr.Body)
+	}
+	user, _ := srv.Store.GetUserByID(ctx, userID)
+	roles, _ := srv.Store.GetUserRoles(ctx, userID)
+	if user.OperatorID != operatorID || len(roles) != 1 || roles[0] != string(auth.RoleOperator) {
+		t.Errorf("expected operator staff, got operator %d roles %v", user.OperatorID, roles)
+	}
+	entries, _, _ := srv.Store.ListAudit(ctx, "user", 10, 0)
+	if len(entries) != 1 || entries[0].Action != "user.set_operator" || entries[0].EntityID != userID {
+		t.Errorf("expected one set_operator audit entry, got %+v", entries)
+	}
+
+	if rr := operatorRequest(r, "DELETE", path, token, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("detach: got %d %s", rr.Code, rr.Body)
+	}
+	user, _ = srv.Store.GetUserByID(ctx, userID)
+	roles, _ = srv.Store.GetUserRoles(ctx, userID)
+	if user.OperatorID != 0 || len(roles) != 0 {
+		t.Errorf("expected a plain account, got operator %d roles %v", user.OperatorID, roles)
+	}
+}
+
+func TestAdminDeleteTripWithBookings(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	token := createAdmin(t, srv)
+
+	ctx := context.Background()
+	userID, _ := srv.Store.CreateUser(ctx, models.User{Name: "Customer", Email: "customer@example.com", Password: "x"})
+	booked, _ := srv.Store.CreateTrip(ctx, models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 1, Seats: []string{"A1"}})
+	empty, _ := srv.Store.CreateTrip(ctx, models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-02", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 1, Seats: []string{"A1"}})
+	if _, err := srv.Store.CreateBooking(ctx, models.Booking{UserID: userID, TripID: booked.ID, Seats: []string{"A1"}}); err != nil {
+		t.Fatalf("Failed to create booking: %v", err)
+	}
+
+	if rr := operatorRequest(r, "DELETE", "/api/admin/trips/"+strconv.Itoa(booked.ID), token, nil); rr.Code != http.StatusConflict {
+		t.Errorf("deleting a booked trip: got status %v want %v", rr.Code, http.StatusConflict)
+	}
+	if rr := operatorRequest(r, "DELETE", "/api/admin/trips/"+strconv.Itoa(empty.ID), token, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("deleting an empty trip: got status %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := operatorRequest(r, "DELETE", "/api/admin/trips/"+strconv.Itoa(empty.ID), token, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("deleting a deleted trip: got status %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestAdminAdjustSeatsIsAudited(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	token := createAdmin(t, srv)
+
+	trip, _ := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 2, Seats: []string{"A1", "A2"}})
+
+	rr := operatorRequest(r, "PUT", "/api/admin/trips/"+strconv.Itoa(trip.ID)+"/seats", token, map[string]interface{}{"seats": []string{"A1"}, "reason": "broken seat"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	updated, _ := srv.Store.GetTripByID(context.Background(), trip.ID)
+	if updated.SeatsAvailable != 1 {
+		t.Errorf("expected 1 seat available, got %d", updated.SeatsAvailable)
+	}
+
+	entries, total, err := srv.Store.ListAudit(context.Background(), "trip", 10, 0)
+	if err != nil || total != 1 || entries[0].Action != "trip.adjust_seats" {
+		t.Errorf("expected one adjust_seats audit entry, got %v (%v)", entries, err)
+	}
+}
+
+func TestAdminAdjustSeatsKeepsBookedSeatsOffSale(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	token := createAdmin(t, srv)
+
+	ctx := context.Background()
+	userID, _ := srv.Store.CreateUser(ctx, models.User{Name: "Customer", Email: "customer@example.com", Password: "x"})
+	trip, _ := srv.Store.CreateTrip(ctx, models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 3, Seats: []string{"A1", "A2", "A3"}})
+	if _, err := srv.Store.CreateBooking(ctx, models.Booking{UserID: userID, TripID: trip.ID, Seats: []string{"A1"}}); err != nil {
+		t.Fatalf("Failed to create booking: %v", err)
+	}
+
+	path := "/api/admin/trips/" + strconv.Itoa(trip.ID) + "/seats"
+	rr := operatorRequest(r, "PUT", path, token, map[string]interface{}{"seats": []string{"A1", "A2", "A3"}})
+	var resp struct {
+		Error struct {
+			Code    string              `json:"code"`
+			Details map[string][]string `json:"details"`
+		} `json:"error"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusConflict || resp.Error.Code != "seat_taken" || len(resp.Error.Details["seats"]) != 1 || resp.Error.Details["seats"][0] != "A1" {
+		t.Errorf("releasing a booked seat: got %d %s", rr.Code, rr.Body)
+	}
+	if updated, _ := srv.Store.GetTripByID(ctx, trip.ID); updated.SeatsAvailable != 2 || len(updated.Seats) != 2 {
+		t.Errorf("expected the seats unchanged, got %d available %v", updated.SeatsAvailable, updated.Seats)
+	}
+
+	// Blocking a free seat still works, and the count follows the seats
+	rr = operatorRequest(r, "PUT", path, token, map[string]interface{}{"seats": []string{"A3"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("blocking a seat: got %d %s", rr.Code, rr.Body)
+	}
+	if updated, _ := srv.Store.GetTripByID(ctx, trip.ID); updated.SeatsAvailable != 1 || len(updated.Seats) != 1 {
+		t.Errorf("expected one seat on sale, got %d available %v", updated.SeatsAvailable, updated.Seats)
+	}
+}
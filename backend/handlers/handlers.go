@@ -3,109 +3,125 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
-	"ticket-booking-app/backend/config"
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/availability"
 	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/metrics"
 	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+	"ticket-booking-app/backend/validate"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func SignupHandler(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func (s *Server) SignupHandler(w http.ResponseWriter, r *http.Request) {
+	var req signupRequest
+	if !decode(w, r, &req) {
 		return
 	}
+	user := models.User{Name: strings.TrimSpace(req.Name), Email: req.Email, Password: req.Password, Language: req.Language}
 
 	// Check if user already exists
-	_, err = database.GetUserByEmail(user.Email)
+	_, err := s.Store.GetUserByEmail(r.Context(), user.Email)
 	if err == nil {
-		http.Error(w, "User already exists", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.ErrUserExists)
 		return
 	} else if err != sql.ErrNoRows {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		serverError(w, r, "Database error", err)
 		return
 	}
 
+	if user.Language != "am" {
+		user.Language = notifications.DefaultLanguage
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		serverError(w, r, "Failed to hash password", err)
 		return
 	}
 	user.Password = string(hashedPassword)
 
-	_, err = database.CreateUser(user)
+	userID, err := s.Store.CreateUser(r.Context(), user)
+	if err != nil {
+		serverError(w, r, "Failed to register user", err)
+		return
+	}
+
+	err = s.Store.GrantRole(r.Context(), userID, string(auth.RoleCustomer))
 	if err != nil {
-		http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		serverError(w, r, "Failed to register user", err)
 		return
 	}
 
+	user.ID = userID
+	if err := s.sendVerificationEmail(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending verification email", "user_id", userID, "error", err)
+	}
+
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "User registered successfully"})
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	var creds models.User
-	err := json.NewDecoder(r.Body).Decode(&creds)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var creds loginRequest
+	if !decode(w, r, &creds) {
 		return
 	}
 
-	user, err := database.GetUserByEmail(creds.Email)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusUnauthorized)
-		} else {
-			http.Error(w, "Database error", http.StatusInternalServerError)
-		}
+	// Unknown addresses go through the same limits, lockout and password
+	// hashing as registered ones, so that neither the answer nor its timing
+	// tells them apart.
+	if !s.Limiter.AllowAccount(w, r, lockoutKey(creds.Email)) || s.loginLocked(w, r, creds.Email) {
 		return
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(creds.Password))
-	if err != nil {
-		http.Error(w, "Invalid password", http.StatusUnauthorized)
+	user, err := s.Store.GetUserByEmail(r.Context(), creds.Email)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, "Database error", err)
 		return
 	}
-
-	// Create token
-	expirationTime := time.Now().Add(5 * time.Minute)
-	claims := &jwt.StandardClaims{
-		Subject:   user.Email,
-		ExpiresAt: expirationTime.Unix(),
+	if !checkPassword(user.Password, creds.Password) {
+		s.loginFailed(w, r, creds.Email)
+		return
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(config.JWTKey)
-	if err != nil {
-		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+	if err := s.Store.ClearLoginFailures(r.Context(), lockoutKey(creds.Email)); err != nil {
+		serverError(w, r, "Database error", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString, "name": user.Name})
+	s.completeLogin(w, r, user)
 }
 
-func SearchTripsHandler(w http.ResponseWriter, r *http.Request) {
-	from := r.URL.Query().Get("from")
-	to := r.URL.Query().Get("to")
-	date := r.URL.Query().Get("date")
-	flexibleDateRange, _ := strconv.Atoi(r.URL.Query().Get("flexibleDateRange")) // Not used in DB query yet
-	currency := r.URL.Query().Get("currency")                                    // Handled on frontend
+func (s *Server) SearchTripsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := searchRequest{From: query.Get("from"), To: query.Get("to"), Date: query.Get("date")}
+	if v := query.Get("flexibleDateRange"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			apierror.Write(w, r, apierror.ErrValidation.WithDetails(validate.Errors{"flexibleDateRange": "must be a number"}))
+			return
+		}
+		search.FlexibleDateRange = n
+	}
+	if errs := validate.Struct(search); errs != nil {
+		apierror.Write(w, r, apierror.ErrValidation.WithDetails(errs))
+		return
+	}
+	currency := query.Get("currency") // Handled on frontend
 
-	trips, err := database.SearchTrips(from, to, date, flexibleDateRange)
+	trips, err := s.Store.SearchTrips(r.Context(), search.From, search.To, search.Date, search.FlexibleDateRange)
 	if err != nil {
-		log.Printf("Error searching trips: %v", err)
-		http.Error(w, "Failed to search trips", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error searching trips", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
 		return
 	}
 
@@ -124,20 +140,20 @@ func SearchTripsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(trips)
 }
 
-func GetTripByIDHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetTripByIDHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid trip ID", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.ErrInvalidID)
 		return
 	}
 
-	trip, err := database.GetTripByID(id)
+	trip, err := s.Store.GetTripByID(r.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.NotFound(w, r)
+			apierror.Write(w, r, apierror.ErrTripNotFound)
 		} else {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			serverError(w, r, "Database error", err)
 		}
 		return
 	}
@@ -146,69 +162,127 @@ func GetTripByIDHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(trip)
 }
 
-func CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
-	var booking models.Booking
-	err := json.NewDecoder(r.Body).Decode(&booking)
-	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func (s *Server) CreateBookingHandler(w http.ResponseWriter, r *http.Request) {
+	var req bookingRequest
+	if !decode(w, r, &req) {
 		return
 	}
+	booking := models.Booking{TripID: req.TripID, Seats: req.Seats}
 
 	// Get user from token
-	claims := r.Context().Value("claims").(*jwt.StandardClaims)
-	user, err := database.GetUserByEmail(claims.Subject)
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+		apierror.Write(w, r, apierror.ErrUnknownUser)
 		return
 	}
 
-	booking.UserID = user.ID
-	bookingID, err := database.CreateBooking(booking)
-	if err != nil {
-		http.Error(w, "Failed to create booking", http.StatusInternalServerError)
+	if !s.mayBook(w, r, user) {
 		return
 	}
-	booking.ID = bookingID
 
-	// Update trip seats
-	trip, err := database.GetTripByID(booking.TripID)
+	trip, err := s.Store.GetTripByID(r.Context(), booking.TripID)
 	if err != nil {
-		http.Error(w, "Trip not found for seat update", http.StatusInternalServerError)
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+	if !trip.Status.Bookable() {
+		apierror.Write(w, r, apierror.ErrTripNotBookable)
+		return
+	}
+	if taken := takenSeats(trip.Seats, booking.Seats); len(taken) > 0 {
+		apierror.Write(w, r, apierror.ErrSeatTaken.WithDetails(map[string][]string{"seats": taken}))
 		return
 	}
 
-	var newSeats []string
-	for _, seat := range trip.Seats {
-		isBooked := false
-		for _, bookedSeat := range booking.Seats {
-			if seat == bookedSeat {
-				isBooked = true
-				break
+	booking.UserID = user.ID
+	bookingID, err := s.Store.CreateBooking(r.Context(), booking)
+	if err != nil {
+		switch err {
+		case database.ErrSeatTaken:
+			// Taken by a booking made since the trip was read
+			taken := booking.Seats
+			if current, err := s.Store.GetTripByID(r.Context(), trip.ID); err == nil {
+				taken = takenSeats(current.Seats, booking.Seats)
 			}
+			apierror.Write(w, r, apierror.ErrSeatTaken.WithDetails(map[string][]string{"seats": taken}))
+		case sql.ErrNoRows:
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		case database.ErrTripNotBookable:
+			apierror.Write(w, r, apierror.ErrTripNotBookable)
+		default:
+			serverError(w, r, "Failed to create booking", err)
 		}
-		if !isBooked {
-			newSeats = append(newSeats, seat)
-		}
+		return
 	}
+	booking.ID = bookingID
+	metrics.BookingCreated(len(booking.Seats))
 
-	err = database.UpdateTripSeats(trip.ID, newSeats, trip.SeatsAvailable-len(booking.Seats))
-	if err != nil {
-		http.Error(w, "Failed to update trip seats", http.StatusInternalServerError)
-		return
+	available := trip.SeatsAvailable - len(booking.Seats)
+	if updated, err := s.Store.GetTripByID(r.Context(), trip.ID); err == nil {
+		available = updated.SeatsAvailable
 	}
+	s.publishSeats(r.Context(), availability.Booked, trip.ID, booking.Seats, available)
+
+	data := tripData(trip)
+	data.BookingID = booking.ID
+	data.Seats = booking.Seats
+	s.notify(r.Context(), user, notifications.EventBookingConfirmed, data)
+	s.scheduleReminders(r.Context(), booking, trip)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Booking created successfully", "booking": booking})
 }
 
-func GetProfileHandler(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value("claims").(*jwt.StandardClaims)
-	user, bookings, err := database.GetUserProfile(claims.Subject)
+// mayBook enforces the limit on active bookings of unverified accounts. It
+// writes the error response and returns false if user cannot book more.
+func (s *Server) mayBook(w http.ResponseWriter, r *http.Request, user models.User) bool {
+	if user.Verified() {
+		return true
+	}
+	active, err := s.Store.CountActiveBookings(r.Context(), user.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return false
+	}
+	if active >= s.App.UnverifiedBookingLimit {
+		apierror.Write(w, r, apierror.ErrVerificationRequired)
+		return false
+	}
+	return true
+}
+
+// takenSeats returns the requested seats that are not among the trip's free
+// seats.
+func takenSeats(free, requested []string) []string {
+	var taken []string
+	for _, seat := range requested {
+		isFree := false
+		for _, f := range free {
+			if seat == f {
+				isFree = true
+				break
+			}
+		}
+		if !isFree {
+			taken = append(taken, seat)
+		}
+	}
+	return taken
+}
+
+func (s *Server) GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromContext(r.Context())
+	user, bookings, err := s.Store.GetUserProfile(r.Context(), claims.UserID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusNotFound)
+			apierror.Write(w, r, apierror.ErrUserNotFound)
 		} else {
-			http.Error(w, "Database error", http.StatusInternalServerError)
+			serverError(w, r, "Database error", err)
 		}
 		return
 	}
@@ -2,12 +2,16 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,44 +19,63 @@ import (
 	"ticket-booking-app/backend/config"
 	"ticket-booking-app/backend/database"
 	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/idempotency"
+	"ticket-booking-app/backend/migrations"
 	"ticket-booking-app/backend/models"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func generateTestToken(email string) (string, error) {
-	expirationTime := time.Now().Add(5 * time.Minute)
-	claims := &jwt.StandardClaims{
-		Subject:   email,
-		ExpiresAt: expirationTime.Unix(),
+func generateTestToken(srv *handlers.Server, userID int, email string) (string, error) {
+	claims := auth.NewClaims(userID, email, []string{string(auth.RoleCustomer)}, 0, 5*time.Minute)
+	return srv.Auth.SignToken(claims)
+}
+
+// testDBConfig connects to the server configured by the DB_* environment
+// variables, the local one by default.
+func testDBConfig(name string) config.DatabaseConfig {
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalf("Invalid test database config: %v", err)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(config.JWTKey)
+	return cfg.Database.WithName(name)
 }
 
-func setupTestDB() {
-	// Set environment variable for test database
-	os.Setenv("TEST_DB_NAME", "ticket_booking_test")
+// newTestServer returns a Server on an empty in-memory store. With
+// TEST_DATABASE=postgres it runs on a freshly migrated PostgreSQL database
+// instead, to check the queries as well.
+func newTestServer() *handlers.Server {
+	var store database.Store = database.NewMemoryStore()
+	if os.Getenv("TEST_DATABASE") == "postgres" {
+		store = setupTestDB()
+	}
+	authn, err := auth.New(config.Default().Auth, store)
+	if err != nil {
+		log.Fatalf("Invalid test auth config: %v", err)
+	}
+	return handlers.NewServer(store, authn, config.Default().App)
+}
 
+func setupTestDB() *database.PostgresStore {
 	// Drop and create the test database to ensure a clean state
 	dropTestDB()
 	createTestDB()
 
 	// Initialize the database connection
-	database.InitDB()
-
-	// Run init.sql to create tables in the test database
-	runInitSQL()
+	db, err := database.Open(testDBConfig("ticket_booking_test"))
+	if err != nil {
+		log.Fatalf("Could not connect to test db: %v", err)
+	}
 
-	// Clear tables before each test run (important for repeated test runs)
-	clearTestDB()
+	// Build the schema the same way production does
+	runMigrations(db)
+	return database.NewPostgresStore(db)
 }
 
 func dropTestDB() {
 	// Connect to default postgres database to drop the test database
-	db, err := database.ConnectToPostgres("postgres") // Assuming a function to connect to a specific db
+	db, err := database.Open(testDBConfig("postgres"))
 	if err != nil {
 		// Log error but don't fatal, as db might not exist
 		return
@@ -63,7 +86,7 @@ func dropTestDB() {
 
 func createTestDB() {
 	// Connect to default postgres database to create the test database
-	db, err := database.ConnectToPostgres("postgres") // Assuming a function to connect to a specific db
+	db, err := database.Open(testDBConfig("postgres"))
 	if err != nil {
 		log.Fatalf("Could not connect to postgres to create test db: %v", err)
 	}
@@ -71,39 +94,23 @@ func createTestDB() {
 	db.Exec("CREATE DATABASE ticket_booking_test")
 }
 
-func runInitSQL() {
-	// Connect to the test database and run init.sql
-	db, err := database.ConnectToPostgres("ticket_booking_test") // Assuming a function to connect to a specific db
-	if err != nil {
-		log.Fatalf("Could not connect to test db to run init.sql: %v", err)
-	}
-	defer db.Close()
-
-	sqlFile, err := os.ReadFile("../database/init.sql")
+func runMigrations(db *sql.DB) {
+	m, err := migrations.New(db)
 	if err != nil {
-		log.Fatalf("Could not read init.sql: %v", err)
+		log.Fatalf("Could not load migrations: %v", err)
 	}
-
-	_, err = db.Exec(string(sqlFile))
-	if err != nil {
-		log.Fatalf("Could not execute init.sql: %v", err)
+	if _, err := m.Up(context.Background()); err != nil {
+		log.Fatalf("Could not migrate test db: %v", err)
 	}
 }
 
-func clearTestDB() {
-	// Clear tables before each test
-	database.DB.Exec("DELETE FROM users")
-	database.DB.Exec("DELETE FROM trips")
-	database.DB.Exec("DELETE FROM bookings")
-}
-
 func TestSignupHandler(t *testing.T) {
-	setupTestDB()
+	srv := newTestServer()
 
-	user := models.User{
-		Name:     "Test User",
-		Email:    "test@example.com",
-		Password: "password123",
+	user := map[string]string{
+		"name":     "Test User",
+		"email":    "test@example.com",
+		"password": "password123",
 	}
 	jsonUser, _ := json.Marshal(user)
 
@@ -115,7 +122,7 @@ func TestSignupHandler(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	r := mux.NewRouter()
-	r.HandleFunc("/api/auth/signup", handlers.SignupHandler).Methods("POST")
+	r.HandleFunc("/api/auth/signup", srv.SignupHandler).Methods("POST")
 	r.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -147,13 +154,13 @@ func TestSignupHandler(t *testing.T) {
 }
 
 func TestLoginHandler(t *testing.T) {
-	setupTestDB()
+	srv := newTestServer()
 
 	// First, register a user to log in with
-	user := models.User{
-		Name:     "Login User",
-		Email:    "login@example.com",
-		Password: "loginpassword",
+	user := map[string]string{
+		"name":     "Login User",
+		"email":    "login@example.com",
+		"password": "login-password",
 	}
 	jsonUser, _ := json.Marshal(user)
 
@@ -161,13 +168,13 @@ func TestLoginHandler(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
 	r := mux.NewRouter()
-	r.HandleFunc("/api/auth/signup", handlers.SignupHandler).Methods("POST")
+	r.HandleFunc("/api/auth/signup", srv.SignupHandler).Methods("POST")
 	r.ServeHTTP(rr, req)
 
 	// Now, attempt to log in
 	loginCreds := map[string]string{
 		"email":    "login@example.com",
-		"password": "loginpassword",
+		"password": "login-password",
 	}
 	jsonCreds, _ := json.Marshal(loginCreds)
 
@@ -178,7 +185,7 @@ func TestLoginHandler(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr = httptest.NewRecorder()
-	r.HandleFunc("/api/auth/login", handlers.LoginHandler).Methods("POST")
+	r.HandleFunc("/api/auth/login", srv.LoginHandler).Methods("POST")
 	r.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -186,7 +193,7 @@ func TestLoginHandler(t *testing.T) {
 			status, http.StatusOK)
 	}
 
-	var responseMap map[string]string
+	var responseMap map[string]interface{}
 	err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
 	if err != nil {
 		t.Fatalf("could not unmarshal response: %v", err)
@@ -225,14 +232,14 @@ func TestLoginHandler(t *testing.T) {
 }
 
 func TestSearchTripsHandler(t *testing.T) {
-	setupTestDB()
+	srv := newTestServer()
 
 	// Insert some dummy trip data
 	trip1 := models.Trip{
 		From:           "Addis Ababa",
 		To:             "Adama",
 		Date:           "2025-08-20",
-		Time:           "10:00:00",
+		DepartureTime:  "10:00:00",
 		Price:          100.0,
 		SeatsAvailable: 50,
 		Seats:          []string{"A1", "A2", "A3"},
@@ -241,7 +248,7 @@ func TestSearchTripsHandler(t *testing.T) {
 		From:           "Addis Ababa",
 		To:             "Hawassa",
 		Date:           "2025-08-20",
-		Time:           "12:00:00",
+		DepartureTime:  "12:00:00",
 		Price:          200.0,
 		SeatsAvailable: 40,
 		Seats:          []string{"B1", "B2", "B3"},
@@ -250,14 +257,14 @@ func TestSearchTripsHandler(t *testing.T) {
 		From:           "Adama",
 		To:             "Addis Ababa",
 		Date:           "2025-08-21",
-		Time:           "14:00:00",
+		DepartureTime:  "14:00:00",
 		Price:          150.0,
 		SeatsAvailable: 30,
 		Seats:          []string{"C1", "C2", "C3"},
 	}
-	database.CreateTrip(trip1)
-	database.CreateTrip(trip2)
-	database.CreateTrip(trip3)
+	srv.Store.CreateTrip(context.Background(), trip1)
+	srv.Store.CreateTrip(context.Background(), trip2)
+	srv.Store.CreateTrip(context.Background(), trip3)
 
 	// Test case 1: Search with 'from' and 'to'
 	req, err := http.NewRequest("GET", "/api/trips/search?from=Addis Ababa&to=Adama&date=2025-08-20", nil)
@@ -266,7 +273,7 @@ func TestSearchTripsHandler(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 	r := mux.NewRouter()
-	r.HandleFunc("/api/trips/search", handlers.SearchTripsHandler).Methods("GET")
+	r.HandleFunc("/api/trips/search", srv.SearchTripsHandler).Methods("GET")
 	r.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -333,19 +340,19 @@ func TestSearchTripsHandler(t *testing.T) {
 }
 
 func TestGetTripByIDHandler(t *testing.T) {
-	setupTestDB()
+	srv := newTestServer()
 
 	// Insert a dummy trip
 	trip := models.Trip{
 		From:           "Addis Ababa",
 		To:             "Adama",
 		Date:           "2025-08-20",
-		Time:           "10:00:00",
+		DepartureTime:  "10:00:00",
 		Price:          100.0,
 		SeatsAvailable: 50,
 		Seats:          []string{"A1", "A2", "A3"},
 	}
-	createdTrip, err := database.CreateTrip(trip)
+	createdTrip, err := srv.Store.CreateTrip(context.Background(), trip)
 	if err != nil {
 		t.Fatalf("Failed to create trip: %v", err)
 	}
@@ -357,7 +364,7 @@ func TestGetTripByIDHandler(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 	r := mux.NewRouter()
-	r.HandleFunc("/api/trips/{id}", handlers.GetTripByIDHandler).Methods("GET")
+	r.HandleFunc("/api/trips/{id}", srv.GetTripByIDHandler).Methods("GET")
 	r.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -390,7 +397,7 @@ func TestGetTripByIDHandler(t *testing.T) {
 }
 
 func TestCreateBookingHandler(t *testing.T) {
-	setupTestDB()
+	srv := newTestServer()
 
 	// 1. Create a user
 	user := models.User{
@@ -400,37 +407,40 @@ func TestCreateBookingHandler(t *testing.T) {
 	}
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	user.Password = string(hashedPassword)
-	userID, err := database.CreateUser(user)
+	userID, err := srv.Store.CreateUser(context.Background(), user)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
 	user.ID = userID
+	// Verified, so that the unverified booking limit does not mask the
+	// invalid trip case below
+	srv.Store.SetEmailVerified(context.Background(), userID)
 
 	// 2. Create a trip
 	trip := models.Trip{
 		From:           "Addis Ababa",
 		To:             "Adama",
 		Date:           "2025-09-01",
-		Time:           "10:00:00",
+		DepartureTime:  "10:00:00",
 		Price:          100.0,
 		SeatsAvailable: 3,
 		Seats:          []string{"A1", "A2", "A3", "A4", "A5"},
 	}
-	createdTrip, err := database.CreateTrip(trip)
+	createdTrip, err := srv.Store.CreateTrip(context.Background(), trip)
 	if err != nil {
 		t.Fatalf("Failed to create trip: %v", err)
 	}
 
 	// 3. Generate a JWT token for the user
-	tokenString, err := generateTestToken(user.Email)
+	tokenString, err := generateTestToken(srv, user.ID, user.Email)
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
 
 	// 4. Create a booking payload
-	booking := models.Booking{
-		TripID: createdTrip.ID,
-		Seats:  []string{"A1", "A2"},
+	booking := map[string]interface{}{
+		"trip_id": createdTrip.ID,
+		"seats":   []string{"A1", "A2"},
 	}
 	jsonBooking, _ := json.Marshal(booking)
 
@@ -444,7 +454,7 @@ func TestCreateBookingHandler(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	r := mux.NewRouter()
-	r.Handle("/api/bookings", auth.Middleware(http.HandlerFunc(handlers.CreateBookingHandler))).Methods("POST")
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
 	r.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -452,7 +462,7 @@ func TestCreateBookingHandler(t *testing.T) {
 			status, http.StatusOK)
 	}
 
-	var responseMap map[string]string
+	var responseMap map[string]interface{}
 	err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
 	if err != nil {
 		t.Fatalf("could not unmarshal response: %v", err)
@@ -464,7 +474,7 @@ func TestCreateBookingHandler(t *testing.T) {
 	}
 
 	// Verify trip seats updated
-	updatedTrip, err := database.GetTripByID(createdTrip.ID)
+	updatedTrip, err := srv.Store.GetTripByID(context.Background(), createdTrip.ID)
 	if err != nil {
 		t.Fatalf("Failed to get updated trip: %v", err)
 	}
@@ -473,7 +483,7 @@ func TestCreateBookingHandler(t *testing.T) {
 	}
 
 	// Test case 2: Booking with invalid trip ID
-	booking.TripID = 9999
+	booking["trip_id"] = 9999
 	jsonBooking, _ = json.Marshal(booking)
 	req, err = http.NewRequest("POST", "/api/bookings", bytes.NewBuffer(jsonBooking))
 	if err != nil {
@@ -484,12 +494,39 @@ func TestCreateBookingHandler(t *testing.T) {
 	rr = httptest.NewRecorder()
 	r.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusInternalServerError {
+	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code for invalid trip ID: got %v want %v",
-			status, http.StatusInternalServerError)
+			status, http.StatusNotFound)
+	}
+
+	// Test case 3: A seat that is already taken
+	booking["trip_id"] = createdTrip.ID
+	booking["seats"] = []string{"A2", "A3"}
+	jsonBooking, _ = json.Marshal(booking)
+	req, _ = http.NewRequest("POST", "/api/bookings", bytes.NewBuffer(jsonBooking))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code for a taken seat: got %v want %v",
+			status, http.StatusConflict)
+	}
+	var errBody struct {
+		Error struct {
+			Code    string              `json:"code"`
+			Details map[string][]string `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &errBody); err != nil {
+		t.Fatalf("could not unmarshal error: %v", err)
+	}
+	if errBody.Error.Code != "seat_taken" || len(errBody.Error.Details["seats"]) != 1 || errBody.Error.Details["seats"][0] != "A2" {
+		t.Errorf("unexpected error for a taken seat: %+v", errBody.Error)
 	}
 
-	// Test case 3: Unauthorized access (no token)
+	// Test case 4: Unauthorized access (no token)
 	req, err = http.NewRequest("POST", "/api/bookings", bytes.NewBuffer(jsonBooking))
 	if err != nil {
 		t.Fatal(err)
@@ -504,8 +541,167 @@ func TestCreateBookingHandler(t *testing.T) {
 	}
 }
 
+func TestRetriedBookingIsNotDuplicated(t *testing.T) {
+	srv := newTestServer()
+	srv.Idempotency = &idempotency.Keys{Store: idempotency.NewMemoryStore(), TTL: time.Hour}
+	r := mux.NewRouter()
+	r.Handle("/api/bookings", srv.Auth.Middleware(srv.Idempotency.Middleware(http.HandlerFunc(srv.CreateBookingHandler)))).Methods("POST")
+
+	userID, err := srv.Store.CreateUser(context.Background(), models.User{Name: "Retry User", Email: "retry@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	trip, _ := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 3, Seats: []string{"A1", "A2", "A3"}})
+	token, err := generateTestToken(srv, userID, "retry@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	book := func(key string, seats ...string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"trip_id": trip.ID, "seats": seats})
+		req := httptest.NewRequest("POST", "/api/bookings", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(idempotency.Header, key)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := book("tap-1", "A1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("first attempt: got %d %s", first.Code, first.Body)
+	}
+	retry := book("tap-1", "A1")
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Errorf("retry: got %d %s", retry.Code, retry.Body)
+	}
+	if active, _ := srv.Store.CountActiveBookings(context.Background(), userID); active != 1 {
+		t.Errorf("expected one booking, got %d", active)
+	}
+	if rr := book("tap-1", "A2"); rr.Code != http.StatusConflict {
+		t.Errorf("key reused for other seats: got %d", rr.Code)
+	}
+}
+
+func TestConcurrentBookingsOfOneSeat(t *testing.T) {
+	srv := newTestServer()
+	r := mux.NewRouter()
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+
+	ctx := context.Background()
+	trip, _ := srv.Store.CreateTrip(ctx, models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 2, Seats: []string{"A1", "A2"}})
+	var tokens []string
+	for i := 0; i < 8; i++ {
+		email := "racer" + strconv.Itoa(i) + "@example.com"
+		userID, err := srv.Store.CreateUser(ctx, models.User{Name: "Racer", Email: email, Password: "x"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		token, _ := generateTestToken(srv, userID, email)
+		tokens = append(tokens, token)
+	}
+
+	codes := make([]int, len(tokens))
+	var wg sync.WaitGroup
+	for i, token := range tokens {
+		wg.Add(1)
+		go func(i int, token string) {
+			defer wg.Done()
+			body, _ := json.Marshal(map[string]interface{}{"trip_id": trip.ID, "seats": []string{"A1"}})
+			req := httptest.NewRequest("POST", "/api/bookings", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			codes[i] = rr.Code
+		}(i, token)
+	}
+	wg.Wait()
+
+	booked := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			booked++
+		case http.StatusConflict:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if booked != 1 {
+		t.Errorf("expected exactly one booking of the seat, got %d", booked)
+	}
+	if updated, _ := srv.Store.GetTripByID(ctx, trip.ID); updated.SeatsAvailable != 1 || len(updated.Seats) != 1 || updated.Seats[0] != "A2" {
+		t.Errorf("expected only A2 left, got %d available %v", updated.SeatsAvailable, updated.Seats)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	srv := newTestServer()
+	r := mux.NewRouter()
+	r.HandleFunc("/api/auth/signup", srv.SignupHandler).Methods("POST")
+	r.HandleFunc("/api/trips/search", srv.SearchTripsHandler).Methods("GET")
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+	token, err := generateTestToken(srv, 1, "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	send := func(method, path, token, body string) (int, string, map[string]string) {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		var resp struct {
+			Error struct {
+				Code    string            `json:"code"`
+				Details map[string]string `json:"details"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp.Error.Code, resp.Error.Details
+	}
+
+	cases := []struct {
+		name          string
+		method, path  string
+		token, body   string
+		status        int
+		code          string
+		invalidFields []string
+	}{
+		{"empty signup", "POST", "/api/auth/signup", "", ``, http.StatusBadRequest, "validation_failed", []string{"name", "email", "password"}},
+		{"bad signup fields", "POST", "/api/auth/signup", "", `{"name":"A","email":"not-an-email","password":"x"}`, http.StatusBadRequest, "validation_failed", []string{"email", "password"}},
+		{"weak password", "POST", "/api/auth/signup", "", `{"name":"A","email":"a@example.com","password":"password"}`, http.StatusBadRequest, "validation_failed", []string{"password"}},
+		{"unknown field", "POST", "/api/auth/signup", "", `{"name":"A","email":"a@example.com","password":"hunter22","role":"admin"}`, http.StatusBadRequest, "validation_failed", []string{"role"}},
+		{"wrong type", "POST", "/api/auth/signup", "", `{"name":1,"email":"a@example.com","password":"hunter22"}`, http.StatusBadRequest, "validation_failed", []string{"name"}},
+		{"trailing data", "POST", "/api/auth/signup", "", `{"name":"A","email":"a@example.com","password":"hunter22"} {}`, http.StatusBadRequest, "invalid_request_body", nil},
+		{"oversize body", "POST", "/api/auth/signup", "", `{"name":"` + strings.Repeat("A", 100<<10) + `"}`, http.StatusRequestEntityTooLarge, "request_too_large", nil},
+		{"no seats", "POST", "/api/bookings", token, `{"trip_id":1,"seats":[]}`, http.StatusBadRequest, "validation_failed", []string{"seats"}},
+		{"duplicate seats", "POST", "/api/bookings", token, `{"trip_id":1,"seats":["A1","A1"]}`, http.StatusBadRequest, "validation_failed", []string{"seats"}},
+		{"no trip", "POST", "/api/bookings", token, `{"seats":["A1"]}`, http.StatusBadRequest, "validation_failed", []string{"trip_id"}},
+		{"bad search date", "GET", "/api/trips/search?from=Addis+Ababa&date=tomorrow", "", ``, http.StatusBadRequest, "validation_failed", []string{"date"}},
+		{"bad date range", "GET", "/api/trips/search?from=Addis+Ababa&flexibleDateRange=week", "", ``, http.StatusBadRequest, "validation_failed", []string{"flexibleDateRange"}},
+	}
+	for _, tc := range cases {
+		status, code, details := send(tc.method, tc.path, tc.token, tc.body)
+		if status != tc.status || code != tc.code {
+			t.Errorf("%s: got %d %s, want %d %s", tc.name, status, code, tc.status, tc.code)
+		}
+		if len(details) != len(tc.invalidFields) {
+			t.Errorf("%s: got invalid fields %v, want %v", tc.name, details, tc.invalidFields)
+		}
+		for _, field := range tc.invalidFields {
+			if details[field] == "" {
+				t.Errorf("%s: expected %s to be reported, got %v", tc.name, field, details)
+			}
+		}
+	}
+}
+
 func TestGetProfileHandler(t *testing.T) {
-	setupTestDB()
+	srv := newTestServer()
 
 	// 1. Create a user
 	user := models.User{
@@ -515,7 +711,7 @@ func TestGetProfileHandler(t *testing.T) {
 	}
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	user.Password = string(hashedPassword)
-	userID, err := database.CreateUser(user)
+	userID, err := srv.Store.CreateUser(context.Background(), user)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
@@ -526,22 +722,22 @@ func TestGetProfileHandler(t *testing.T) {
 		From:           "Addis Ababa",
 		To:             "Adama",
 		Date:           "2025-09-01",
-		Time:           "10:00:00",
+		DepartureTime:  "10:00:00",
 		Price:          100.0,
 		SeatsAvailable: 50,
 		Seats:          []string{"A1", "A2"},
 	}
-	createdTrip1, _ := database.CreateTrip(trip1)
+	createdTrip1, _ := srv.Store.CreateTrip(context.Background(), trip1)
 
 	booking1 := models.Booking{
 		UserID: user.ID,
 		TripID: createdTrip1.ID,
 		Seats:  []string{"A1"},
 	}
-	database.CreateBooking(booking1)
+	srv.Store.CreateBooking(context.Background(), booking1)
 
 	// 3. Generate a JWT token for the user
-	tokenString, err := generateTestToken(user.Email)
+	tokenString, err := generateTestToken(srv, user.ID, user.Email)
 	if err != nil {
 		t.Fatalf("Failed to create token: %v", err)
 	}
@@ -555,7 +751,7 @@ func TestGetProfileHandler(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	r := mux.NewRouter()
-	r.Handle("/api/profile", auth.Middleware(http.HandlerFunc(handlers.GetProfileHandler))).Methods("GET")
+	r.Handle("/api/profile", srv.Auth.Middleware(http.HandlerFunc(srv.GetProfileHandler))).Methods("GET")
 	r.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/metrics"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// After lockoutThreshold failed logins in a row, an email address is
+	// locked for lockoutBase, doubling with each further failure up to
+	// lockoutMax. Failures are forgotten after lockoutReset without one.
+	lockoutThreshold = 5
+	lockoutBase      = time.Minute
+	lockoutMax       = time.Hour
+	lockoutReset     = 24 * time.Hour
+)
+
+// lockoutKey is the key failed logins are counted under.
+func lockoutKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// lockedUntil returns when the lockout after failures ends, or the zero
+// time if they do not lock the address.
+func lockedUntil(failures database.LoginFailures) time.Time {
+	if failures.Count < lockoutThreshold {
+		return time.Time{}
+	}
+	d := lockoutMax
+	if n := failures.Count - lockoutThreshold; n < 16 {
+		d = min(lockoutBase<<n, lockoutMax)
+	}
+	return failures.Last.Add(d)
+}
+
+// loginLocked writes a 429 and returns true if email is locked out.
+func (s *Server) loginLocked(w http.ResponseWriter, r *http.Request, email string) bool {
+	failures, err := s.Store.GetLoginFailures(r.Context(), lockoutKey(email))
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return true
+	}
+	if wait := time.Until(lockedUntil(failures)); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		apierror.Write(w, r, apierror.ErrTooManyAttempts)
+		return true
+	}
+	return false
+}
+
+// loginFailed counts a failed login to email and answers with the error
+// given for unknown addresses and wrong passwords alike.
+func (s *Server) loginFailed(w http.ResponseWriter, r *http.Request, email string) {
+	now := time.Now()
+	failures, err := s.Store.RecordLoginFailure(r.Context(), lockoutKey(email), now, now.Add(-lockoutReset))
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if failures.Count == lockoutThreshold {
+		metrics.LoginLockout()
+	}
+	apierror.Write(w, r, apierror.ErrInvalidCredentials)
+}
+
+// dummyPasswordHash is compared against when there is no password to check,
+// so that unknown addresses take as long to reject as wrong passwords.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// checkPassword reports whether password matches hash, taking the same time
+// whether or not there is a hash.
+func checkPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
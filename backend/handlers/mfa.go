@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/validate"
+)
+
+const (
+	// mfaChallengeTTL is how long a user has to enter their second factor
+	// after their password.
+	mfaChallengeTTL = 5 * time.Minute
+	// A login challenge stops working after mfaMaxAttempts wrong codes.
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+type mfaCodeRequest struct {
+	Code string `json:"code" validate:"required,max=10"`
+}
+
+// completeLogin finishes a login whose first factor has been checked. Users
+// with two-factor authentication get a short-lived challenge token to pass
+// to VerifyMFAHandler instead of a session.
+func (s *Server) completeLogin(w http.ResponseWriter, r *http.Request, user models.User) {
+	if !user.TOTPEnabled {
+		s.startSession(w, r, user, false)
+		return
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		serverError(w, r, "Failed to create token", err)
+		return
+	}
+	if err := s.Store.CreateUserToken(r.Context(), user.ID, database.TokenMFALogin, hash, time.Now().Add(mfaChallengeTTL)); err != nil {
+		slog.ErrorContext(r.Context(), "Error creating MFA challenge", "user_id", user.ID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfaRequired": true,
+		"mfaToken":    token,
+		"expiresIn":   int(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// checkTOTP verifies code against the user's authenticator and records its
+// time step so the same code cannot be used twice.
+func (s *Server) checkTOTP(ctx context.Context, user models.User, code string) (bool, error) {
+	step, ok := auth.VerifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return false, nil
+	}
+	return s.Store.RecordTOTPStep(ctx, user.ID, step)
+}
+
+// newRecoveryCodes generates a set of recovery codes and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// mfaUser loads the caller for the two-factor management handlers.
+func (s *Server) mfaUser(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return user, false
+	}
+	return user, true
+}
+
+// VerifyMFAHandler completes a login with an authenticator code or one of
+// the user's recovery codes.
+func (s *Server) VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken     string `json:"mfaToken" validate:"required"`
+		Code         string `json:"code" validate:"max=10"`
+		RecoveryCode string `json:"recoveryCode" validate:"max=32"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		apierror.Write(w, r, apierror.ErrValidation.WithDetails(validate.Errors{"code": "is required without a recoveryCode"}))
+		return
+	}
+
+	challenge, err := s.Store.LookupUserToken(r.Context(), database.TokenMFALogin, auth.HashToken(req.MFAToken))
+	if err == sql.ErrNoRows || (err == nil && challenge.Attempts >= mfaMaxAttempts) {
+		apierror.Write(w, r, apierror.ErrLoginExpired)
+		return
+	}
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	user, err := s.Store.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+
+	var valid bool
+	if req.RecoveryCode != "" {
+		valid, err = s.Store.UseRecoveryCode(r.Context(), user.ID, auth.HashToken(auth.NormalizeRecoveryCode(req.RecoveryCode)))
+	} else {
+		valid, err = s.checkTOTP(r.Context(), user, req.Code)
+	}
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if !valid {
+		if err := s.Store.RecordUserTokenFailure(r.Context(), challenge.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording MFA failure", "error", err)
+		}
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return
+	}
+
+	consumed, err := s.Store.ConsumeUserTokenByID(r.Context(), challenge.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if !consumed {
+		apierror.Write(w, r, apierror.ErrLoginExpired)
+		return
+	}
+	s.startSession(w, r, user, true)
+}
+
+// MFAStatusHandler reports whether the caller has two-factor authentication
+// enabled and whether their roles require it.
+func (s *Server) MFAStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.mfaUser(w, r)
+	if !ok {
+		return
+	}
+	roles, err := s.Store.GetUserRoles(r.Context(), user.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	remaining, err := s.Store.CountRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":                user.TOTPEnabled,
+		"required":               s.Auth.MFARequired(roles),
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+// SetupTOTPHandler creates a new authenticator secret for the caller. It
+// does not take effect until confirmed with EnableTOTPHandler.
+func (s *Server) SetupTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.mfaUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		apierror.Write(w, r, apierror.ErrMFAAlreadyEnabled)
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		serverError(w, r, "Failed to create secret", err)
+		return
+	}
+	if err := s.Store.SetTOTPSecret(r.Context(), user.ID, secret); err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Phone
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":     secret,
+		"otpauthUri": auth.TOTPURI(s.App.Name, account, secret),
+	})
+}
+
+// EnableTOTPHandler turns on two-factor authentication once the caller
+// enters a code from their authenticator. It returns the recovery codes,
+// which are shown only this once, and a new access token: the current
+// session counts as having passed two-factor authentication.
+func (s *Server) EnableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req mfaCodeRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	user, ok := s.mfaUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		apierror.Write(w, r, apierror.ErrMFAAlreadyEnabled)
+		return
+	}
+	if user.TOTPSecret == "" {
+		apierror.Write(w, r, apierror.ErrMFASetupRequired)
+		return
+	}
+
+	step, valid := auth.VerifyTOTP(user.TOTPSecret, req.Code, time.Now(), 0)
+	if !valid {
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		serverError(w, r, "Failed to create recovery codes", err)
+		return
+	}
+	if err := s.Store.EnableTOTP(r.Context(), user.ID, step, hashes); err != nil {
+		slog.ErrorContext(r.Context(), "Error enabling TOTP", "user_id", user.ID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	user.TOTPEnabled = true
+
+	claims := auth.ClaimsFromContext(r.Context())
+	if claims.SessionID != 0 {
+		if err := s.Store.SetSessionMFA(r.Context(), claims.SessionID); err != nil {
+			serverError(w, r, "Database error", err)
+			return
+		}
+	}
+	token, _, err := s.accessToken(r.Context(), user, claims.SessionID, true)
+	if err != nil {
+		serverError(w, r, "Failed to create token", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recoveryCodes": codes,
+		"token":         token,
+		"expiresIn":     int(accessTokenTTL.Seconds()),
+	})
+}
+
+// DisableTOTPHandler turns two-factor authentication off. Users whose roles
+// require it cannot.
+func (s *Server) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req mfaCodeRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	user, ok := s.mfaUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Write(w, r, apierror.ErrMFANotEnabled)
+		return
+	}
+	roles, err := s.Store.GetUserRoles(r.Context(), user.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if s.Auth.MFARequired(roles) {
+		apierror.Write(w, r, apierror.ErrMFARequired)
+		return
+	}
+
+	valid, err := s.checkTOTP(r.Context(), user, req.Code)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if !valid {
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return
+	}
+	if err := s.Store.DisableTOTP(r.Context(), user.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Error disabling TOTP", "user_id", user.ID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodesHandler replaces the caller's recovery codes,
+// invalidating the old ones.
+func (s *Server) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var req mfaCodeRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	user, ok := s.mfaUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		apierror.Write(w, r, apierror.ErrMFANotEnabled)
+		return
+	}
+
+	valid, err := s.checkTOTP(r.Context(), user, req.Code)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if !valid {
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		serverError(w, r, "Failed to create recovery codes", err)
+		return
+	}
+	if err := s.Store.ReplaceRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recoveryCodes": codes})
+}
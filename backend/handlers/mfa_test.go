@@ -0,0 +1,192 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+func newMFARouter(srv *handlers.Server) *mux.Router {
+	r := newSessionRouter(srv)
+	r.HandleFunc("/api/auth/mfa", srv.VerifyMFAHandler).Methods("POST")
+	r.Handle("/api/mfa", srv.Auth.Middleware(http.HandlerFunc(srv.MFAStatusHandler))).Methods("GET")
+	r.Handle("/api/mfa/totp/setup", srv.Auth.Middleware(http.HandlerFunc(srv.SetupTOTPHandler))).Methods("POST")
+	r.Handle("/api/mfa/totp/enable", srv.Auth.Middleware(http.HandlerFunc(srv.EnableTOTPHandler))).Methods("POST")
+	r.Handle("/api/mfa/totp/disable", srv.Auth.Middleware(http.HandlerFunc(srv.DisableTOTPHandler))).Methods("POST")
+	r.Handle("/api/mfa/recovery-codes", srv.Auth.Middleware(http.HandlerFunc(srv.RegenerateRecoveryCodesHandler))).Methods("POST")
+	return r
+}
+
+// tokenClaims decodes the payload of an access token without checking it.
+func tokenClaims(t *testing.T, token string) *auth.Claims {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed token %q", token)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("malformed token payload: %v", err)
+	}
+	var claims auth.Claims
+	json.Unmarshal(payload, &claims)
+	return &claims
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	code, err := auth.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	return code
+}
+
+func TestTOTPEnrolmentAndLogin(t *testing.T) {
+	srv := newTestServer()
+	r := newMFARouter(srv)
+	createPasswordUser(t, srv, "staff@example.com", "secret")
+	user, _ := srv.Store.GetUserByEmail(context.Background(), "staff@example.com")
+	srv.Store.GrantRole(context.Background(), user.ID, string(auth.RoleOperator))
+
+	// Without two-factor authentication the operator role is withheld
+	rr := operatorRequest(r, "POST", "/api/auth/login", "", map[string]string{"email": "staff@example.com", "password": "secret"})
+	var first struct {
+		tokens
+		MFASetupRequired bool `json:"mfaSetupRequired"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &first)
+	if !first.MFASetupRequired || tokenClaims(t, first.Token).HasRole(auth.RoleOperator) {
+		t.Fatalf("expected operator role to be withheld pending setup, got %s", rr.Body.String())
+	}
+
+	rr = operatorRequest(r, "POST", "/api/mfa/totp/setup", first.Token, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("setup: got status %v want %v", rr.Code, http.StatusOK)
+	}
+	var setup struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauthUri"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &setup)
+	if !strings.HasPrefix(setup.OTPAuthURI, "otpauth://totp/") {
+		t.Errorf("unexpected otpauth URI %q", setup.OTPAuthURI)
+	}
+
+	step := auth.TOTPStep(time.Now())
+	if rr := operatorRequest(r, "POST", "/api/mfa/totp/enable", first.Token, map[string]string{"code": "000000x"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("enable with wrong code: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	rr = operatorRequest(r, "POST", "/api/mfa/totp/enable", first.Token, map[string]string{"code": totpCode(t, setup.Secret, step)})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("enable: got status %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var enabled struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+		Token         string   `json:"token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &enabled)
+	if len(enabled.RecoveryCodes) != 10 {
+		t.Errorf("expected 10 recovery codes, got %d", len(enabled.RecoveryCodes))
+	}
+	if !tokenClaims(t, enabled.Token).HasRole(auth.RoleOperator) {
+		t.Error("expected the enrolling session to get the operator role")
+	}
+
+	// Operators cannot turn it off again
+	if rr := operatorRequest(r, "POST", "/api/mfa/totp/disable", enabled.Token, map[string]string{"code": totpCode(t, setup.Secret, step+1)}); rr.Code != http.StatusForbidden {
+		t.Errorf("disable for operator: got status %v want %v", rr.Code, http.StatusForbidden)
+	}
+
+	// A password alone now only yields a challenge
+	challenge := func() string {
+		rr := operatorRequest(r, "POST", "/api/auth/login", "", map[string]string{"email": "staff@example.com", "password": "secret"})
+		var resp struct {
+			MFARequired bool   `json:"mfaRequired"`
+			MFAToken    string `json:"mfaToken"`
+			Token       string `json:"token"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if !resp.MFARequired || resp.MFAToken == "" || resp.Token != "" {
+			t.Fatalf("expected an MFA challenge, got %s", rr.Body.String())
+		}
+		return resp.MFAToken
+	}
+	mfaToken := challenge()
+
+	// The code used to enable cannot be replayed
+	if rr := operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": mfaToken, "code": totpCode(t, setup.Secret, step)}); rr.Code != http.StatusBadRequest {
+		t.Errorf("replayed code: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	rr = operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": mfaToken, "code": totpCode(t, setup.Secret, step+1)})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("mfa login: got status %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var second tokens
+	json.Unmarshal(rr.Body.Bytes(), &second)
+	if claims := tokenClaims(t, second.Token); !claims.MFA || !claims.HasRole(auth.RoleOperator) {
+		t.Errorf("expected an MFA token with the operator role, got %+v", claims)
+	}
+	if rr := operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": mfaToken, "code": totpCode(t, setup.Secret, step+1)}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("reused challenge: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	// Refreshing keeps the session's MFA status
+	rr = operatorRequest(r, "POST", "/api/auth/refresh", "", map[string]string{"refreshToken": second.RefreshToken})
+	var refreshed tokens
+	json.Unmarshal(rr.Body.Bytes(), &refreshed)
+	if !tokenClaims(t, refreshed.Token).HasRole(auth.RoleOperator) {
+		t.Error("expected refreshed token to keep the operator role")
+	}
+
+	// Recovery codes work once, in any formatting
+	recovery := strings.ToUpper(strings.ReplaceAll(enabled.RecoveryCodes[0], "-", ""))
+	if rr := operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": challenge(), "recoveryCode": recovery}); rr.Code != http.StatusOK {
+		t.Fatalf("recovery code login: got status %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr := operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": challenge(), "recoveryCode": recovery}); rr.Code != http.StatusBadRequest {
+		t.Errorf("reused recovery code: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestMFAChallengeAttemptLimit(t *testing.T) {
+	srv := newTestServer()
+	r := newMFARouter(srv)
+	createPasswordUser(t, srv, "careful@example.com", "secret")
+	user, _ := srv.Store.GetUserByEmail(context.Background(), "careful@example.com")
+	secret, _ := auth.NewTOTPSecret()
+	srv.Store.SetTOTPSecret(context.Background(), user.ID, secret)
+	srv.Store.EnableTOTP(context.Background(), user.ID, 0, nil)
+
+	rr := operatorRequest(r, "POST", "/api/auth/login", "", map[string]string{"email": "careful@example.com", "password": "secret"})
+	var resp struct {
+		MFAToken string `json:"mfaToken"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+
+	for i := 0; i < 5; i++ {
+		operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": resp.MFAToken, "recoveryCode": "wrong"})
+	}
+	code := totpCode(t, secret, auth.TOTPStep(time.Now()))
+	if rr := operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": resp.MFAToken, "code": code}); rr.Code != http.StatusUnauthorized {
+		t.Errorf("challenge after 5 wrong codes: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	// Customers may turn two-factor authentication off
+	rr = operatorRequest(r, "POST", "/api/auth/login", "", map[string]string{"email": "careful@example.com", "password": "secret"})
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	rr = operatorRequest(r, "POST", "/api/auth/mfa", "", map[string]string{"mfaToken": resp.MFAToken, "code": code})
+	var tok tokens
+	json.Unmarshal(rr.Body.Bytes(), &tok)
+	next := totpCode(t, secret, auth.TOTPStep(time.Now())+1)
+	if rr := operatorRequest(r, "POST", "/api/mfa/totp/disable", tok.Token, map[string]string{"code": next}); rr.Code != http.StatusOK {
+		t.Errorf("disable: got status %v want %v", rr.Code, http.StatusOK)
+	}
+}
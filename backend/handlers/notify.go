@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+)
+
+// recipient addresses a user on every channel they have. Unverified phone
+// numbers are not messaged.
+func recipient(user models.User) notifications.Recipient {
+	to := notifications.Recipient{Name: user.Name, Email: user.Email, Language: user.Language}
+	if user.PhoneVerified {
+		to.Phone = user.Phone
+	}
+	return to
+}
+
+func tripData(trip models.Trip) notifications.Data {
+	return notifications.Data{
+		From:          trip.From,
+		To:            trip.To,
+		Date:          trip.Date,
+		DepartureTime: trip.DepartureTime,
+		DelayMinutes:  trip.DelayMinutes,
+		Reason:        trip.StatusReason,
+	}
+}
+
+// notify queues a notification for user. Failing to queue never fails the
+// request that triggered it; the error is logged instead.
+func (s *Server) notify(ctx context.Context, user models.User, event notifications.Event, data notifications.Data) {
+	if s.Outbox == nil {
+		return
+	}
+	if err := notifications.Notify(ctx, s.Outbox, recipient(user), event, data); err != nil {
+		slog.ErrorContext(ctx, "Failed to queue notification", "event", event, "user_id", user.ID, "error", err)
+	}
+}
+
+// notifyBooking queues event for the owner of booking.
+func (s *Server) notifyBooking(ctx context.Context, booking models.Booking, trip models.Trip, event notifications.Event, reason string) {
+	if s.Outbox == nil {
+		return
+	}
+	user, err := s.Store.GetUserByID(ctx, booking.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load user for notification", "user_id", booking.UserID, "event", event, "error", err)
+		return
+	}
+	data := tripData(trip)
+	data.BookingID = booking.ID
+	data.Seats = booking.Seats
+	data.RefundEligible = booking.RefundEligible
+	if reason != "" {
+		data.Reason = reason
+	}
+	s.notify(ctx, user, event, data)
+}
+
+// notifyTripStatus tells passengers about a status change that affects them.
+// cancelled holds the bookings cancelled along with the trip, and ended the
+// waitlist entries it ended.
+func (s *Server) notifyTripStatus(ctx context.Context, update models.TripStatusUpdate, cancelled []models.Booking, ended []models.WaitlistEntry) {
+	if s.Outbox == nil {
+		return
+	}
+
+	var event notifications.Event
+	switch update.Status {
+	case models.TripDelayed:
+		event = notifications.EventTripDelayed
+	case models.TripBoarding:
+		event = notifications.EventTripBoarding
+	case models.TripCancelled:
+		event = notifications.EventTripCancelled
+	default:
+		return
+	}
+
+	trip, err := s.Store.GetTripByID(ctx, update.TripID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load trip for notification", "trip_id", update.TripID, "event", event, "error", err)
+		return
+	}
+
+	bookings := cancelled
+	if update.Status != models.TripCancelled {
+		bookings, err = s.Store.GetBookingsByTrip(ctx, update.TripID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load bookings for notification", "trip_id", update.TripID, "event", event, "error", err)
+			return
+		}
+	}
+	for _, booking := range bookings {
+		if update.Status != models.TripCancelled && booking.Status != models.BookingConfirmed {
+			continue
+		}
+		s.notifyBooking(ctx, booking, trip, event, update.Reason)
+	}
+	for _, entry := range ended {
+		user, err := s.Store.GetUserByID(ctx, entry.UserID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load user for notification", "user_id", entry.UserID, "event", notifications.EventWaitlistEnded, "error", err)
+			continue
+		}
+		data := tripData(trip)
+		data.Seats = entry.OfferedSeats
+		if update.Reason != "" {
+			data.Reason = update.Reason
+		}
+		s.notify(ctx, user, notifications.EventWaitlistEnded, data)
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// The operator handlers are mounted behind auth.Auth.OperatorMiddleware,
+// which has already verified that any {tripID} or {busID} in the path
+// belongs to the caller's operator.
+
+func (s *Server) OperatorListTripsHandler(w http.ResponseWriter, r *http.Request) {
+	trips, err := s.Store.ListTripsByOperator(r.Context(), auth.OperatorID(r.Context()))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing operator trips", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trips)
+}
+
+func (s *Server) OperatorCreateTripHandler(w http.ResponseWriter, r *http.Request) {
+	var req tripRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	trip := req.trip()
+
+	operatorID := auth.OperatorID(r.Context())
+	operator, err := s.Store.GetOperatorByID(r.Context(), operatorID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	trip.OperatorID = operatorID
+	trip.BusOperator = operator.Name
+
+	if trip.BusID != 0 {
+		bus, ok := s.operatorBus(w, r, trip.BusID, operatorID)
+		if !ok {
+			return
+		}
+		if len(trip.Seats) == 0 {
+			trip.Seats = bus.Seats
+		}
+		if len(trip.Amenities) == 0 {
+			trip.Amenities = bus.Amenities
+		}
+	}
+	if trip.SeatsAvailable == 0 {
+		trip.SeatsAvailable = len(trip.Seats)
+	}
+
+	trip, err = s.Store.CreateTrip(r.Context(), trip)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating trip", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(trip)
+}
+
+func (s *Server) OperatorUpdateTripHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["tripID"])
+
+	var req tripRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	trip := req.trip()
+	trip.ID = id
+
+	if trip.BusID != 0 {
+		if _, ok := s.operatorBus(w, r, trip.BusID, auth.OperatorID(r.Context())); !ok {
+			return
+		}
+	}
+
+	before, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if err := s.Store.UpdateTrip(r.Context(), trip); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating trip", "trip_id", id, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	updated, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	s.rescheduleReminders(r.Context(), before, updated)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// OperatorCancelTripHandler is shorthand for moving a trip to the cancelled
+// state, which cancels its bookings.
+func (s *Server) OperatorCancelTripHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["tripID"])
+
+	var req reasonRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	update := models.TripStatusUpdate{TripID: id, Status: models.TripCancelled, Reason: req.Reason}
+	if _, ok := s.applyTripStatus(w, r, update); !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Trip cancelled successfully"})
+}
+
+func (s *Server) OperatorUpdateTripStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["tripID"])
+
+	var req tripStatusRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	update := models.TripStatusUpdate{TripID: id, Status: req.Status, DelayMinutes: req.DelayMinutes, Reason: req.Reason}
+
+	if !update.Status.Valid() {
+		apierror.Write(w, r, apierror.ErrUnknownTripStatus)
+		return
+	}
+	if update.Status == models.TripDelayed && update.DelayMinutes <= 0 {
+		apierror.Write(w, r, apierror.ErrValidation.WithDetails(map[string]string{"delayMinutes": "must be positive for a delay"}))
+		return
+	}
+
+	update, ok := s.applyTripStatus(w, r, update)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update)
+}
+
+func (s *Server) OperatorTripBookingsHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["tripID"])
+
+	bookings, err := s.Store.GetBookingsByTrip(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing trip bookings", "trip_id", id, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookings)
+}
+
+func (s *Server) OperatorListBusesHandler(w http.ResponseWriter, r *http.Request) {
+	buses, err := s.Store.ListBusesByOperator(r.Context(), auth.OperatorID(r.Context()))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing buses", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buses)
+}
+
+func (s *Server) OperatorCreateBusHandler(w http.ResponseWriter, r *http.Request) {
+	var req busRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	bus := req.bus()
+	bus.OperatorID = auth.OperatorID(r.Context())
+	if bus.Capacity == 0 {
+		bus.Capacity = len(bus.Seats)
+	}
+
+	bus, err := s.Store.CreateBus(r.Context(), bus)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating bus", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bus)
+}
+
+func (s *Server) OperatorUpdateBusHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["busID"])
+
+	var req busRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	bus := req.bus()
+	bus.ID = id
+	bus.OperatorID = auth.OperatorID(r.Context())
+	if bus.Capacity == 0 {
+		bus.Capacity = len(bus.Seats)
+	}
+
+	if err := s.Store.UpdateBus(r.Context(), bus); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating bus", "bus_id", id, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bus)
+}
+
+func (s *Server) OperatorDeleteBusHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["busID"])
+
+	if err := s.Store.DeleteBus(r.Context(), id); err != nil {
+		if err == database.ErrBusInUse {
+			apierror.Write(w, r, apierror.ErrBusInUse)
+		} else {
+			serverError(w, r, "Error deleting bus", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// operatorBus loads a bus referenced from a request body and rejects it if it
+// belongs to a different operator.
+func (s *Server) operatorBus(w http.ResponseWriter, r *http.Request, busID, operatorID int) (models.Bus, bool) {
+	ctx := r.Context()
+	bus, err := s.Store.GetBusByID(ctx, busID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrBusNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return bus, false
+	}
+	if bus.OperatorID != operatorID {
+		apierror.Write(w, r, apierror.ErrOtherOperator)
+		return bus, false
+	}
+	return bus, true
+}
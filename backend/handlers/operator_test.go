@@ -0,0 +1,251 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+func newOperatorRouter(srv *handlers.Server) *mux.Router {
+	r := mux.NewRouter()
+	operator := r.PathPrefix("/api/operator").Subrouter()
+	operator.Use(srv.Auth.Middleware, srv.Auth.OperatorMiddleware)
+	operator.HandleFunc("/trips", srv.OperatorListTripsHandler).Methods("GET")
+	operator.HandleFunc("/trips", srv.OperatorCreateTripHandler).Methods("POST")
+	operator.HandleFunc("/trips/{tripID}", srv.OperatorUpdateTripHandler).Methods("PUT")
+	operator.HandleFunc("/trips/{tripID}/status", srv.OperatorUpdateTripStatusHandler).Methods("PUT")
+	operator.HandleFunc("/trips/{tripID}/cancel", srv.OperatorCancelTripHandler).Methods("POST")
+	operator.HandleFunc("/trips/{tripID}/bookings", srv.OperatorTripBookingsHandler).Methods("GET")
+	operator.HandleFunc("/buses", srv.OperatorListBusesHandler).Methods("GET")
+	operator.HandleFunc("/buses", srv.OperatorCreateBusHandler).Methods("POST")
+	operator.HandleFunc("/buses/{busID}", srv.OperatorUpdateBusHandler).Methods("PUT")
+	operator.HandleFunc("/buses/{busID}", srv.OperatorDeleteBusHandler).Methods("DELETE")
+	return r
+}
+
+// createOperatorStaff creates an operator with one staff account, a bus and a
+// trip, and returns a token for the staff account.
+func createOperatorStaff(t *testing.T, srv *handlers.Server, name, email string) (string, models.Bus, models.Trip) {
+	operatorID, err := srv.Store.CreateOperator(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Failed to create operator: %v", err)
+	}
+	userID, err := srv.Store.CreateUser(context.Background(), models.User{Name: name + " Staff", Email: email, Password: "x"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := srv.Store.SetUserOperator(context.Background(), userID, operatorID); err != nil {
+		t.Fatalf("Failed to attach user to operator: %v", err)
+	}
+
+	bus, err := srv.Store.CreateBus(context.Background(), models.Bus{OperatorID: operatorID, PlateNumber: name + "-1", Capacity: 2, Seats: []string{"A1", "A2"}})
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	trip, err := srv.Store.CreateTrip(context.Background(), models.Trip{
+		From:           "Addis Ababa",
+		To:             "Adama",
+		Date:           "2025-09-01",
+		DepartureTime:  "10:00:00",
+		ArrivalTime:    "11:30:00",
+		Price:          100.0,
+		SeatsAvailable: 2,
+		Seats:          []string{"A1", "A2"},
+		BusOperator:    name,
+		OperatorID:     operatorID,
+		BusID:          bus.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create trip: %v", err)
+	}
+
+	claims := auth.NewClaims(userID, email, []string{string(auth.RoleOperator)}, operatorID, 5*time.Minute)
+	token, err := srv.Auth.SignToken(claims)
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	return token, bus, trip
+}
+
+func operatorRequest(r *mux.Router, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOperatorListTripsIsScoped(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+
+	tokenA, _, tripA := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+	createOperatorStaff(t, srv, "Sky Bus", "staff@sky.example.com")
+
+	rr := operatorRequest(r, "GET", "/api/operator/trips", tokenA, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var trips []models.Trip
+	if err := json.Unmarshal(rr.Body.Bytes(), &trips); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if len(trips) != 1 || trips[0].ID != tripA.ID {
+		t.Errorf("expected only trip %d, got %v", tripA.ID, trips)
+	}
+}
+
+func TestOperatorCrossOperatorAccessRejected(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+
+	tokenA, _, _ := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+	_, busB, tripB := createOperatorStaff(t, srv, "Sky Bus", "staff@sky.example.com")
+
+	tripPath := "/api/operator/trips/" + strconv.Itoa(tripB.ID)
+	busPath := "/api/operator/buses/" + strconv.Itoa(busB.ID)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"update trip", "PUT", tripPath, map[string]interface{}{"from": "Addis Ababa", "to": "Dire Dawa", "date": "2025-09-02", "departureTime": "09:00:00", "arrivalTime": "18:00:00"}},
+		{"cancel trip", "POST", tripPath + "/cancel", nil},
+		{"update trip status", "PUT", tripPath + "/status", map[string]interface{}{"status": models.TripDelayed, "delayMinutes": 30}},
+		{"list trip bookings", "GET", tripPath + "/bookings", nil},
+		{"update bus", "PUT", busPath, map[string]interface{}{"plateNumber": "stolen", "seats": []string{"A1"}}},
+		{"delete bus", "DELETE", busPath, nil},
+		{"create trip on foreign bus", "POST", "/api/operator/trips", map[string]interface{}{"from": "Addis Ababa", "to": "Adama", "date": "2025-09-03", "departureTime": "08:00:00", "arrivalTime": "09:30:00", "busId": busB.ID}},
+	}
+
+	for _, tc := range cases {
+		rr := operatorRequest(r, tc.method, tc.path, tokenA, tc.body)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tc.name, rr.Code, http.StatusForbidden)
+		}
+	}
+
+	trip, err := srv.Store.GetTripByID(context.Background(), tripB.ID)
+	if err != nil {
+		t.Fatalf("Failed to get trip: %v", err)
+	}
+	if trip.Status != models.TripScheduled || trip.To != "Adama" {
+		t.Errorf("foreign trip was modified: %+v", trip)
+	}
+	if _, err := srv.Store.GetBusByID(context.Background(), busB.ID); err != nil {
+		t.Errorf("foreign bus was deleted: %v", err)
+	}
+}
+
+func TestOperatorRoutesRequireOperatorAccount(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+
+	userID, err := srv.Store.CreateUser(context.Background(), models.User{Name: "Customer", Email: "customer@example.com", Password: "x"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	token, err := generateTestToken(srv, userID, "customer@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	rr := operatorRequest(r, "GET", "/api/operator/trips", token, nil)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code for customer: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+
+	rr = operatorRequest(r, "GET", "/api/operator/trips", "", nil)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code without token: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestOperatorCreateAndCancelTrip(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+
+	token, bus, _ := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+
+	rr := operatorRequest(r, "POST", "/api/operator/trips", token, map[string]interface{}{
+		"from":          "Addis Ababa",
+		"to":            "Hawassa",
+		"date":          "2025-09-05",
+		"departureTime": "07:00:00",
+		"arrivalTime":   "11:00:00",
+		"price":         300.0,
+		"duration":      "4h 0m",
+		"busId":         bus.ID,
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var created models.Trip
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if created.BusOperator != "Selam Bus" || created.SeatsAvailable != len(bus.Seats) {
+		t.Errorf("trip not populated from operator and bus: %+v", created)
+	}
+
+	rr = operatorRequest(r, "POST", "/api/operator/trips/"+strconv.Itoa(created.ID)+"/cancel", token, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code for cancel: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	trip, err := srv.Store.GetTripByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get trip: %v", err)
+	}
+	if trip.Status != models.TripCancelled {
+		t.Errorf("expected trip to be cancelled")
+	}
+}
+
+func TestOperatorDeleteBusInUse(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+
+	token, bus, trip := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+	busPath := "/api/operator/buses/" + strconv.Itoa(bus.ID)
+
+	rr := operatorRequest(r, "DELETE", busPath, token, nil)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("deleting an assigned bus: got status %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	// Without any trips on it the bus can go
+	if err := srv.Store.DeleteTrip(context.Background(), trip.ID); err != nil {
+		t.Fatalf("Failed to delete trip: %v", err)
+	}
+	rr = operatorRequest(r, "DELETE", busPath, token, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("deleting an unused bus: got status %v want %v: %s", rr.Code, http.StatusNoContent, rr.Body.String())
+	}
+	if _, err := srv.Store.GetBusByID(context.Background(), bus.ID); err != sql.ErrNoRows {
+		t.Errorf("expected the bus to be deleted, got %v", err)
+	}
+}
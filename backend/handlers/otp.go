@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	otpTTL = 5 * time.Minute
+	// At most otpSendLimit codes are sent to a number per otpSendWindow.
+	otpSendLimit  = 3
+	otpSendWindow = 15 * time.Minute
+	// A code stops working after otpMaxAttempts wrong guesses.
+	otpMaxAttempts = 5
+	// A number is locked for otpLockoutWindow once otpLockoutThreshold
+	// wrong codes have been entered for it within that window.
+	otpLockoutThreshold = 10
+	otpLockoutWindow    = time.Hour
+)
+
+type otpRequest struct {
+	Phone    string `json:"phone" validate:"required,max=20"`
+	Code     string `json:"code" validate:"max=10"`
+	Name     string `json:"name" validate:"max=100"`
+	Language string `json:"language" validate:"oneof=en am"`
+}
+
+func generateOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// otpLocked writes 429 and returns true if phone is locked out after too
+// many wrong codes.
+func (s *Server) otpLocked(w http.ResponseWriter, r *http.Request, phone string) bool {
+	ctx := r.Context()
+	failures, err := s.Store.FailedOTPAttemptsSince(ctx, phone, time.Now().Add(-otpLockoutWindow))
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return true
+	}
+	if failures >= otpLockoutThreshold {
+		w.Header().Set("Retry-After", strconv.Itoa(int(otpLockoutWindow.Seconds())))
+		apierror.Write(w, r, apierror.ErrTooManyAttempts)
+		return true
+	}
+	return false
+}
+
+// sendOTP rate-limits, stores and texts a new code to phone. It writes the
+// error response and returns false on failure.
+func (s *Server) sendOTP(w http.ResponseWriter, r *http.Request, phone, purpose string, userID int, language string) bool {
+	ctx := r.Context()
+	if s.otpLocked(w, r, phone) {
+		return false
+	}
+	sent, err := s.Store.CountOTPsSince(ctx, phone, time.Now().Add(-otpSendWindow))
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return false
+	}
+	if sent >= otpSendLimit {
+		w.Header().Set("Retry-After", strconv.Itoa(int(otpSendWindow.Seconds())))
+		apierror.Write(w, r, apierror.ErrTooManyCodes)
+		return false
+	}
+
+	code, err := generateOTP()
+	if err != nil {
+		serverError(w, r, "Failed to create code", err)
+		return false
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		serverError(w, r, "Failed to create code", err)
+		return false
+	}
+	if err := s.Store.CreateOTP(ctx, phone, purpose, userID, string(hash), time.Now().Add(otpTTL)); err != nil {
+		slog.ErrorContext(ctx, "Error storing OTP", "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return false
+	}
+
+	if s.Outbox != nil {
+		to := notifications.Recipient{Phone: phone, Language: language}
+		if err := notifications.Notify(ctx, s.Outbox, to, notifications.EventOTPCode, notifications.Data{Code: code}); err != nil {
+			slog.ErrorContext(ctx, "Error queueing OTP", "error", err)
+			apierror.Write(w, r, apierror.ErrInternal)
+			return false
+		}
+	}
+	return true
+}
+
+// checkOTP verifies and consumes the current code for phone. It writes the
+// error response and returns false if the code is wrong, used up or locked.
+func (s *Server) checkOTP(w http.ResponseWriter, r *http.Request, phone, purpose, code string) (database.OTPCode, bool) {
+	otp, ok := s.verifyOTP(w, r, phone, purpose, code)
+	if !ok {
+		return otp, false
+	}
+	return otp, s.consumeOTP(w, r, otp)
+}
+
+// verifyOTP is checkOTP without consuming the code, for callers that may
+// still turn the request down and let the user retry with the same code.
+func (s *Server) verifyOTP(w http.ResponseWriter, r *http.Request, phone, purpose, code string) (database.OTPCode, bool) {
+	ctx := r.Context()
+	if s.otpLocked(w, r, phone) {
+		return database.OTPCode{}, false
+	}
+
+	otp, err := s.Store.GetActiveOTP(ctx, phone, purpose)
+	if err == sql.ErrNoRows || (err == nil && otp.Attempts >= otpMaxAttempts) {
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return otp, false
+	}
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return otp, false
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(otp.CodeHash), []byte(code)) != nil {
+		if err := s.Store.RecordOTPFailure(ctx, otp.ID); err != nil {
+			slog.ErrorContext(ctx, "Error recording OTP failure", "error", err)
+		}
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return otp, false
+	}
+	return otp, true
+}
+
+// consumeOTP uses up a code verified by verifyOTP. It writes the error
+// response and returns false if another request used it first.
+func (s *Server) consumeOTP(w http.ResponseWriter, r *http.Request, otp database.OTPCode) bool {
+	consumed, err := s.Store.ConsumeOTP(r.Context(), otp.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return false
+	}
+	if !consumed {
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return false
+	}
+	return true
+}
+
+// decodeOTPRequest reads the body and normalizes its phone number.
+func decodeOTPRequest(w http.ResponseWriter, r *http.Request) (otpRequest, bool) {
+	var req otpRequest
+	if !decode(w, r, &req) {
+		return req, false
+	}
+	phone, err := auth.NormalizePhone(req.Phone)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrInvalidPhone)
+		return req, false
+	}
+	req.Phone = phone
+	return req, true
+}
+
+// RequestOTPHandler texts a login code. It works for both existing and new
+// phone numbers; the account is created when the code is verified.
+func (s *Server) RequestOTPHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeOTPRequest(w, r)
+	if !ok {
+		return
+	}
+
+	language := req.Language
+	if user, err := s.Store.GetUserByPhone(r.Context(), req.Phone); err == nil {
+		language = user.Language
+	}
+	if !s.sendOTP(w, r, req.Phone, database.OTPLogin, 0, language) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Code sent"})
+}
+
+// VerifyOTPHandler logs in with a texted code, signing up a new customer if
+// no account has the phone number yet.
+func (s *Server) VerifyOTPHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeOTPRequest(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := s.Store.GetUserByPhone(r.Context(), req.Phone)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	isNew := err == sql.ErrNoRows
+
+	// Whether the number has an account is only revealed once the code is
+	// proven, and the code is kept so the user can retry with a name
+	otp, ok := s.verifyOTP(w, r, req.Phone, database.OTPLogin, req.Code)
+	if !ok {
+		return
+	}
+	if isNew && req.Name == "" {
+		apierror.Write(w, r, apierror.ErrValidation.WithDetails(map[string]string{"name": "required to create an account"}))
+		return
+	}
+	if !s.consumeOTP(w, r, otp) {
+		return
+	}
+
+	switch {
+	case isNew:
+		user = models.User{Name: req.Name, Phone: req.Phone, PhoneVerified: true, Language: req.Language}
+		if user.Language != "am" {
+			user.Language = notifications.DefaultLanguage
+		}
+		user.ID, err = s.Store.CreateUser(r.Context(), user)
+		if err == nil {
+			err = s.Store.GrantRole(r.Context(), user.ID, string(auth.RoleCustomer))
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error creating phone account", "error", err)
+			apierror.Write(w, r, apierror.ErrInternal)
+			return
+		}
+	case !user.PhoneVerified:
+		if err := s.Store.SetPhoneVerified(r.Context(), user.ID); err != nil {
+			serverError(w, r, "Database error", err)
+			return
+		}
+		user.PhoneVerified = true
+	}
+
+	s.completeLogin(w, r, user)
+}
+
+// LinkPhoneHandler texts a code to add a phone number to the caller's
+// account.
+func (s *Server) LinkPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeOTPRequest(w, r)
+	if !ok {
+		return
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+
+	if other, err := s.Store.GetUserByPhone(r.Context(), req.Phone); err == nil && other.ID != user.ID {
+		apierror.Write(w, r, apierror.ErrPhoneTaken)
+		return
+	}
+	if !s.sendOTP(w, r, req.Phone, database.OTPLink, user.ID, user.Language) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Code sent"})
+}
+
+// VerifyLinkPhoneHandler completes LinkPhoneHandler.
+func (s *Server) VerifyLinkPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeOTPRequest(w, r)
+	if !ok {
+		return
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+
+	otp, ok := s.checkOTP(w, r, req.Phone, database.OTPLink, req.Code)
+	if !ok {
+		return
+	}
+	if otp.UserID != claims.UserID {
+		apierror.Write(w, r, apierror.ErrInvalidCode)
+		return
+	}
+
+	if other, err := s.Store.GetUserByPhone(r.Context(), req.Phone); err == nil && other.ID != claims.UserID {
+		apierror.Write(w, r, apierror.ErrPhoneTaken)
+		return
+	}
+	if err := s.Store.SetUserPhone(r.Context(), claims.UserID, req.Phone); err != nil {
+		slog.ErrorContext(r.Context(), "Error linking phone", "user_id", claims.UserID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Phone number linked", "phone": req.Phone})
+}
+
+// LinkEmailHandler adds an email and password to an account that signed up
+// by phone, and mails a verification link to the address.
+func (s *Server) LinkEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email" validate:"required,email,max=254"`
+		Password string `json:"password" validate:"required,password"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+	if user.Email != "" {
+		apierror.Write(w, r, apierror.ErrHasEmail)
+		return
+	}
+	if _, err := s.Store.GetUserByEmail(r.Context(), req.Email); err == nil {
+		apierror.Write(w, r, apierror.ErrEmailTaken)
+		return
+	} else if err != sql.ErrNoRows {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		serverError(w, r, "Failed to hash password", err)
+		return
+	}
+	if err := s.Store.SetUserEmail(r.Context(), user.ID, req.Email, string(hashedPassword)); err != nil {
+		slog.ErrorContext(r.Context(), "Error linking email", "user_id", user.ID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+
+	user.Email = req.Email
+	if err := s.sendVerificationEmail(r.Context(), user); err != nil {
+		slog.ErrorContext(r.Context(), "Error sending verification email", "user_id", user.ID, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email address added; check your inbox to verify it"})
+}
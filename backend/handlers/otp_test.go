@@ -0,0 +1,185 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+
+	"github.com/gorilla/mux"
+)
+
+func newOTPRouter(srv *handlers.Server) *mux.Router {
+	r := newAccountRouter(srv)
+	r.HandleFunc("/api/auth/otp/request", srv.RequestOTPHandler).Methods("POST")
+	r.HandleFunc("/api/auth/otp/verify", srv.VerifyOTPHandler).Methods("POST")
+	r.Handle("/api/profile", srv.Auth.Middleware(http.HandlerFunc(srv.GetProfileHandler))).Methods("GET")
+	r.Handle("/api/profile/phone", srv.Auth.Middleware(http.HandlerFunc(srv.LinkPhoneHandler))).Methods("POST")
+	r.Handle("/api/profile/phone/verify", srv.Auth.Middleware(http.HandlerFunc(srv.VerifyLinkPhoneHandler))).Methods("POST")
+	r.Handle("/api/profile/email", srv.Auth.Middleware(http.HandlerFunc(srv.LinkEmailHandler))).Methods("POST")
+	return r
+}
+
+// textedCode returns the code in the most recent SMS to phone.
+func textedCode(t *testing.T, outbox *notifications.MemoryStore, phone string) string {
+	all := outbox.All()
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Channel == notifications.ChannelSMS && all[i].To == phone {
+			return all[i].Body[:6]
+		}
+	}
+	t.Fatalf("no code texted to %s", phone)
+	return ""
+}
+
+func TestOTPSignupAndLogin(t *testing.T) {
+	srv := newTestServer()
+	outbox := notifications.NewMemoryStore()
+	srv.Outbox = outbox
+	r := newOTPRouter(srv)
+
+	rr := operatorRequest(r, "POST", "/api/auth/otp/request", "", map[string]string{"phone": "0911 23 45 67"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("otp request: got status %v want %v: %s", rr.Code, http.StatusAccepted, rr.Body.String())
+	}
+	code := textedCode(t, outbox, "+251911234567")
+
+	// A wrong code gives nothing away about the number
+	wrong := "000000"
+	if code == wrong {
+		wrong = "000001"
+	}
+	rr = operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "+251911234567", "code": wrong})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "invalid_code") {
+		t.Fatalf("wrong code without name: got %d %s", rr.Code, rr.Body)
+	}
+
+	// New numbers need a name to sign up; the code stays valid
+	rr = operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "+251911234567", "code": code})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("signup without name: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	rr = operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "251911234567", "code": code, "name": "Almaz"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("otp signup: got status %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var tok tokens
+	json.Unmarshal(rr.Body.Bytes(), &tok)
+
+	rr = operatorRequest(r, "GET", "/api/profile", tok.Token, nil)
+	var profile models.User
+	json.Unmarshal(rr.Body.Bytes(), &profile)
+	if profile.Phone != "+251911234567" || !profile.PhoneVerified || profile.Email != "" {
+		t.Errorf("unexpected profile: %+v", profile)
+	}
+
+	// The code is single use
+	rr = operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "+251911234567", "code": code})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("reused code: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// Logging in again with a new code finds the same account
+	operatorRequest(r, "POST", "/api/auth/otp/request", "", map[string]string{"phone": "+251911234567"})
+	rr = operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "+251911234567", "code": textedCode(t, outbox, "+251911234567")})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("otp login: got status %v want %v", rr.Code, http.StatusOK)
+	}
+	if user, err := srv.Store.GetUserByPhone(context.Background(), "+251911234567"); err != nil || user.Name != "Almaz" {
+		t.Errorf("expected the existing account, got %+v %v", user, err)
+	}
+}
+
+func TestOTPRateLimitAndLockout(t *testing.T) {
+	srv := newTestServer()
+	outbox := notifications.NewMemoryStore()
+	srv.Outbox = outbox
+	r := newOTPRouter(srv)
+	phone := map[string]string{"phone": "+251922000000"}
+
+	if rr := operatorRequest(r, "POST", "/api/auth/otp/request", "", map[string]string{"phone": "+254711000000"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("foreign number: got status %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	for i := 0; i < 3; i++ {
+		if rr := operatorRequest(r, "POST", "/api/auth/otp/request", "", phone); rr.Code != http.StatusAccepted {
+			t.Fatalf("request %d: got status %v want %v", i+1, rr.Code, http.StatusAccepted)
+		}
+	}
+	if rr := operatorRequest(r, "POST", "/api/auth/otp/request", "", phone); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("fourth request: got status %v want %v", rr.Code, http.StatusTooManyRequests)
+	}
+
+	// Five wrong guesses burn the code, even if the right one follows
+	code := textedCode(t, outbox, "+251922000000")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	guess := func(c string) int {
+		return operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "+251922000000", "code": c, "name": "Kebede"}).Code
+	}
+	for i := 0; i < 5; i++ {
+		guess(wrong)
+	}
+	if got := guess(code); got != http.StatusBadRequest {
+		t.Errorf("code after 5 wrong guesses: got status %v want %v", got, http.StatusBadRequest)
+	}
+
+	// Five more failures on earlier codes lock the number
+	for i := 0; i < 5; i++ {
+		srv.Store.RecordOTPFailure(context.Background(), 1)
+	}
+	if got := guess(code); got != http.StatusTooManyRequests {
+		t.Errorf("locked number: got status %v want %v", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestLinkPhoneAndEmail(t *testing.T) {
+	srv := newTestServer()
+	outbox := notifications.NewMemoryStore()
+	srv.Outbox = outbox
+	r := newOTPRouter(srv)
+
+	// An email account adds a phone
+	createPasswordUser(t, srv, "email@example.com", "secret")
+	emailTok := login(t, r, "email@example.com", "secret")
+	rr := operatorRequest(r, "POST", "/api/profile/phone", emailTok.Token, map[string]string{"phone": "0933000000"})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("link phone: got status %v want %v", rr.Code, http.StatusAccepted)
+	}
+	rr = operatorRequest(r, "POST", "/api/profile/phone/verify", emailTok.Token, map[string]string{"phone": "0933000000", "code": textedCode(t, outbox, "+251933000000")})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("verify linked phone: got status %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	linked, _ := srv.Store.GetUserByEmail(context.Background(), "email@example.com")
+	if linked.Phone != "+251933000000" || !linked.PhoneVerified {
+		t.Errorf("expected phone to be linked, got %+v", linked)
+	}
+
+	// Logging in by that phone reaches the same account
+	operatorRequest(r, "POST", "/api/auth/otp/request", "", map[string]string{"phone": "+251933000000"})
+	rr = operatorRequest(r, "POST", "/api/auth/otp/verify", "", map[string]string{"phone": "+251933000000", "code": textedCode(t, outbox, "+251933000000")})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("otp login to linked account: got status %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// A phone account adds an email and can then log in with it
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Phone Only", Phone: "+251944000000", PhoneVerified: true})
+	phoneTok, _ := generateTestToken(srv, userID, "")
+	rr = operatorRequest(r, "POST", "/api/profile/email", phoneTok, map[string]string{"email": "email@example.com", "password": "hunter22"})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("taken email: got status %v want %v", rr.Code, http.StatusConflict)
+	}
+	rr = operatorRequest(r, "POST", "/api/profile/email", phoneTok, map[string]string{"email": "phone.only@example.com", "password": "hunter22"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("link email: got status %v want %v", rr.Code, http.StatusOK)
+	}
+	login(t, r, "phone.only@example.com", "hunter22")
+	mailedToken(t, outbox, "phone.only@example.com")
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ticket-booking-app/backend/jobs"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+)
+
+// JobTripReminder is the job kind for departure reminders.
+const JobTripReminder = "trip_reminder"
+
+// reminderOffsets are how long before departure passengers are reminded.
+var reminderOffsets = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+type reminderPayload struct {
+	BookingID   int `json:"bookingId"`
+	HoursBefore int `json:"hoursBefore"`
+}
+
+func reminderGroup(bookingID int) string {
+	return fmt.Sprintf("booking:%d:reminders", bookingID)
+}
+
+// scheduleReminders schedules the departure reminders of a new booking.
+// Reminders whose time has already passed are skipped.
+func (s *Server) scheduleReminders(ctx context.Context, booking models.Booking, trip models.Trip) {
+	if s.Jobs == nil {
+		return
+	}
+	departure, err := trip.Departure()
+	if err != nil {
+		slog.ErrorContext(ctx, "Cannot schedule reminders", "booking_id", booking.ID, "error", err)
+		return
+	}
+
+	for _, offset := range reminderOffsets {
+		runAt := departure.Add(-offset)
+		if runAt.Before(time.Now()) {
+			continue
+		}
+		hours := int(offset / time.Hour)
+		job, err := jobs.NewJob(JobTripReminder, runAt, reminderPayload{BookingID: booking.ID, HoursBefore: hours})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to build reminder", "booking_id", booking.ID, "error", err)
+			continue
+		}
+		// The departure is part of the key, so that a trip that is moved
+		// gets its reminders again even where they were already sent
+		job.Key = fmt.Sprintf("booking:%d:reminder:%dh:%d", booking.ID, hours, departure.Unix())
+		job.Group = reminderGroup(booking.ID)
+		if _, err := s.Jobs.Schedule(ctx, job); err != nil {
+			slog.ErrorContext(ctx, "Failed to schedule reminder", "hours", hours, "booking_id", booking.ID, "error", err)
+		}
+	}
+}
+
+// cancelReminders removes the pending reminders of a cancelled booking.
+func (s *Server) cancelReminders(ctx context.Context, bookingID int) {
+	if s.Jobs == nil {
+		return
+	}
+	if _, err := s.Jobs.CancelGroup(ctx, reminderGroup(bookingID)); err != nil {
+		slog.ErrorContext(ctx, "Failed to cancel reminders", "booking_id", bookingID, "error", err)
+	}
+}
+
+// rescheduleReminders moves the reminders of the confirmed bookings on a
+// trip whose departure changed from before to after.
+func (s *Server) rescheduleReminders(ctx context.Context, before, after models.Trip) {
+	if s.Jobs == nil {
+		return
+	}
+	from, errBefore := before.Departure()
+	to, errAfter := after.Departure()
+	if errBefore == nil && errAfter == nil && from.Equal(to) {
+		return
+	}
+
+	bookings, err := s.Store.GetBookingsByTrip(ctx, after.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load bookings to reschedule reminders", "trip_id", after.ID, "error", err)
+		return
+	}
+	for _, booking := range bookings {
+		if booking.Status != models.BookingConfirmed {
+			continue
+		}
+		s.cancelReminders(ctx, booking.ID)
+		s.scheduleReminders(ctx, booking, after)
+	}
+}
+
+// SendTripReminder is the job handler for JobTripReminder. It rechecks the
+// booking and trip when it runs, so a reminder that slipped past a
+// cancellation is dropped rather than sent.
+func (s *Server) SendTripReminder(ctx context.Context, job jobs.Job) error {
+	var payload reminderPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	booking, err := s.Store.GetBookingByID(ctx, payload.BookingID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if booking.Status != models.BookingConfirmed {
+		return nil
+	}
+
+	trip, err := s.Store.GetTripByID(ctx, booking.TripID)
+	if err != nil {
+		return err
+	}
+	switch trip.Status {
+	case models.TripCancelled, models.TripDeparted, models.TripArrived:
+		return nil
+	}
+
+	user, err := s.Store.GetUserByID(ctx, booking.UserID)
+	if err != nil {
+		return err
+	}
+	if s.Outbox == nil {
+		return nil
+	}
+
+	data := tripData(trip)
+	data.BookingID = booking.ID
+	data.Seats = booking.Seats
+	data.HoursUntilDeparture = payload.HoursBefore
+	return notifications.Notify(ctx, s.Outbox, recipient(user), notifications.EventTripReminder, data)
+}
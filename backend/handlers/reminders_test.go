@@ -0,0 +1,115 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/jobs"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+)
+
+func TestBookingSchedulesAndCancelsReminders(t *testing.T) {
+	srv := newTestServer()
+	queue := jobs.NewMemoryStore()
+	outbox := notifications.NewMemoryStore()
+	srv.Jobs, srv.Outbox = queue, outbox
+
+	r := newAdminRouter(srv)
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+	adminToken := createAdmin(t, srv)
+
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Passenger", Email: "passenger@example.com", Password: "x"})
+	date := time.Now().In(models.TripLocation).AddDate(0, 0, 3).Format("2006-01-02")
+	trip, err := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: date, DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 2, Seats: []string{"A1", "A2"}})
+	if err != nil {
+		t.Fatalf("Failed to create trip: %v", err)
+	}
+	token, _ := srv.Auth.SignToken(auth.NewClaims(userID, "passenger@example.com", []string{string(auth.RoleCustomer)}, 0, 5*time.Minute))
+
+	rr := operatorRequest(r, "POST", "/api/bookings", token, map[string]interface{}{"trip_id": trip.ID, "seats": []string{"A1"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	departure, _ := models.Trip{Date: date, DepartureTime: "10:00:00"}.Departure()
+	scheduled := queue.All()
+	if len(scheduled) != 2 {
+		t.Fatalf("expected 2 reminders, got %+v", scheduled)
+	}
+	if !scheduled[0].RunAt.Equal(departure.Add(-24*time.Hour)) || !scheduled[1].RunAt.Equal(departure.Add(-2*time.Hour)) {
+		t.Errorf("unexpected reminder times: %v, %v", scheduled[0].RunAt, scheduled[1].RunAt)
+	}
+
+	// Running a reminder queues a notification for the passenger
+	if err := srv.SendTripReminder(context.Background(), scheduled[0]); err != nil {
+		t.Fatalf("SendTripReminder failed: %v", err)
+	}
+	sent := outbox.All()
+	last := sent[len(sent)-1]
+	if last.To != "passenger@example.com" || !strings.Contains(last.Subject, "departs in 24 hours") {
+		t.Errorf("unexpected reminder notification: %+v", last)
+	}
+
+	bookings, _ := srv.Store.GetBookingsByTrip(context.Background(), trip.ID)
+	rr = operatorRequest(r, "POST", "/api/admin/bookings/"+strconv.Itoa(bookings[0].ID)+"/cancel", adminToken, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if left := queue.All(); len(left) != 0 {
+		t.Errorf("expected reminders to be unscheduled, got %+v", left)
+	}
+
+	// A reminder that was already claimed when the booking was cancelled is dropped
+	before := len(outbox.All())
+	if err := srv.SendTripReminder(context.Background(), scheduled[1]); err != nil {
+		t.Fatalf("SendTripReminder failed: %v", err)
+	}
+	if len(outbox.All()) != before {
+		t.Error("expected no reminder for a cancelled booking")
+	}
+}
+
+func TestMovingTripReschedulesReminders(t *testing.T) {
+	srv := newTestServer()
+	queue := jobs.NewMemoryStore()
+	srv.Jobs = queue
+
+	r := newAdminRouter(srv)
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+	adminToken := createAdmin(t, srv)
+
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Passenger", Email: "passenger@example.com", Password: "x"})
+	date := time.Now().In(models.TripLocation).AddDate(0, 0, 3).Format("2006-01-02")
+	trip, err := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: date, DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 2, Seats: []string{"A1", "A2"}})
+	if err != nil {
+		t.Fatalf("Failed to create trip: %v", err)
+	}
+	token, _ := srv.Auth.SignToken(auth.NewClaims(userID, "passenger@example.com", []string{string(auth.RoleCustomer)}, 0, 5*time.Minute))
+	rr := operatorRequest(r, "POST", "/api/bookings", token, map[string]interface{}{"trip_id": trip.ID, "seats": []string{"A1"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("booking: got %d %s", rr.Code, rr.Body)
+	}
+
+	moved := time.Now().In(models.TripLocation).AddDate(0, 0, 5).Format("2006-01-02")
+	rr = operatorRequest(r, "PUT", "/api/admin/trips/"+strconv.Itoa(trip.ID), adminToken, map[string]interface{}{
+		"from": "Addis Ababa", "to": "Adama", "date": moved, "departureTime": "08:00:00", "arrivalTime": "09:30:00", "seatsAvailable": 1, "seats": []string{"A2"},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("moving trip: got %d %s", rr.Code, rr.Body)
+	}
+
+	departure, _ := models.Trip{Date: moved, DepartureTime: "08:00:00"}.Departure()
+	scheduled := queue.All()
+	if len(scheduled) != 2 {
+		t.Fatalf("expected 2 reminders, got %+v", scheduled)
+	}
+	if !scheduled[0].RunAt.Equal(departure.Add(-24*time.Hour)) || !scheduled[1].RunAt.Equal(departure.Add(-2*time.Hour)) {
+		t.Errorf("reminders not moved with the trip: %v, %v", scheduled[0].RunAt, scheduled[1].RunAt)
+	}
+}
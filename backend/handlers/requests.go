@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/validate"
+)
+
+// maxBodyBytes bounds request bodies. The largest requests, trips with
+// their seat maps, stay far below it.
+const maxBodyBytes = 64 << 10
+
+// decode reads the JSON body of r into dst and checks dst's validate rules.
+// Unknown fields, trailing data and bodies over maxBodyBytes are rejected;
+// an empty body decodes as {}. It writes the error response and returns
+// false if the request is invalid.
+func decode(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if err == nil && dec.More() {
+		err = errors.New("trailing data after the JSON body")
+	}
+	if err != nil && err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		var wrongType *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &tooLarge):
+			apierror.Write(w, r, apierror.ErrBodyTooLarge)
+		case errors.As(err, &wrongType) && wrongType.Field != "":
+			apierror.Write(w, r, apierror.ErrValidation.WithDetails(validate.Errors{wrongType.Field: "must be a " + jsonType(wrongType.Type.Kind().String())}))
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			apierror.Write(w, r, apierror.ErrValidation.WithDetails(validate.Errors{field: "is not a known field"}))
+		default:
+			apierror.Write(w, r, apierror.ErrInvalidBody)
+		}
+		return false
+	}
+
+	if errs := validate.Struct(dst); errs != nil {
+		apierror.Write(w, r, apierror.ErrValidation.WithDetails(errs))
+		return false
+	}
+	return true
+}
+
+// jsonType names a Go kind the way API clients know it.
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice" || kind == "array":
+		return "list"
+	case kind == "struct" || kind == "map":
+		return "object"
+	case kind == "bool":
+		return "boolean"
+	}
+	return kind
+}
+
+// Request bodies are decoded into these types rather than into the models,
+// so that clients can only set what a request is meant to change.
+
+type signupRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,password"`
+	Language string `json:"language" validate:"oneof=en am"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email" validate:"required,max=254"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// bookingRequest allows at most 10 seats per booking.
+type bookingRequest struct {
+	TripID int      `json:"trip_id" validate:"required,min=1"`
+	Seats  []string `json:"seats" validate:"required,max=10,unique"`
+}
+
+// userOperatorRequest names the operator a user becomes staff of.
+type userOperatorRequest struct {
+	OperatorID int `json:"operatorId" validate:"required,min=1"`
+}
+
+// waitlistRequest is the seat count a user waits for, at most a booking's
+// worth.
+type waitlistRequest struct {
+	Seats int `json:"seats" validate:"required,min=1,max=10"`
+}
+
+// tripRequest is the body of the admin and operator trip endpoints.
+// Operators cannot choose the operator, it is always their own.
+type tripRequest struct {
+	From              string   `json:"from" validate:"required,max=100"`
+	To                string   `json:"to" validate:"required,max=100"`
+	Date              string   `json:"date" validate:"required,date"`
+	DepartureTime     string   `json:"departureTime" validate:"required,clock"`
+	ArrivalTime       string   `json:"arrivalTime" validate:"clock"`
+	Price             float64  `json:"price" validate:"min=0"`
+	SeatsAvailable    int      `json:"seatsAvailable" validate:"min=0"`
+	BusOperator       string   `json:"busOperator" validate:"max=100"`
+	Duration          string   `json:"duration" validate:"max=20"`
+	Seats             []string `json:"seats" validate:"max=100,unique"`
+	Amenities         []string `json:"amenities" validate:"max=20,unique"`
+	IntermediateStops []string `json:"intermediateStops" validate:"max=20"`
+	OperatorID        int      `json:"operatorId" validate:"min=1"`
+	BusID             int      `json:"busId" validate:"min=1"`
+}
+
+func (t tripRequest) trip() models.Trip {
+	return models.Trip{
+		From:              t.From,
+		To:                t.To,
+		Date:              t.Date,
+		DepartureTime:     t.DepartureTime,
+		ArrivalTime:       t.ArrivalTime,
+		Price:             t.Price,
+		SeatsAvailable:    t.SeatsAvailable,
+		BusOperator:       t.BusOperator,
+		Duration:          t.Duration,
+		Seats:             t.Seats,
+		Amenities:         t.Amenities,
+		IntermediateStops: t.IntermediateStops,
+		OperatorID:        t.OperatorID,
+		BusID:             t.BusID,
+	}
+}
+
+type busRequest struct {
+	PlateNumber string   `json:"plateNumber" validate:"required,max=20"`
+	Capacity    int      `json:"capacity" validate:"min=0,max=100"`
+	Seats       []string `json:"seats" validate:"max=100,unique"`
+	Amenities   []string `json:"amenities" validate:"max=20,unique"`
+}
+
+func (b busRequest) bus() models.Bus {
+	return models.Bus{PlateNumber: b.PlateNumber, Capacity: b.Capacity, Seats: b.Seats, Amenities: b.Amenities}
+}
+
+type tripStatusRequest struct {
+	Status       models.TripStatus `json:"status" validate:"required"`
+	DelayMinutes int               `json:"delayMinutes" validate:"min=0,max=1440"`
+	Reason       string            `json:"reason" validate:"max=500"`
+}
+
+// searchRequest is the query of trip searches. flexibleDateRange is in days
+// either side of date.
+type searchRequest struct {
+	From              string `json:"from" validate:"max=100"`
+	To                string `json:"to" validate:"max=100"`
+	Date              string `json:"date" validate:"date"`
+	FlexibleDateRange int    `json:"flexibleDateRange" validate:"min=0,max=7"`
+}
+
+// reasonRequest is the optional body of cancellations.
+type reasonRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
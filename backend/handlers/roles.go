@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+
+	"github.com/gorilla/mux"
+)
+
+// Role changes take effect at the user's next login, when a new token with
+// the updated roles is issued.
+
+func (s *Server) GetUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.roleTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	roles, err := s.Store.GetUserRoles(r.Context(), userID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"userId": userID, "roles": roles})
+}
+
+func (s *Server) GrantRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.roleTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" validate:"required"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		apierror.Write(w, r, apierror.ErrUnknownRole)
+		return
+	}
+
+	if err := s.Store.GrantRole(r.Context(), userID, req.Role); err != nil {
+		slog.ErrorContext(r.Context(), "Error granting role", "role", req.Role, "user_id", userID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	s.audit(r, "role.grant", "user", userID, map[string]string{"role": req.Role})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role granted successfully"})
+}
+
+func (s *Server) RevokeRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.roleTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	role := mux.Vars(r)["role"]
+	claims := auth.ClaimsFromContext(r.Context())
+	if role == string(auth.RoleAdmin) && claims.UserID == userID {
+		apierror.Write(w, r, apierror.ErrRevokeOwnAdmin)
+		return
+	}
+
+	revoked, err := s.Store.RevokeRole(r.Context(), userID, role)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking role", "role", role, "user_id", userID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	if !revoked {
+		apierror.Write(w, r, apierror.ErrRoleNotAssigned)
+		return
+	}
+	s.audit(r, "role.revoke", "user", userID, map[string]string{"role": role})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role revoked successfully"})
+}
+
+// SetUserOperatorHandler makes a user staff of an operator: the account is
+// attached to the operator and granted the operator role. It takes effect
+// from the user's next access token.
+func (s *Server) SetUserOperatorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.roleTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req userOperatorRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if _, err := s.Store.GetOperatorByID(r.Context(), req.OperatorID); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrOperatorNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+
+	if err := s.Store.SetUserOperator(r.Context(), userID, req.OperatorID); err != nil {
+		serverError(w, r, "Error attaching user to operator", err)
+		return
+	}
+	if err := s.Store.GrantRole(r.Context(), userID, string(auth.RoleOperator)); err != nil {
+		serverError(w, r, "Error granting role", err)
+		return
+	}
+	s.audit(r, "user.set_operator", "user", userID, map[string]int{"operatorId": req.OperatorID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "User attached to operator successfully"})
+}
+
+// RemoveUserOperatorHandler detaches a staff account from its operator and
+// revokes the operator role.
+func (s *Server) RemoveUserOperatorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.roleTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.Store.SetUserOperator(r.Context(), userID, 0); err != nil {
+		serverError(w, r, "Error detaching user from operator", err)
+		return
+	}
+	if _, err := s.Store.RevokeRole(r.Context(), userID, string(auth.RoleOperator)); err != nil {
+		serverError(w, r, "Error revoking role", err)
+		return
+	}
+	s.audit(r, "user.remove_operator", "user", userID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// roleTargetUser resolves the {id} route variable to an existing user.
+func (s *Server) roleTargetUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return 0, false
+	}
+	if _, err := s.Store.GetUserByID(r.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrUserNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/availability"
+)
+
+// keepAlive is how often an idle seat event stream sends a comment, so that
+// proxies do not close it.
+const keepAlive = 25 * time.Second
+
+// publishSeats tells the clients watching a trip that seats changed.
+func (s *Server) publishSeats(ctx context.Context, eventType string, tripID int, seats []string, available int) {
+	if len(seats) == 0 {
+		return
+	}
+	e := availability.Event{Type: eventType, TripID: tripID, Seats: seats, SeatsAvailable: available}
+	if err := s.SeatEvents.Publish(ctx, e); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish seat event", "trip_id", tripID, "type", eventType, "error", err)
+	}
+}
+
+// TripSeatEventsHandler streams the seat availability of a trip as
+// server-sent events: a snapshot of the free seats, then booked, held and
+// released events as they happen. Clients that fall behind are
+// disconnected and start over from a new snapshot when they reconnect.
+func (s *Server) TripSeatEventsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	// Subscribed before reading the seats, so that no change in between is
+	// missed
+	events, unsubscribe := s.SeatEvents.Subscribe(id)
+	defer unsubscribe()
+	trip, err := s.Store.GetTripByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+
+	// The stream outlives the server's write timeout. Where the deadline
+	// cannot be lifted the stream is cut and the client reconnects.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	writeSeatEvent(w, availability.Event{Type: availability.Snapshot, TripID: id, Seats: trip.Seats, SeatsAvailable: trip.SeatsAvailable})
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			writeSeatEvent(w, e)
+		case <-ticker.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		}
+	}
+}
+
+func writeSeatEvent(w io.Writer, e availability.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+}
@@ -0,0 +1,95 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ticket-booking-app/backend/availability"
+	"ticket-booking-app/backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// readSeatEvent reads the next event from a server-sent event stream,
+// skipping comments.
+func readSeatEvent(t *testing.T, stream *bufio.Reader) availability.Event {
+	var e availability.Event
+	var name string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				t.Fatalf("invalid event data %q: %v", line, err)
+			}
+		case line == "" && name != "":
+			if name != e.Type {
+				t.Errorf("event named %q carries type %q", name, e.Type)
+			}
+			return e
+		}
+	}
+}
+
+func TestSeatEventsStreamBookings(t *testing.T) {
+	srv := newTestServer()
+	r := mux.NewRouter()
+	r.HandleFunc("/api/trips/{id}/seats/events", srv.TripSeatEventsHandler).Methods("GET")
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Watcher", Email: "watcher@example.com"})
+	trip, _ := srv.Store.CreateTrip(context.Background(), models.Trip{From: "Addis Ababa", To: "Adama", Date: "2025-09-01", DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 3, Seats: []string{"A1", "A2", "A3"}})
+	token, err := generateTestToken(srv, userID, "watcher@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/trips/9999/seats/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown trip: got status %v want %v", resp.StatusCode, http.StatusNotFound)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/trips/"+strconv.Itoa(trip.ID)+"/seats/events", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	if e := readSeatEvent(t, stream); e.Type != availability.Snapshot || len(e.Seats) != 3 || e.SeatsAvailable != 3 {
+		t.Errorf("unexpected snapshot %+v", e)
+	}
+
+	rr := operatorRequest(r, "POST", "/api/bookings", token, map[string]interface{}{"trip_id": trip.ID, "seats": []string{"A2"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("booking: got %d %s", rr.Code, rr.Body)
+	}
+	e := readSeatEvent(t, stream)
+	if e.Type != availability.Booked || e.TripID != trip.ID || len(e.Seats) != 1 || e.Seats[0] != "A2" || e.SeatsAvailable != 2 {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/availability"
+	"ticket-booking-app/backend/config"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/idempotency"
+	"ticket-booking-app/backend/jobs"
+	"ticket-booking-app/backend/notifications"
+	"ticket-booking-app/backend/ratelimit"
+)
+
+// Server holds what the HTTP handlers depend on; the handlers are its
+// methods. main builds one on PostgreSQL, tests on in-memory stores.
+type Server struct {
+	Store database.Store
+	// Auth signs the access tokens handed out at login and decides which
+	// roles need two-factor authentication.
+	Auth *auth.Auth
+	// Outbox receives the notifications sent by handlers. When nil,
+	// notifications are skipped.
+	Outbox notifications.Store
+	// Jobs receives the background jobs scheduled by handlers. When nil,
+	// reminders are not scheduled.
+	Jobs jobs.Store
+	// Limiter rate-limits login attempts per account. When nil, there is no
+	// limit.
+	Limiter *ratelimit.Limiter
+	// Idempotency replays the responses to retried requests on the routes
+	// main wraps in it. When nil, Idempotency-Key headers are ignored.
+	Idempotency *idempotency.Keys
+	// SeatEvents carries seat changes to the clients watching a trip.
+	SeatEvents *availability.Hub
+	App        config.AppConfig
+}
+
+// NewServer returns a Server on store without notifications, background
+// jobs, rate limits or idempotency keys, delivering seat events within the
+// process.
+func NewServer(store database.Store, authn *auth.Auth, app config.AppConfig) *Server {
+	return &Server{Store: store, Auth: authn, App: app, SeatEvents: availability.NewHub()}
+}
+
+// serverError logs err with msg, tagged with the request ID, and answers
+// 500. The client only learns the request ID to quote in a report.
+func serverError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	slog.ErrorContext(r.Context(), msg, "error", err)
+	apierror.Write(w, r, apierror.ErrInternal)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/models"
+)
+
+const (
+	accessTokenTTL = 5 * time.Minute
+	// refreshTokenTTL bounds a session: it is not extended by refreshing.
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+	Name         string `json:"name"`
+	// MFASetupRequired is set when the user's roles need two-factor
+	// authentication they have not enrolled in yet. Those roles are left
+	// out of the token until they do.
+	MFASetupRequired bool `json:"mfaSetupRequired,omitempty"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// accessToken issues an access token for user bound to a session. Roles are
+// reloaded every time so that grants and revocations take effect on the
+// next refresh. Roles that require two-factor authentication are only
+// included if the session passed it; withheld reports whether any were
+// left out.
+func (s *Server) accessToken(ctx context.Context, user models.User, sessionID int, mfa bool) (token string, withheld bool, err error) {
+	roles, err := s.Store.GetUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", false, err
+	}
+	// Accounts created before roles existed are plain customers
+	if len(roles) == 0 {
+		roles = []string{string(auth.RoleCustomer)}
+	}
+	if !mfa && s.Auth.MFARequired(roles) {
+		roles = s.Auth.WithoutMFARoles(roles)
+		withheld = true
+	}
+	claims := auth.NewClaims(user.ID, user.Email, roles, user.OperatorID, accessTokenTTL)
+	claims.SessionID = sessionID
+	claims.MFA = mfa
+	token, err = s.Auth.SignToken(claims)
+	return token, withheld, err
+}
+
+func (s *Server) writeTokens(w http.ResponseWriter, r *http.Request, user models.User, sessionID int, mfa bool, refreshToken string) {
+	token, withheld, err := s.accessToken(r.Context(), user, sessionID, mfa)
+	if err != nil {
+		serverError(w, r, "Failed to create token", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokenResponse{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int(accessTokenTTL.Seconds()),
+		Name:             user.Name,
+		MFASetupRequired: withheld && !user.TOTPEnabled,
+	})
+}
+
+// startSession opens a new session for an authenticated user and writes its
+// tokens as the response. mfa records whether the login included a second
+// factor.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user models.User, mfa bool) {
+	refreshToken, hash, err := auth.NewToken()
+	if err != nil {
+		serverError(w, r, "Failed to create token", err)
+		return
+	}
+	sessionID, err := s.Store.CreateSession(r.Context(), user.ID, r.UserAgent(), clientIP(r), time.Now().Add(refreshTokenTTL), hash, mfa)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating session", "user_id", user.ID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	s.writeTokens(w, r, user, sessionID, mfa, refreshToken)
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and a
+// new refresh token. The old refresh token stops working.
+func (s *Server) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	refreshToken, hash, err := auth.NewToken()
+	if err != nil {
+		serverError(w, r, "Failed to create token", err)
+		return
+	}
+	session, err := s.Store.RotateRefreshToken(r.Context(), auth.HashToken(req.RefreshToken), hash)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			apierror.Write(w, r, apierror.ErrInvalidRefreshToken)
+		case database.ErrSessionRevoked:
+			apierror.Write(w, r, apierror.ErrSessionExpired)
+		case database.ErrRefreshTokenReused:
+			slog.WarnContext(r.Context(), "Refresh token reuse detected; session revoked")
+			apierror.Write(w, r, apierror.ErrRefreshTokenReused)
+		default:
+			slog.ErrorContext(r.Context(), "Error rotating refresh token", "error", err)
+			apierror.Write(w, r, apierror.ErrInternal)
+		}
+		return
+	}
+
+	user, err := s.Store.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+	s.writeTokens(w, r, user, session.ID, session.MFA, refreshToken)
+}
+
+// LogoutHandler revokes the session of the given refresh token. It does not
+// need a valid access token, so clients can log out after it has expired.
+func (s *Server) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	session, err := s.Store.GetSessionByRefreshToken(r.Context(), auth.HashToken(req.RefreshToken))
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	// Logging out twice, or with an unknown token, is not an error
+	if err == nil {
+		if _, err := s.Store.RevokeSession(r.Context(), session.UserID, session.ID); err != nil {
+			serverError(w, r, "Database error", err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+func (s *Server) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromContext(r.Context())
+	sessions, err := s.Store.ListSessions(r.Context(), claims.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing sessions", "user_id", claims.UserID, "error", err)
+		apierror.Write(w, r, apierror.ErrInternal)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSessionHandler logs one of the caller's devices out. Access tokens
+// of that session are rejected from the next request on.
+func (s *Server) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+
+	revoked, err := s.Store.RevokeSession(r.Context(), claims.UserID, id)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+	if !revoked {
+		apierror.Write(w, r, apierror.ErrSessionNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKSHandler publishes the public keys access tokens can be verified with,
+// so that other services need not share a secret with this one.
+func (s *Server) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string][]auth.JWK{"keys": s.Auth.Keys.JWKS()})
+}
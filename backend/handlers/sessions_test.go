@@ -0,0 +1,193 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/ratelimit"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func newSessionRouter(srv *handlers.Server) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/auth/login", srv.LoginHandler).Methods("POST")
+	r.HandleFunc("/api/auth/refresh", srv.RefreshTokenHandler).Methods("POST")
+	r.HandleFunc("/api/auth/logout", srv.LogoutHandler).Methods("POST")
+	r.Handle("/api/sessions", srv.Auth.Middleware(http.HandlerFunc(srv.ListSessionsHandler))).Methods("GET")
+	r.Handle("/api/sessions/{id}", srv.Auth.Middleware(http.HandlerFunc(srv.RevokeSessionHandler))).Methods("DELETE")
+	return r
+}
+
+func login(t *testing.T, r *mux.Router, email, password string) tokens {
+	body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req := httptest.NewRequest("POST", "/api/auth/login", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var tok tokens
+	json.Unmarshal(rr.Body.Bytes(), &tok)
+	if tok.Token == "" || tok.RefreshToken == "" {
+		t.Fatalf("expected access and refresh tokens, got %s", rr.Body.String())
+	}
+	return tok
+}
+
+func createPasswordUser(t *testing.T, srv *handlers.Server, email, password string) {
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if _, err := srv.Store.CreateUser(context.Background(), models.User{Name: "Session User", Email: email, Password: string(hashed)}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+}
+
+func TestRefreshTokenRotationAndReuse(t *testing.T) {
+	srv := newTestServer()
+	r := newSessionRouter(srv)
+	createPasswordUser(t, srv, "session@example.com", "secret")
+	first := login(t, r, "session@example.com", "secret")
+
+	rr := operatorRequest(r, "POST", "/api/auth/refresh", "", map[string]string{"refreshToken": first.RefreshToken})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("refresh returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var second tokens
+	json.Unmarshal(rr.Body.Bytes(), &second)
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected a rotated refresh token, got %+v", second)
+	}
+
+	if rr := operatorRequest(r, "GET", "/api/sessions", second.Token, nil); rr.Code != http.StatusOK {
+		t.Fatalf("list sessions returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// Replaying the first token revokes the whole session
+	rr = operatorRequest(r, "POST", "/api/auth/refresh", "", map[string]string{"refreshToken": first.RefreshToken})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	rr = operatorRequest(r, "POST", "/api/auth/refresh", "", map[string]string{"refreshToken": second.RefreshToken})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse detection: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := operatorRequest(r, "GET", "/api/sessions", second.Token, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("access token of revoked session: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	srv := newTestServer()
+	r := newSessionRouter(srv)
+	createPasswordUser(t, srv, "devices@example.com", "secret")
+	phone := login(t, r, "devices@example.com", "secret")
+	laptop := login(t, r, "devices@example.com", "secret")
+
+	rr := operatorRequest(r, "GET", "/api/sessions", laptop.Token, nil)
+	var sessions []models.Session
+	json.Unmarshal(rr.Body.Bytes(), &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	var phoneID int
+	for _, s := range sessions {
+		if !s.Current {
+			phoneID = s.ID
+		}
+	}
+
+	rr = operatorRequest(r, "DELETE", "/api/sessions/"+strconv.Itoa(phoneID), laptop.Token, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("revoke returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := operatorRequest(r, "GET", "/api/sessions", phone.Token, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session's access token: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	rr = operatorRequest(r, "POST", "/api/auth/logout", "", map[string]string{"refreshToken": laptop.RefreshToken})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("logout returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	rr = operatorRequest(r, "POST", "/api/auth/refresh", "", map[string]string{"refreshToken": laptop.RefreshToken})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: got status %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginFailuresLookTheSameAndLockOut(t *testing.T) {
+	srv := newTestServer()
+	r := newSessionRouter(srv)
+	createPasswordUser(t, srv, "guarded@example.com", "secret")
+
+	attempt := func(email, password string) (int, string) {
+		rr := operatorRequest(r, "POST", "/api/auth/login", "", map[string]string{"email": email, "password": password})
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body.Error.Code + ": " + body.Error.Message
+	}
+
+	wrongStatus, wrongPassword := attempt("guarded@example.com", "guess")
+	unknownStatus, unknownUser := attempt("nobody@example.com", "guess")
+	if wrongStatus != http.StatusUnauthorized || unknownStatus != wrongStatus || unknownUser != wrongPassword {
+		t.Errorf("unknown user answered %d %q, wrong password %d %q", unknownStatus, unknownUser, wrongStatus, wrongPassword)
+	}
+
+	// Both addresses reach the lockout threshold; the right password no
+	// longer helps, and unknown addresses are locked out alike
+	for i := 0; i < 4; i++ {
+		attempt("Guarded@example.com", "guess")
+		attempt("nobody@example.com", "guess")
+	}
+	if status, _ := attempt("guarded@example.com", "secret"); status != http.StatusTooManyRequests {
+		t.Errorf("locked account: got %d want %d", status, http.StatusTooManyRequests)
+	}
+	if status, _ := attempt("nobody@example.com", "guess"); status != http.StatusTooManyRequests {
+		t.Errorf("locked unknown address: got %d want %d", status, http.StatusTooManyRequests)
+	}
+
+	// A successful login starts the count again
+	createPasswordUser(t, srv, "forgetful@example.com", "secret")
+	for i := 0; i < 4; i++ {
+		attempt("forgetful@example.com", "guess")
+	}
+	login(t, r, "forgetful@example.com", "secret")
+	for i := 0; i < 4; i++ {
+		attempt("forgetful@example.com", "guess")
+	}
+	login(t, r, "forgetful@example.com", "secret")
+}
+
+func TestLoginAttemptsAreRateLimitedPerAccount(t *testing.T) {
+	srv := newTestServer()
+	srv.Limiter = &ratelimit.Limiter{Store: ratelimit.NewMemoryStore(), PerAccount: ratelimit.PerMinute(2)}
+	r := newSessionRouter(srv)
+	createPasswordUser(t, srv, "popular@example.com", "secret")
+
+	login(t, r, "popular@example.com", "secret")
+	login(t, r, "popular@example.com", "secret")
+	rr := operatorRequest(r, "POST", "/api/auth/login", "", map[string]string{"email": "POPULAR@example.com", "password": "secret"})
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("got %d with Retry-After %q, want %d", rr.Code, rr.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	createPasswordUser(t, srv, "other@example.com", "secret")
+	login(t, r, "other@example.com", "secret")
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/metrics"
+	"ticket-booking-app/backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetTripStatusHandler is public so that passengers and their families can
+// check a bus without logging in.
+func (s *Server) GetTripStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrInvalidID)
+		return
+	}
+
+	status, err := s.Store.GetTripStatus(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// applyTripStatus performs a status transition, notifies affected passengers
+// and writes the error response if it fails.
+func (s *Server) applyTripStatus(w http.ResponseWriter, r *http.Request, update models.TripStatusUpdate) (models.TripStatusUpdate, bool) {
+	update, cancelled, ended, err := s.Store.UpdateTripStatus(r.Context(), update)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		case database.ErrInvalidTransition:
+			apierror.Write(w, r, apierror.ErrInvalidStatusTransition.WithDetails(map[string]string{"status": string(update.Status)}))
+		default:
+			slog.ErrorContext(r.Context(), "Error updating trip status", "trip_id", update.TripID, "error", err)
+			apierror.Write(w, r, apierror.ErrInternal)
+		}
+		return update, false
+	}
+
+	if len(cancelled) > 0 {
+		metrics.BookingsCancelled(metrics.CancelledWithTrip, len(cancelled))
+		slog.InfoContext(r.Context(), "Trip cancelled; bookings cancelled and marked refundable", "trip_id", update.TripID, "bookings", len(cancelled))
+	}
+	for _, booking := range cancelled {
+		s.cancelReminders(r.Context(), booking.ID)
+	}
+	for _, entry := range ended {
+		if entry.OfferExpiresAt != nil {
+			s.cancelOfferExpiry(r.Context(), entry.ID)
+		}
+	}
+	s.notifyTripStatus(r.Context(), update, cancelled, ended)
+	return update, true
+}
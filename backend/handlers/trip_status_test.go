@@ -0,0 +1,135 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+)
+
+func TestTripStatusLifecycle(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+	r.HandleFunc("/api/trips/{id}/status", srv.GetTripStatusHandler).Methods("GET")
+
+	token, _, trip := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+	statusPath := "/api/operator/trips/" + strconv.Itoa(trip.ID) + "/status"
+	publicPath := "/api/trips/" + strconv.Itoa(trip.ID) + "/status"
+
+	rr := operatorRequest(r, "PUT", statusPath, token, map[string]interface{}{"status": models.TripDelayed, "delayMinutes": 45, "reason": "Road works near Mojo"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	rr = operatorRequest(r, "GET", publicPath, "", nil)
+	var status models.TripStatusUpdate
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if status.Status != models.TripDelayed || status.DelayMinutes != 45 || status.Reason != "Road works near Mojo" {
+		t.Errorf("unexpected public status: %+v", status)
+	}
+
+	// Delays need a duration
+	rr = operatorRequest(r, "PUT", statusPath, token, map[string]interface{}{"status": models.TripDelayed})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for delay without minutes: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// A delayed trip cannot skip straight to arrived
+	rr = operatorRequest(r, "PUT", statusPath, token, map[string]interface{}{"status": models.TripArrived})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code for invalid transition: got %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	for _, next := range []models.TripStatus{models.TripBoarding, models.TripDeparted, models.TripArrived} {
+		rr = operatorRequest(r, "PUT", statusPath, token, map[string]interface{}{"status": next})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("transition to %s: got status %v want %v", next, rr.Code, http.StatusOK)
+		}
+	}
+
+	rr = operatorRequest(r, "GET", publicPath, "", nil)
+	json.Unmarshal(rr.Body.Bytes(), &status)
+	if status.Status != models.TripArrived || status.DelayMinutes != 0 {
+		t.Errorf("expected arrived with delay cleared, got %+v", status)
+	}
+}
+
+func TestCancelTripCascadesToBookings(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+	outbox := notifications.NewMemoryStore()
+	srv.Outbox = outbox
+
+	token, _, trip := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Passenger", Email: "passenger@example.com", Password: "x"})
+	bookingID, err := srv.Store.CreateBooking(context.Background(), models.Booking{UserID: userID, TripID: trip.ID, Seats: []string{"A1"}})
+	if err != nil {
+		t.Fatalf("Failed to create booking: %v", err)
+	}
+	waiterID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Waiter", Email: "waiter@example.com", Password: "x"})
+	if _, err := srv.Store.JoinWaitlist(context.Background(), trip.ID, waiterID, 1); err != nil {
+		t.Fatalf("Failed to join waitlist: %v", err)
+	}
+
+	rr := operatorRequest(r, "POST", "/api/operator/trips/"+strconv.Itoa(trip.ID)+"/cancel", token, map[string]string{"reason": "Bus breakdown"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	booking, err := srv.Store.GetBookingByID(context.Background(), bookingID)
+	if err != nil {
+		t.Fatalf("Failed to get booking: %v", err)
+	}
+	if booking.Status != models.BookingCancelled || !booking.RefundEligible {
+		t.Errorf("expected booking cancelled with refund eligibility, got %+v", booking)
+	}
+
+	sent := outbox.All()
+	if len(sent) != 2 || sent[0].To != "passenger@example.com" || !strings.Contains(sent[0].Body, "Bus breakdown") {
+		t.Fatalf("expected a cancellation email to the passenger, got %+v", sent)
+	}
+	if sent[1].To != "waiter@example.com" || !strings.Contains(sent[1].Body, "waitlist") || !strings.Contains(sent[1].Body, "Bus breakdown") {
+		t.Errorf("expected the waitlisted user to be told the trip is cancelled, got %+v", sent[1])
+	}
+
+	// Cancelled is terminal
+	rr = operatorRequest(r, "PUT", "/api/operator/trips/"+strconv.Itoa(trip.ID)+"/status", token, map[string]interface{}{"status": models.TripScheduled})
+	if rr.Code != http.StatusConflict {
+		t.Errorf("handler returned wrong status code for reviving a cancelled trip: got %v want %v", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestBookingCancelledTripRejected(t *testing.T) {
+	srv := newTestServer()
+	r := newOperatorRouter(srv)
+	r.Handle("/api/bookings", srv.Auth.Middleware(http.HandlerFunc(srv.CreateBookingHandler))).Methods("POST")
+
+	token, _, trip := createOperatorStaff(t, srv, "Selam Bus", "staff@selam.example.com")
+	rr := operatorRequest(r, "POST", "/api/operator/trips/"+strconv.Itoa(trip.ID)+"/cancel", token, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("cancel: got %d %s", rr.Code, rr.Body)
+	}
+
+	userID, _ := srv.Store.CreateUser(context.Background(), models.User{Name: "Passenger", Email: "passenger@example.com", Password: "x"})
+	passengerToken, err := generateTestToken(srv, userID, "passenger@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	rr = operatorRequest(r, "POST", "/api/bookings", passengerToken, map[string]interface{}{"trip_id": trip.ID, "seats": []string{"A1"}})
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "trip_not_bookable") {
+		t.Errorf("booking a cancelled trip: got %d %s", rr.Code, rr.Body)
+	}
+	if bookings, _ := srv.Store.GetBookingsByTrip(context.Background(), trip.ID); len(bookings) != 0 {
+		t.Errorf("expected no bookings on the cancelled trip, got %+v", bookings)
+	}
+	if cancelled, _ := srv.Store.GetTripByID(context.Background(), trip.ID); len(cancelled.Seats) != 2 {
+		t.Errorf("expected the seats to be left alone, got %v", cancelled.Seats)
+	}
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/availability"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/jobs"
+	"ticket-booking-app/backend/metrics"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+)
+
+// JobWaitlistOfferExpiry is the job kind that passes seats held for a
+// waitlisted user on to the next one when the hold runs out.
+const JobWaitlistOfferExpiry = "waitlist_offer_expiry"
+
+type offerExpiryPayload struct {
+	EntryID int `json:"entryId"`
+}
+
+func waitlistGroup(entryID int) string {
+	return fmt.Sprintf("waitlist:%d", entryID)
+}
+
+// offerWaitlist holds the free seats of a trip for the users waiting for
+// them, in the order they joined, and tells them. It is called whenever
+// seats are freed.
+func (s *Server) offerWaitlist(ctx context.Context, tripID int) {
+	trip, err := s.Store.GetTripByID(ctx, tripID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load trip for waitlist", "trip_id", tripID, "error", err)
+		return
+	}
+	switch trip.Status {
+	case models.TripCancelled, models.TripDeparted, models.TripArrived:
+		return
+	}
+
+	offers, err := s.Store.OfferWaitlistSeats(ctx, tripID, time.Now().Add(s.App.WaitlistHold))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to offer seats to the waitlist", "trip_id", tripID, "error", err)
+		return
+	}
+	available := trip.SeatsAvailable
+	for _, entry := range offers {
+		available -= len(entry.OfferedSeats)
+		s.publishSeats(ctx, availability.Held, tripID, entry.OfferedSeats, available)
+		s.scheduleOfferExpiry(ctx, entry)
+
+		user, err := s.Store.GetUserByID(ctx, entry.UserID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load user for notification", "user_id", entry.UserID, "event", notifications.EventWaitlistOffer, "error", err)
+			continue
+		}
+		data := tripData(trip)
+		data.Seats = entry.OfferedSeats
+		data.HoldMinutes = int(s.App.WaitlistHold / time.Minute)
+		data.Link = s.App.URL + "/booking/" + strconv.Itoa(tripID)
+		s.notify(ctx, user, notifications.EventWaitlistOffer, data)
+	}
+}
+
+// scheduleOfferExpiry schedules the end of the hold on the seats offered to
+// entry.
+func (s *Server) scheduleOfferExpiry(ctx context.Context, entry models.WaitlistEntry) {
+	if s.Jobs == nil {
+		return
+	}
+	job, err := jobs.NewJob(JobWaitlistOfferExpiry, *entry.OfferExpiresAt, offerExpiryPayload{EntryID: entry.ID})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to build offer expiry", "waitlist_id", entry.ID, "error", err)
+		return
+	}
+	job.Key = waitlistGroup(entry.ID) + ":expiry"
+	job.Group = waitlistGroup(entry.ID)
+	if _, err := s.Jobs.Schedule(ctx, job); err != nil {
+		slog.ErrorContext(ctx, "Failed to schedule offer expiry", "waitlist_id", entry.ID, "error", err)
+	}
+}
+
+// cancelOfferExpiry removes the pending expiry of an offer that was taken
+// up or turned down.
+func (s *Server) cancelOfferExpiry(ctx context.Context, entryID int) {
+	if s.Jobs == nil {
+		return
+	}
+	if _, err := s.Jobs.CancelGroup(ctx, waitlistGroup(entryID)); err != nil {
+		slog.ErrorContext(ctx, "Failed to cancel offer expiry", "waitlist_id", entryID, "error", err)
+	}
+}
+
+// releaseOffer returns the seats of an ended offer to sale and offers them
+// to the next users waiting.
+func (s *Server) releaseOffer(ctx context.Context, entry models.WaitlistEntry) {
+	if trip, err := s.Store.GetTripByID(ctx, entry.TripID); err == nil {
+		s.publishSeats(ctx, availability.Released, trip.ID, entry.OfferedSeats, trip.SeatsAvailable)
+	}
+	s.offerWaitlist(ctx, entry.TripID)
+}
+
+// ExpireWaitlistOffer is the job handler for JobWaitlistOfferExpiry. Offers
+// confirmed or withdrawn in the meantime are left alone.
+func (s *Server) ExpireWaitlistOffer(ctx context.Context, job jobs.Job) error {
+	var payload offerExpiryPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+
+	entry, err := s.Store.ExpireWaitlistOffer(ctx, payload.EntryID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.releaseOffer(ctx, entry)
+	return nil
+}
+
+// waitlistEntry loads the caller's active entry for the trip in the path.
+// It writes the error response and returns false if there is none.
+func (s *Server) waitlistEntry(w http.ResponseWriter, r *http.Request) (models.WaitlistEntry, bool) {
+	tripID, ok := pathID(w, r, "id")
+	if !ok {
+		return models.WaitlistEntry{}, false
+	}
+	claims := auth.ClaimsFromContext(r.Context())
+	entry, err := s.Store.GetWaitlistEntry(r.Context(), tripID, claims.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrNotWaitlisted)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return entry, false
+	}
+	return entry, true
+}
+
+// JoinWaitlistHandler puts the caller on the waitlist of a trip that does
+// not have the seats they want. It answers with their entry and position.
+func (s *Server) JoinWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	tripID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req waitlistRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+	if !s.mayBook(w, r, user) {
+		return
+	}
+
+	trip, err := s.Store.GetTripByID(r.Context(), tripID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrTripNotFound)
+		} else {
+			serverError(w, r, "Database error", err)
+		}
+		return
+	}
+	if !trip.Status.Bookable() {
+		apierror.Write(w, r, apierror.ErrTripNotBookable)
+		return
+	}
+	if len(trip.Seats) >= req.Seats {
+		apierror.Write(w, r, apierror.ErrSeatsAvailable)
+		return
+	}
+
+	if _, err := s.Store.JoinWaitlist(r.Context(), tripID, user.ID, req.Seats); err != nil {
+		switch err {
+		case database.ErrAlreadyWaitlisted:
+			apierror.Write(w, r, apierror.ErrAlreadyWaitlisted)
+		case database.ErrTripNotBookable:
+			apierror.Write(w, r, apierror.ErrTripNotBookable)
+		default:
+			serverError(w, r, "Failed to join waitlist", err)
+		}
+		return
+	}
+	entry, err := s.Store.GetWaitlistEntry(r.Context(), tripID, user.ID)
+	if err != nil {
+		serverError(w, r, "Database error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// GetWaitlistHandler answers with the caller's entry on the waitlist of a
+// trip: their position while waiting, or the seats held for them.
+func (s *Server) GetWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.waitlistEntry(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// LeaveWaitlistHandler takes the caller off the waitlist of a trip. Seats
+// held for them go to the next users waiting.
+func (s *Server) LeaveWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.waitlistEntry(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := s.Store.LeaveWaitlist(r.Context(), entry.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			apierror.Write(w, r, apierror.ErrNotWaitlisted)
+		} else {
+			serverError(w, r, "Failed to leave waitlist", err)
+		}
+		return
+	}
+	if entry.Status == models.WaitlistOffered {
+		s.cancelOfferExpiry(r.Context(), entry.ID)
+		s.releaseOffer(r.Context(), entry)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConfirmWaitlistOfferHandler books the seats held for the caller, as long
+// as the hold has not expired.
+func (s *Server) ConfirmWaitlistOfferHandler(w http.ResponseWriter, r *http.Request) {
+	claims := auth.ClaimsFromContext(r.Context())
+	user, err := s.Store.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		apierror.Write(w, r, apierror.ErrUnknownUser)
+		return
+	}
+	if !s.mayBook(w, r, user) {
+		return
+	}
+	entry, ok := s.waitlistEntry(w, r)
+	if !ok {
+		return
+	}
+
+	booking, err := s.Store.ConfirmWaitlistOffer(r.Context(), entry.ID, time.Now())
+	if err != nil {
+		switch err {
+		case database.ErrNoWaitlistOffer:
+			apierror.Write(w, r, apierror.ErrNoWaitlistOffer)
+		case database.ErrTripNotBookable:
+			apierror.Write(w, r, apierror.ErrTripNotBookable)
+		default:
+			serverError(w, r, "Failed to create booking", err)
+		}
+		return
+	}
+	metrics.BookingCreated(len(booking.Seats))
+	s.cancelOfferExpiry(r.Context(), entry.ID)
+
+	if trip, err := s.Store.GetTripByID(r.Context(), booking.TripID); err == nil {
+		s.publishSeats(r.Context(), availability.Booked, trip.ID, booking.Seats, trip.SeatsAvailable)
+		data := tripData(trip)
+		data.BookingID = booking.ID
+		data.Seats = booking.Seats
+		s.notify(r.Context(), user, notifications.EventBookingConfirmed, data)
+		s.scheduleReminders(r.Context(), booking, trip)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Booking created successfully", "booking": booking})
+}
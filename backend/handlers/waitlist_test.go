@@ -0,0 +1,229 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/jobs"
+	"ticket-booking-app/backend/models"
+	"ticket-booking-app/backend/notifications"
+)
+
+func TestWaitlistOffersFreedSeatsInTurn(t *testing.T) {
+	srv := newTestServer()
+	queue := jobs.NewMemoryStore()
+	outbox := notifications.NewMemoryStore()
+	srv.Jobs, srv.Outbox = queue, outbox
+
+	r := newAdminRouter(srv)
+	r.Handle("/api/trips/{id}/waitlist", srv.Auth.Middleware(http.HandlerFunc(srv.JoinWaitlistHandler))).Methods("POST")
+	r.Handle("/api/trips/{id}/waitlist", srv.Auth.Middleware(http.HandlerFunc(srv.GetWaitlistHandler))).Methods("GET")
+	r.Handle("/api/trips/{id}/waitlist", srv.Auth.Middleware(http.HandlerFunc(srv.LeaveWaitlistHandler))).Methods("DELETE")
+	r.Handle("/api/trips/{id}/waitlist/confirm", srv.Auth.Middleware(http.HandlerFunc(srv.ConfirmWaitlistOfferHandler))).Methods("POST")
+	adminToken := createAdmin(t, srv)
+
+	ctx := context.Background()
+	customer := func(name string) (int, string) {
+		email := strings.ToLower(name) + "@example.com"
+		userID, err := srv.Store.CreateUser(ctx, models.User{Name: name, Email: email, Password: "x"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		token, _ := srv.Auth.SignToken(auth.NewClaims(userID, email, []string{string(auth.RoleCustomer)}, 0, 5*time.Minute))
+		return userID, token
+	}
+	holderID, _ := customer("Holder")
+	_, firstToken := customer("First")
+	_, secondToken := customer("Second")
+
+	date := time.Now().In(models.TripLocation).AddDate(0, 0, 3).Format("2006-01-02")
+	trip, err := srv.Store.CreateTrip(ctx, models.Trip{From: "Addis Ababa", To: "Adama", Date: date, DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 2, Seats: []string{"A1", "A2"}})
+	if err != nil {
+		t.Fatalf("Failed to create trip: %v", err)
+	}
+	bookingID, _ := srv.Store.CreateBooking(ctx, models.Booking{UserID: holderID, TripID: trip.ID, Seats: []string{"A1", "A2"}})
+	path := "/api/trips/" + strconv.Itoa(trip.ID) + "/waitlist"
+
+	entryOf := func(token string) (models.WaitlistEntry, int) {
+		rr := operatorRequest(r, "GET", path, token, nil)
+		var entry models.WaitlistEntry
+		json.Unmarshal(rr.Body.Bytes(), &entry)
+		return entry, rr.Code
+	}
+
+	rr := operatorRequest(r, "POST", path, firstToken, map[string]int{"seats": 2})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("join: got %d %s", rr.Code, rr.Body)
+	}
+	rr = operatorRequest(r, "POST", path, secondToken, map[string]int{"seats": 1})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("join: got %d %s", rr.Code, rr.Body)
+	}
+	if entry, _ := entryOf(secondToken); entry.Status != models.WaitlistWaiting || entry.Position != 2 {
+		t.Errorf("expected second in line, got %+v", entry)
+	}
+	if rr := operatorRequest(r, "POST", path, firstToken, map[string]int{"seats": 1}); rr.Code != http.StatusConflict {
+		t.Errorf("joining twice: got status %v want %v", rr.Code, http.StatusConflict)
+	}
+
+	// Cancelling the booking holds its seats for the first in line
+	rr = operatorRequest(r, "POST", "/api/admin/bookings/"+strconv.Itoa(bookingID)+"/cancel", adminToken, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("cancel: got %d %s", rr.Code, rr.Body)
+	}
+	first, _ := entryOf(firstToken)
+	if first.Status != models.WaitlistOffered || len(first.OfferedSeats) != 2 || first.OfferExpiresAt == nil {
+		t.Fatalf("expected seats offered to the first in line, got %+v", first)
+	}
+	if entry, _ := entryOf(secondToken); entry.Status != models.WaitlistWaiting || entry.Position != 1 {
+		t.Errorf("expected second to move up, got %+v", entry)
+	}
+	if held, _ := srv.Store.GetTripByID(ctx, trip.ID); held.SeatsAvailable != 0 || len(held.Seats) != 0 {
+		t.Errorf("expected held seats off sale, got %d available %v", held.SeatsAvailable, held.Seats)
+	}
+	sent := outbox.All()
+	if last := sent[len(sent)-1]; last.To != "first@example.com" || !strings.Contains(last.Body, "/booking/"+strconv.Itoa(trip.ID)) {
+		t.Errorf("unexpected offer notification: %+v", last)
+	}
+
+	var expiry jobs.Job
+	for _, job := range queue.All() {
+		if job.Kind == handlers.JobWaitlistOfferExpiry {
+			expiry = job
+		}
+	}
+	if !expiry.RunAt.Equal(*first.OfferExpiresAt) {
+		t.Fatalf("expected the offer expiry to be scheduled, got %+v", queue.All())
+	}
+
+	// The first in line lets the hold run out, so the next is offered a seat
+	if err := srv.ExpireWaitlistOffer(ctx, expiry); err != nil {
+		t.Fatalf("ExpireWaitlistOffer failed: %v", err)
+	}
+	if _, code := entryOf(firstToken); code != http.StatusNotFound {
+		t.Errorf("expired entry: got status %v want %v", code, http.StatusNotFound)
+	}
+	second, _ := entryOf(secondToken)
+	if second.Status != models.WaitlistOffered || len(second.OfferedSeats) != 1 {
+		t.Fatalf("expected a seat offered to the second in line, got %+v", second)
+	}
+	if rr := operatorRequest(r, "POST", path+"/confirm", firstToken, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("confirming an expired offer: got status %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr = operatorRequest(r, "POST", path+"/confirm", secondToken, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("confirm: got %d %s", rr.Code, rr.Body)
+	}
+	var resp struct {
+		Booking models.Booking `json:"booking"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Booking.ID == 0 || len(resp.Booking.Seats) != 1 || resp.Booking.Seats[0] != second.OfferedSeats[0] {
+		t.Errorf("unexpected booking %+v", resp.Booking)
+	}
+	if updated, _ := srv.Store.GetTripByID(ctx, trip.ID); updated.SeatsAvailable != 1 || len(updated.Seats) != 1 {
+		t.Errorf("expected one seat left for sale, got %d available %v", updated.SeatsAvailable, updated.Seats)
+	}
+	for _, job := range queue.All() {
+		if job.Kind == handlers.JobWaitlistOfferExpiry && job.ID != expiry.ID {
+			t.Errorf("expected the confirmed offer's expiry to be cancelled, got %+v", job)
+		}
+	}
+
+	// With a seat free, joining the waitlist for one is refused
+	if rr := operatorRequest(r, "POST", path, firstToken, map[string]int{"seats": 1}); rr.Code != http.StatusConflict {
+		t.Errorf("joining with seats free: got status %v want %v", rr.Code, http.StatusConflict)
+	}
+	rr = operatorRequest(r, "POST", path, firstToken, map[string]int{"seats": 2})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("join: got %d %s", rr.Code, rr.Body)
+	}
+	if rr := operatorRequest(r, "DELETE", path, firstToken, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("leave: got status %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if _, code := entryOf(firstToken); code != http.StatusNotFound {
+		t.Errorf("left entry: got status %v want %v", code, http.StatusNotFound)
+	}
+}
+
+func TestWaitlistClosesWithTrip(t *testing.T) {
+	srv := newTestServer()
+	r := newAdminRouter(srv)
+	r.Handle("/api/trips/{id}/waitlist", srv.Auth.Middleware(http.HandlerFunc(srv.JoinWaitlistHandler))).Methods("POST")
+	r.Handle("/api/trips/{id}/waitlist", srv.Auth.Middleware(http.HandlerFunc(srv.GetWaitlistHandler))).Methods("GET")
+	r.Handle("/api/trips/{id}/waitlist/confirm", srv.Auth.Middleware(http.HandlerFunc(srv.ConfirmWaitlistOfferHandler))).Methods("POST")
+
+	ctx := context.Background()
+	customer := func(name string) string {
+		email := strings.ToLower(name) + "@example.com"
+		userID, err := srv.Store.CreateUser(ctx, models.User{Name: name, Email: email, Password: "x"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		token, _ := srv.Auth.SignToken(auth.NewClaims(userID, email, []string{string(auth.RoleCustomer)}, 0, 5*time.Minute))
+		return token
+	}
+	firstToken, secondToken, lateToken := customer("First"), customer("Second"), customer("Late")
+
+	date := time.Now().In(models.TripLocation).AddDate(0, 0, 3).Format("2006-01-02")
+	soldOut := func() (models.Trip, string) {
+		trip, err := srv.Store.CreateTrip(ctx, models.Trip{From: "Addis Ababa", To: "Adama", Date: date, DepartureTime: "10:00:00", ArrivalTime: "11:30:00", SeatsAvailable: 0, Seats: []string{}})
+		if err != nil {
+			t.Fatalf("Failed to create trip: %v", err)
+		}
+		path := "/api/trips/" + strconv.Itoa(trip.ID) + "/waitlist"
+		for _, token := range []string{firstToken, secondToken} {
+			if rr := operatorRequest(r, "POST", path, token, map[string]int{"seats": 1}); rr.Code != http.StatusCreated {
+				t.Fatalf("join: got %d %s", rr.Code, rr.Body)
+			}
+		}
+		return trip, path
+	}
+	setStatus := func(tripID int, statuses ...models.TripStatus) {
+		for _, status := range statuses {
+			if _, _, _, err := srv.Store.UpdateTripStatus(ctx, models.TripStatusUpdate{TripID: tripID, Status: status}); err != nil {
+				t.Fatalf("UpdateTripStatus(%s) failed: %v", status, err)
+			}
+		}
+	}
+
+	// Cancelling the trip ends the waitlist, offered and waiting alike
+	trip, path := soldOut()
+	srv.Store.AdjustTripSeats(ctx, trip.ID, []string{"A1"})
+	if _, err := srv.Store.OfferWaitlistSeats(ctx, trip.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("OfferWaitlistSeats failed: %v", err)
+	}
+	setStatus(trip.ID, models.TripCancelled)
+	for _, token := range []string{firstToken, secondToken} {
+		if rr := operatorRequest(r, "GET", path, token, nil); rr.Code != http.StatusNotFound {
+			t.Errorf("entry after cancellation: got status %v want %v", rr.Code, http.StatusNotFound)
+		}
+	}
+	if rr := operatorRequest(r, "POST", path+"/confirm", firstToken, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("confirming after cancellation: got status %v want %v", rr.Code, http.StatusNotFound)
+	}
+	rr := operatorRequest(r, "POST", path, lateToken, map[string]int{"seats": 1})
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "trip_not_bookable") {
+		t.Errorf("joining a cancelled trip: got %d %s", rr.Code, rr.Body)
+	}
+
+	// An offer outstanding when the bus leaves can no longer be booked
+	trip, path = soldOut()
+	srv.Store.AdjustTripSeats(ctx, trip.ID, []string{"A1"})
+	if _, err := srv.Store.OfferWaitlistSeats(ctx, trip.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("OfferWaitlistSeats failed: %v", err)
+	}
+	setStatus(trip.ID, models.TripBoarding, models.TripDeparted)
+	rr = operatorRequest(r, "POST", path+"/confirm", firstToken, nil)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "trip_not_bookable") {
+		t.Errorf("confirming on a departed trip: got %d %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,139 @@
+// Package health serves the endpoints load balancers and deploy scripts use
+// to tell whether the backend is alive, ready for traffic, and which build
+// it runs.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Commit and BuildTime identify the build. They are set when building, e.g.
+//
+//	go build -ldflags "-X ticket-booking-app/backend/health.Commit=$(git rev-parse HEAD) -X ticket-booking-app/backend/health.BuildTime=$(date -u +%FT%TZ)"
+//
+// Otherwise the version control information recorded by the go command is
+// used, if any.
+var (
+	Commit    string
+	BuildTime string
+)
+
+// checkTimeout bounds each readiness check, so that a hanging dependency
+// fails the probe instead of blocking it.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Checker serves the readiness probe from a set of named checks.
+type Checker struct {
+	checks map[string]Check
+}
+
+// Add registers a check under name, which is how it is reported.
+func (c *Checker) Add(name string, check Check) {
+	if c.checks == nil {
+		c.checks = map[string]Check{}
+	}
+	c.checks[name] = check
+}
+
+type result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// run runs every check concurrently and returns their results by name, and
+// whether all of them passed.
+func (c *Checker) run(ctx context.Context) (map[string]result, bool) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = map[string]result{}
+		ok      = true
+	)
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			res := result{Status: "ok"}
+			if err := check(ctx); err != nil {
+				res = result{Status: "failing", Error: err.Error()}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = res
+			if res.Status != "ok" {
+				ok = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return results, ok
+}
+
+// ServeHTTP answers 200 when every check passes and 503 otherwise, with the
+// outcome of each check.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	results, ok := c.run(r.Context())
+	status, code := "ready", http.StatusOK
+	if !ok {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": results})
+}
+
+// LiveHandler answers as long as the process can serve requests at all. It
+// checks no dependency, so that an unreachable database does not get the
+// process restarted.
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Version returns the build information of the running binary.
+func Version() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+// VersionHandler reports which build is running.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Version())
+}
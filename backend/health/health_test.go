@@ -0,0 +1,79 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type readiness struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+func probe(t *testing.T, c *Checker) (int, readiness) {
+	rr := httptest.NewRecorder()
+	c.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	var body readiness
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	return rr.Code, body
+}
+
+func TestReadinessReportsEachCheck(t *testing.T) {
+	c := &Checker{}
+	c.Add("database", func(ctx context.Context) error { return nil })
+	code, body := probe(t, c)
+	if code != http.StatusOK || body.Status != "ready" || body.Checks["database"].Status != "ok" {
+		t.Errorf("healthy: got %d %+v", code, body)
+	}
+
+	c.Add("workers", func(ctx context.Context) error { return errors.New("not running: job pool") })
+	code, body = probe(t, c)
+	if code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Errorf("failing check: got %d %+v", code, body)
+	}
+	if got := body.Checks["workers"]; got.Status != "failing" || got.Error != "not running: job pool" {
+		t.Errorf("workers check: %+v", got)
+	}
+	if body.Checks["database"].Status != "ok" {
+		t.Errorf("database check: %+v", body.Checks["database"])
+	}
+}
+
+func TestReadinessTimesOutHangingChecks(t *testing.T) {
+	c := &Checker{}
+	c.Add("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr := httptest.NewRecorder()
+	c.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestVersionUsesInjectedBuildInfo(t *testing.T) {
+	defer func(commit, buildTime string) { Commit, BuildTime = commit, buildTime }(Commit, BuildTime)
+	Commit, BuildTime = "abc123", "2025-09-01T10:00:00Z"
+
+	rr := httptest.NewRecorder()
+	VersionHandler(rr, httptest.NewRequest("GET", "/version", nil))
+	var info Info
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("could not unmarshal response: %v", err)
+	}
+	if info.Commit != "abc123" || info.BuildTime != "2025-09-01T10:00:00Z" || info.GoVersion == "" {
+		t.Errorf("unexpected build info: %+v", info)
+	}
+}
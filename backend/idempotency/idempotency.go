@@ -0,0 +1,231 @@
+// Package idempotency lets clients retry requests that must not be
+// repeated, such as creating a booking. A client sends the same
+// Idempotency-Key header with every attempt; the first response is stored
+// and replayed to the retries. Keys are kept in process by MemoryStore or
+// shared between processes by PostgresStore.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/config"
+	"ticket-booking-app/backend/metrics"
+)
+
+const (
+	// Header carries the key chosen by the client, e.g. a UUID.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from a stored one.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+const (
+	maxKeyLength = 255
+	// maxBodyBytes bounds the requests read to fingerprint them; the
+	// handlers apply their own, smaller limits.
+	maxBodyBytes = 1 << 20
+	// lockTimeout is how long a key stays claimed by a request that has not
+	// answered, after which a retry may claim it. It outlasts the server's
+	// write timeout, so the request has given up by then.
+	lockTimeout = time.Minute
+)
+
+// Record is what is stored for a key.
+type Record struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	// Status is 0 while that request is being handled.
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store keeps the keys.
+type Store interface {
+	// Start claims key for a request with fingerprint until expires. If the
+	// key is already claimed, it returns its record and false, unless the
+	// claim has expired or its request has not answered within lockTimeout.
+	Start(ctx context.Context, key, fingerprint string, now, expires time.Time) (Record, bool, error)
+	// Finish stores the response to the request that claimed key.
+	Finish(ctx context.Context, key string, rec Record) error
+	// Release forgets key, so that the request may be tried again.
+	Release(ctx context.Context, key string) error
+}
+
+// Keys applies idempotency keys. A nil Keys ignores them.
+type Keys struct {
+	Store Store
+	// TTL is how long a key is remembered after its first use.
+	TTL time.Duration
+}
+
+// New returns Keys for cfg kept in store.
+func New(store Store, cfg config.IdempotencyConfig) *Keys {
+	return &Keys{Store: store, TTL: cfg.TTL}
+}
+
+// Middleware handles the first request with a given key and answers the
+// retries with its response, marked with ReplayedHeader. A key reused with
+// a different request, or while its first request is still being handled,
+// is refused with 409. Server errors are not stored, so that the request
+// can be retried. Keys are scoped to the authenticated user, so
+// Middleware must run after auth.Auth.Middleware.
+func (k *Keys) Middleware(next http.Handler) http.Handler {
+	if k == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validKey(key) {
+			apierror.Write(w, r, apierror.ErrInvalidIdempotencyKey)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Write(w, r, apierror.ErrBodyTooLarge)
+			} else {
+				apierror.Write(w, r, apierror.ErrInvalidBody)
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = scope(r) + ":" + key
+		fingerprint := fingerprint(r, body)
+		now := time.Now()
+		rec, started, err := k.Store.Start(r.Context(), key, fingerprint, now, now.Add(k.TTL))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error claiming idempotency key", "error", err)
+			apierror.Write(w, r, apierror.ErrInternal)
+			return
+		}
+		if !started {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				metrics.IdempotentRequest("reused")
+				apierror.Write(w, r, apierror.ErrIdempotencyKeyReused)
+			case rec.Status == 0:
+				metrics.IdempotentRequest("in_progress")
+				w.Header().Set("Retry-After", "1")
+				apierror.Write(w, r, apierror.ErrRequestInProgress)
+			default:
+				metrics.IdempotentRequest("replayed")
+				replay(w, rec)
+			}
+			return
+		}
+		metrics.IdempotentRequest("handled")
+
+		// The outcome is recorded even if the client has gone away: that is
+		// when it is most likely to retry.
+		ctx := context.WithoutCancel(r.Context())
+		stored := false
+		defer func() {
+			if !stored {
+				if err := k.Store.Release(ctx, key); err != nil {
+					slog.ErrorContext(ctx, "Error releasing idempotency key", "error", err)
+				}
+			}
+		}()
+		rw := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		if rw.code() >= 500 {
+			return
+		}
+		rec = Record{Fingerprint: fingerprint, Status: rw.code(), ContentType: rw.Header().Get("Content-Type"), Body: rw.body.Bytes()}
+		if err := k.Store.Finish(ctx, key, rec); err != nil {
+			slog.ErrorContext(ctx, "Error storing idempotent response", "error", err)
+			return
+		}
+		stored = true
+	})
+}
+
+// validKey accepts up to maxKeyLength printable ASCII characters.
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// scope returns whose keys r's key is among.
+func scope(r *http.Request) string {
+	if claims := auth.ClaimsFromContext(r.Context()); claims != nil {
+		return "user:" + strconv.Itoa(claims.UserID)
+	}
+	return "anonymous"
+}
+
+// fingerprint identifies a request by its method, path and body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, rec Record) {
+	if rec.ContentType != "" {
+		w.Header().Set("Content-Type", rec.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// responseRecorder passes the response through while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// code returns the status sent, which is 200 if the handler wrote nothing.
+func (w *responseRecorder) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,150 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/config"
+)
+
+// testAuth checks the tokens made by withUser. They are not tied to a
+// session, so it needs no store.
+var testAuth, _ = auth.New(config.Default().Auth, nil)
+
+// withUser authenticates req as the user with id.
+func withUser(t *testing.T, req *http.Request, id int) *http.Request {
+	token, err := testAuth.SignToken(auth.NewClaims(id, "user@example.com", []string{"customer"}, 0, time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestMiddlewareReplaysRetries(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+	k := &Keys{Store: NewMemoryStore(), TTL: time.Hour}
+	h := testAuth.Middleware(k.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"booking":%d}`, calls)
+	})))
+	call := func(user int, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/bookings", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(Header, key)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, withUser(t, req, user))
+		return rr
+	}
+
+	first := call(1, "k1", `{"seats":["A1"]}`)
+	if first.Code != http.StatusOK || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first request: got %d %v", first.Code, first.Header())
+	}
+	retry := call(1, "k1", `{"seats":["A1"]}`)
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get(ReplayedHeader) != "true" ||
+		retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("retry: got %d %v %s", retry.Code, retry.Header(), retry.Body)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times", calls)
+	}
+
+	if rr := call(1, "k1", `{"seats":["A2"]}`); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "idempotency_key_reused") {
+		t.Errorf("key reused with another body: got %d %s", rr.Code, rr.Body)
+	}
+	if rr := call(2, "k1", `{"seats":["A1"]}`); rr.Code != http.StatusOK || calls != 2 {
+		t.Errorf("another user's key: got %d after %d calls", rr.Code, calls)
+	}
+	call(1, "", `{"seats":["A1"]}`)
+	call(1, "", `{"seats":["A1"]}`)
+	if calls != 4 {
+		t.Errorf("requests without a key: handler called %d times, want 4", calls)
+	}
+
+	// Server errors are not stored, so the retry runs the handler again
+	status = http.StatusInternalServerError
+	call(1, "k2", `{}`)
+	status = http.StatusOK
+	if rr := call(1, "k2", `{}`); rr.Code != http.StatusOK || rr.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("retry after a server error: got %d %v", rr.Code, rr.Header())
+	}
+
+	if rr := call(1, strings.Repeat("k", maxKeyLength+1), `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("overlong key: got %d", rr.Code)
+	}
+	if rr := call(1, "café", `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("non-ASCII key: got %d", rr.Code)
+	}
+
+	var disabled *Keys
+	req := httptest.NewRequest("POST", "/api/bookings", nil)
+	req.Header.Set(Header, "\n")
+	rr := httptest.NewRecorder()
+	disabled.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("nil Keys checked the key: got %d", rr.Code)
+	}
+}
+
+func TestConcurrentRetryIsRefused(t *testing.T) {
+	k := &Keys{Store: NewMemoryStore(), TTL: time.Hour}
+	var retry *httptest.ResponseRecorder
+	h := testAuth.Middleware(k.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The retry arrives while the first request is being handled
+		req := httptest.NewRequest("POST", "/api/bookings", strings.NewReader("{}"))
+		req.Header.Set(Header, "k")
+		retry = httptest.NewRecorder()
+		testAuth.Middleware(k.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("retry handled concurrently")
+		}))).ServeHTTP(retry, withUser(t, req, 1))
+	})))
+	req := httptest.NewRequest("POST", "/api/bookings", strings.NewReader("{}"))
+	req.Header.Set(Header, "k")
+	h.ServeHTTP(httptest.NewRecorder(), withUser(t, req, 1))
+
+	if retry.Code != http.StatusConflict || !strings.Contains(retry.Body.String(), "request_in_progress") || retry.Header().Get("Retry-After") == "" {
+		t.Errorf("concurrent retry: got %d %v %s", retry.Code, retry.Header(), retry.Body)
+	}
+}
+
+func TestMemoryStoreExpiresKeys(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)
+	start := func(key string) bool {
+		_, started, err := store.Start(ctx, key, "f", now, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return started
+	}
+
+	if !start("done") || !start("abandoned") {
+		t.Fatal("new keys not claimed")
+	}
+	store.Finish(ctx, "done", Record{Fingerprint: "f", Status: http.StatusOK})
+
+	now = now.Add(2 * lockTimeout)
+	if start("done") {
+		t.Error("answered key claimed again before it expired")
+	}
+	if !start("abandoned") {
+		t.Error("abandoned key not taken over")
+	}
+
+	now = now.Add(time.Hour)
+	if !start("done") {
+		t.Error("expired key not claimed again")
+	}
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// PostgresStore keeps keys in the idempotency_keys table, so that every
+// server process using the database shares them.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// Start inserts the key, or takes over an expired or abandoned one, in a
+// single statement, so that of concurrent requests with the same key only
+// one claims it.
+func (s *PostgresStore) Start(ctx context.Context, key, fingerprint string, now, expires time.Time) (Record, bool, error) {
+	res, err := s.DB.ExecContext(ctx, `INSERT INTO idempotency_keys (key, fingerprint, started_at, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = 0, content_type = '', body = NULL,
+			started_at = EXCLUDED.started_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= $3 OR (idempotency_keys.status = 0 AND idempotency_keys.started_at <= $5)`,
+		key, fingerprint, now, expires, now.Add(-lockTimeout))
+	if err != nil {
+		return Record{}, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Record{}, false, err
+	}
+	if n == 1 {
+		return Record{}, true, nil
+	}
+
+	var rec Record
+	err = s.DB.QueryRowContext(ctx, "SELECT fingerprint, status, content_type, body FROM idempotency_keys WHERE key = $1", key).
+		Scan(&rec.Fingerprint, &rec.Status, &rec.ContentType, &rec.Body)
+	return rec, false, err
+}
+
+func (s *PostgresStore) Finish(ctx context.Context, key string, rec Record) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE idempotency_keys SET status = $2, content_type = $3, body = $4 WHERE key = $1",
+		key, rec.Status, rec.ContentType, rec.Body)
+	return err
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key)
+	return err
+}
+
+// Prune deletes the keys that expired before now.
+func (s *PostgresStore) Prune(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Run prunes expired keys every few minutes until ctx is done.
+func (s *PostgresStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Prune(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Error pruning idempotency keys", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package jobs runs durable background jobs scheduled for a point in time.
+//
+// Jobs are stored in the database and claimed with row-level locks, so any
+// number of server instances can run a Pool against the same table without
+// executing a job twice.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+type Job struct {
+	ID      int
+	Kind    string
+	Payload json.RawMessage
+	RunAt   time.Time
+	// Key, if set, makes scheduling idempotent: a second job with the same
+	// key is ignored.
+	Key string
+	// Group ties related jobs together so they can be cancelled at once,
+	// e.g. all reminders of one booking.
+	Group       string
+	Attempts    int
+	MaxAttempts int
+	LastError   string
+	Status      Status
+}
+
+// Store persists jobs.
+type Store interface {
+	// Schedule adds a job. It returns false if a job with the same Key
+	// already exists.
+	Schedule(ctx context.Context, job Job) (bool, error)
+	// Claim leases up to limit due jobs to the caller. A job whose lease
+	// expires without being completed or failed becomes claimable again.
+	Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Job, error)
+	Complete(ctx context.Context, id int) error
+	// Fail records a failed attempt. A zero retryAt marks the job failed for
+	// good.
+	Fail(ctx context.Context, id int, jobErr error, retryAt time.Time) error
+	// CancelGroup deletes the pending jobs of a group and reports how many
+	// were removed.
+	CancelGroup(ctx context.Context, group string) (int, error)
+	// Prune deletes the jobs that completed before the given time and
+	// reports how many were removed. Failed jobs are kept for inspection.
+	Prune(ctx context.Context, before time.Time) (int, error)
+}
+
+// NewJob builds a job whose payload is the JSON encoding of payload.
+func NewJob(kind string, runAt time.Time, payload interface{}) (Job, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+	return Job{Kind: kind, RunAt: runAt, Payload: raw}, nil
+}
@@ -0,0 +1,195 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestPoolRunsDueJobsOnly(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{now: time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	var ran []int
+	var mu sync.Mutex
+	p := &Pool{Store: store, Now: c.Now}
+	p.Handle("echo", func(ctx context.Context, job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, job.ID)
+		return nil
+	})
+
+	store.Schedule(ctx, Job{Kind: "echo", RunAt: c.now})
+	store.Schedule(ctx, Job{Kind: "echo", RunAt: c.now.Add(time.Hour)})
+
+	if n, err := p.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one job to run, got n=%d err=%v", n, err)
+	}
+	if len(ran) != 1 || ran[0] != 1 {
+		t.Fatalf("expected job 1 to run, got %v", ran)
+	}
+	if got := store.All()[0].Status; got != StatusDone {
+		t.Errorf("expected status done, got %s", got)
+	}
+
+	c.now = c.now.Add(time.Hour)
+	p.RunOnce(ctx)
+	if len(ran) != 2 {
+		t.Errorf("expected the later job to run once due, got %v", ran)
+	}
+}
+
+func TestPoolRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{now: time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	jobErr := errors.New("smtp unavailable")
+	p := &Pool{Store: store, Now: c.Now}
+	p.Handle("flaky", func(ctx context.Context, job Job) error { return jobErr })
+
+	store.Schedule(ctx, Job{Kind: "flaky", RunAt: c.now, MaxAttempts: 2})
+
+	p.RunOnce(ctx)
+	j := store.All()[0]
+	if j.Status != StatusPending || j.Attempts != 1 || !j.RunAt.Equal(c.now.Add(30*time.Second)) || j.LastError != "smtp unavailable" {
+		t.Fatalf("unexpected state after failure: %+v", j)
+	}
+
+	c.now = c.now.Add(30 * time.Second)
+	p.RunOnce(ctx)
+	if j := store.All()[0]; j.Status != StatusFailed || j.Attempts != 2 {
+		t.Errorf("expected job to fail permanently after 2 attempts, got %+v", j)
+	}
+}
+
+func TestPoolRecoversPanicsAndUnknownKinds(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	p := &Pool{Store: store}
+	p.Handle("boom", func(ctx context.Context, job Job) error { panic("nil map") })
+
+	store.Schedule(ctx, Job{Kind: "boom", RunAt: time.Now()})
+	store.Schedule(ctx, Job{Kind: "unknown", RunAt: time.Now()})
+	p.RunOnce(ctx)
+
+	for _, j := range store.All() {
+		if j.Status != StatusPending || j.LastError == "" {
+			t.Errorf("expected job %d to be rescheduled with an error, got %+v", j.ID, j)
+		}
+	}
+}
+
+func TestScheduleKeyIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	job := Job{Kind: "echo", RunAt: time.Now(), Key: "booking:1:reminder:24h"}
+
+	if ok, _ := store.Schedule(ctx, job); !ok {
+		t.Fatal("expected first schedule to succeed")
+	}
+	if ok, _ := store.Schedule(ctx, job); ok {
+		t.Error("expected duplicate key to be ignored")
+	}
+	if len(store.All()) != 1 {
+		t.Errorf("expected one job, got %d", len(store.All()))
+	}
+}
+
+func TestCancelGroupOnlyRemovesPendingJobs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.Schedule(ctx, Job{Kind: "echo", RunAt: now, Group: "booking:1"})
+	store.Schedule(ctx, Job{Kind: "echo", RunAt: now.Add(time.Hour), Group: "booking:1"})
+	store.Schedule(ctx, Job{Kind: "echo", RunAt: now.Add(time.Hour), Group: "booking:2"})
+
+	// The first job is already running and must be left alone
+	store.Claim(ctx, now, 1, time.Minute)
+
+	if n, _ := store.CancelGroup(ctx, "booking:1"); n != 1 {
+		t.Fatalf("expected one job cancelled, got %d", n)
+	}
+	all := store.All()
+	if len(all) != 2 || all[0].Status != StatusRunning || all[1].Group != "booking:2" {
+		t.Errorf("unexpected jobs after cancel: %+v", all)
+	}
+}
+
+func TestConcurrentPoolsRunEachJobOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i := 0; i < 50; i++ {
+		store.Schedule(ctx, Job{Kind: "count", RunAt: time.Now()})
+	}
+
+	var runs int64
+	count := func(ctx context.Context, job Job) error {
+		atomic.AddInt64(&runs, 1)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		p := &Pool{Store: store, Workers: 3}
+		p.Handle("count", count)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n, _ := p.RunOnce(ctx)
+				if n == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if runs != 50 {
+		t.Errorf("expected 50 runs, got %d", runs)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 20: time.Hour}
+	for attempts, want := range cases {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestPoolPrunesCompletedJobs(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	p := &Pool{Store: store}
+	p.Handle("ok", func(ctx context.Context, job Job) error { return nil })
+	p.Handle("broken", func(ctx context.Context, job Job) error { return errors.New("boom") })
+
+	store.Schedule(ctx, Job{Kind: "ok", RunAt: time.Now()})
+	store.Schedule(ctx, Job{Kind: "broken", RunAt: time.Now(), MaxAttempts: 1})
+	store.Schedule(ctx, Job{Kind: "ok", RunAt: time.Now().Add(time.Hour)})
+	p.RunOnce(ctx)
+
+	if n, err := p.Prune(ctx); err != nil || n != 0 {
+		t.Fatalf("expected recent jobs to be kept, pruned %d err=%v", n, err)
+	}
+
+	p.Retention = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if n, err := p.Prune(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the completed job to be pruned, pruned %d err=%v", n, err)
+	}
+	all := store.All()
+	if len(all) != 2 || all[0].Status != StatusFailed || all[1].Status != StatusPending {
+		t.Errorf("expected failed and pending jobs to remain, got %+v", all)
+	}
+}
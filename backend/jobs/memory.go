@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store for tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	nextID   int
+	jobs     map[int]*Job
+	leases   map[int]time.Time
+	finished map[int]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: map[int]*Job{}, leases: map[int]time.Time{}, finished: map[int]time.Time{}}
+}
+
+func (s *MemoryStore) Schedule(ctx context.Context, job Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.Key != "" {
+		for _, existing := range s.jobs {
+			if existing.Key == job.Key {
+				return false, nil
+			}
+		}
+	}
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = defaultMaxAttempts
+	}
+	s.nextID++
+	job.ID = s.nextID
+	job.Status = StatusPending
+	s.jobs[job.ID] = &job
+	return true, nil
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*Job
+	for id, j := range s.jobs {
+		pending := j.Status == StatusPending && !j.RunAt.After(now)
+		expired := j.Status == StatusRunning && !s.leases[id].After(now)
+		if pending || expired {
+			due = append(due, j)
+		}
+	}
+	sort.Slice(due, func(i, k int) bool { return due[i].RunAt.Before(due[k].RunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]Job, 0, len(due))
+	for _, j := range due {
+		j.Status = StatusRunning
+		j.Attempts++
+		s.leases[j.ID] = now.Add(lease)
+		claimed = append(claimed, *j)
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].Status = StatusDone
+	delete(s.leases, id)
+	s.finished[id] = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, id int, jobErr error, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.jobs[id]
+	j.LastError = jobErr.Error()
+	if retryAt.IsZero() {
+		j.Status = StatusFailed
+	} else {
+		j.Status = StatusPending
+		j.RunAt = retryAt
+	}
+	delete(s.leases, id)
+	return nil
+}
+
+func (s *MemoryStore) CancelGroup(ctx context.Context, group string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, j := range s.jobs {
+		if j.Group == group && j.Status == StatusPending {
+			delete(s.jobs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, j := range s.jobs {
+		if j.Status == StatusDone && s.finished[id].Before(before) {
+			delete(s.jobs, id)
+			delete(s.finished, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// All returns a snapshot of every job, ordered by ID.
+func (s *MemoryStore) All() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		all = append(all, *j)
+	}
+	sort.Slice(all, func(i, k int) bool { return all[i].ID < all[k].ID })
+	return all
+}
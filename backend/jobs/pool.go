@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers      = 4
+	defaultPollInterval = 5 * time.Second
+	defaultLease        = 5 * time.Minute
+	defaultRetention    = 7 * 24 * time.Hour
+	pruneInterval       = time.Hour
+	baseBackoff         = 30 * time.Second
+	maxBackoff          = time.Hour
+)
+
+// Backoff returns the delay before retrying work that has failed attempts
+// times: 30s, 1m, 2m, ... capped at an hour. Jobs and outbox messages share it.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// HandlerFunc runs one job. Returning an error schedules a retry.
+type HandlerFunc func(ctx context.Context, job Job) error
+
+// Pool claims due jobs and runs them on a fixed number of workers.
+type Pool struct {
+	Store Store
+	// Workers is the number of jobs run concurrently. Zero means 4.
+	Workers      int
+	PollInterval time.Duration
+	// Lease is how long a claimed job is reserved for this pool before
+	// another instance may pick it up again. It must exceed the longest
+	// expected run time of a job.
+	Lease time.Duration
+	// Retention is how long completed jobs are kept before Run deletes
+	// them. Zero means 7 days.
+	Retention time.Duration
+	// Now is overridable for tests.
+	Now func() time.Time
+
+	handlers map[string]HandlerFunc
+}
+
+// Handle registers the handler for jobs of kind.
+func (p *Pool) Handle(kind string, h HandlerFunc) {
+	if p.handlers == nil {
+		p.handlers = map[string]HandlerFunc{}
+	}
+	p.handlers[kind] = h
+}
+
+func (p *Pool) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+func (p *Pool) workers() int {
+	if p.Workers > 0 {
+		return p.Workers
+	}
+	return defaultWorkers
+}
+
+// Run polls for due jobs every PollInterval until ctx is cancelled, then
+// waits for running jobs to finish. Once an hour it also prunes completed
+// jobs older than Retention.
+func (p *Pool) Run(ctx context.Context) {
+	interval := p.PollInterval
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(pruneInterval)
+	defer pruneTicker.Stop()
+
+	p.prune(ctx)
+	for {
+		if _, err := p.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Job polling failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-pruneTicker.C:
+			p.prune(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes the jobs that completed more than Retention ago.
+func (p *Pool) Prune(ctx context.Context) (int, error) {
+	retention := p.Retention
+	if retention == 0 {
+		retention = defaultRetention
+	}
+	return p.Store.Prune(ctx, p.now().Add(-retention))
+}
+
+func (p *Pool) prune(ctx context.Context) {
+	if _, err := p.Prune(ctx); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Error pruning finished jobs", "error", err)
+	}
+}
+
+// RunOnce claims one batch of due jobs, runs them and returns how many
+// succeeded.
+func (p *Pool) RunOnce(ctx context.Context) (int, error) {
+	lease := p.Lease
+	if lease == 0 {
+		lease = defaultLease
+	}
+	claimed, err := p.Store.Claim(ctx, p.now(), p.workers(), lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		firstErr  error
+	)
+	for _, job := range claimed {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			ok, err := p.run(ctx, job)
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				succeeded++
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(job)
+	}
+	wg.Wait()
+	return succeeded, firstErr
+}
+
+// run executes job and records the outcome. The returned error is about
+// recording the outcome, not about the job itself.
+func (p *Pool) run(ctx context.Context, job Job) (bool, error) {
+	jobErr := p.execute(ctx, job)
+	if jobErr == nil {
+		return true, p.Store.Complete(ctx, job.ID)
+	}
+
+	var retryAt time.Time
+	if job.Attempts < job.MaxAttempts {
+		retryAt = p.now().Add(Backoff(job.Attempts))
+	} else {
+		slog.WarnContext(ctx, "Giving up on job", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", jobErr)
+	}
+	return false, p.Store.Fail(ctx, job.ID, jobErr, retryAt)
+}
+
+func (p *Pool) execute(ctx context.Context, job Job) (err error) {
+	h, ok := p.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler registered for job kind %q", job.Kind)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
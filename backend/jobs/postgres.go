@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const defaultMaxAttempts = 5
+
+// PostgresStore keeps jobs in the jobs table.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) Schedule(ctx context.Context, job Job) (bool, error) {
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = defaultMaxAttempts
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO jobs (kind, payload, run_at, key, group_key, max_attempts)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		ON CONFLICT (key) DO NOTHING`,
+		job.Kind, []byte(job.Payload), job.RunAt, job.Key, job.Group, job.MaxAttempts)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Claim locks due rows with FOR UPDATE SKIP LOCKED so that concurrent
+// instances each get a disjoint batch.
+func (s *PostgresStore) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Job, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = $1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= $2) OR (status = 'running' AND locked_until <= $2)
+			ORDER BY run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, run_at, COALESCE(key, ''), group_key, attempts, max_attempts, last_error, status`,
+		now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []Job
+	for rows.Next() {
+		var j Job
+		var payload []byte
+		if err := rows.Scan(&j.ID, &j.Kind, &payload, &j.RunAt, &j.Key, &j.Group, &j.Attempts, &j.MaxAttempts, &j.LastError, &j.Status); err != nil {
+			return nil, err
+		}
+		j.Payload = payload
+		claimed = append(claimed, j)
+	}
+	return claimed, rows.Err()
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE jobs SET status = 'done', locked_until = NULL, finished_at = NOW() WHERE id = $1", id)
+	return err
+}
+
+func (s *PostgresStore) Fail(ctx context.Context, id int, jobErr error, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		_, err := s.DB.ExecContext(ctx, "UPDATE jobs SET status = 'failed', last_error = $1, locked_until = NULL, finished_at = NOW() WHERE id = $2", jobErr.Error(), id)
+		return err
+	}
+	_, err := s.DB.ExecContext(ctx, "UPDATE jobs SET status = 'pending', last_error = $1, run_at = $2, locked_until = NULL WHERE id = $3", jobErr.Error(), retryAt, id)
+	return err
+}
+
+func (s *PostgresStore) CancelGroup(ctx context.Context, group string) (int, error) {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM jobs WHERE group_key = $1 AND status = 'pending'", group)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM jobs WHERE status = 'done' AND finished_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ticket-booking-app/backend/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func logLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("not a JSON log line: %q", buf.String())
+	}
+	return line
+}
+
+func TestRequestIDIsAttached(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LogConfig{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithRequestID(context.Background(), "req-42")
+	logger.With("component", "test").ErrorContext(ctx, "Database error", "error", "connection refused")
+
+	line := logLine(t, &buf)
+	if line["request_id"] != "req-42" || line["error"] != "connection refused" || line["component"] != "test" {
+		t.Errorf("unexpected log line: %v", line)
+	}
+
+	buf.Reset()
+	logger.DebugContext(ctx, "query")
+	if buf.Len() != 0 {
+		t.Errorf("debug logged at info level: %s", buf.String())
+	}
+}
+
+func TestCredentialsAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LogConfig{Level: "debug", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret-token")
+	header.Set("User-Agent", "test")
+	logger.Info("request", "password", "hunter2", "refresh_token", "abc", "code", "123456", "headers", header)
+
+	if out := buf.String(); strings.Contains(out, "hunter2") || strings.Contains(out, "abc") ||
+		strings.Contains(out, "123456") || strings.Contains(out, "secret-token") {
+		t.Errorf("credentials logged: %s", out)
+	}
+	line := logLine(t, &buf)
+	headers, _ := line["headers"].(map[string]interface{})
+	if agent, _ := headers["User-Agent"].([]interface{}); len(agent) != 1 || agent[0] != "test" {
+		t.Errorf("other headers should be kept: %v", headers)
+	}
+	if header.Get("Authorization") != "Bearer secret-token" {
+		t.Error("the logged header was modified in place")
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, config.LogConfig{Level: "verbose", Format: "json"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, config.LogConfig{Level: "info", Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestTraceIDIsAttached(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LogConfig{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+		TraceFlags: trace.FlagsSampled,
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "request")
+
+	line := logLine(t, &buf)
+	if line["trace_id"] != sc.TraceID().String() || line["span_id"] != sc.SpanID().String() {
+		t.Errorf("unexpected log line: %v", line)
+	}
+}
@@ -1,43 +1,309 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/smtp"
+	"os"
+	"time"
 
+	"ticket-booking-app/backend/apierror"
 	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/availability"
+	"ticket-booking-app/backend/config"
 	"ticket-booking-app/backend/database"
 	"ticket-booking-app/backend/handlers"
+	"ticket-booking-app/backend/health"
+	"ticket-booking-app/backend/idempotency"
+	"ticket-booking-app/backend/jobs"
+	"ticket-booking-app/backend/logging"
+	"ticket-booking-app/backend/metrics"
 	"ticket-booking-app/backend/middleware"
+	"ticket-booking-app/backend/migrations"
+	"ticket-booking-app/backend/notifications"
+	"ticket-booking-app/backend/ratelimit"
+	"ticket-booking-app/backend/server"
+	"ticket-booking-app/backend/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-func main() {
-	// Initialize database
-	database.InitDB()
+// protect wraps h in token validation followed by the given authorization
+// checks, outermost first.
+func protect(a *auth.Auth, h http.HandlerFunc, checks ...func(http.Handler) http.Handler) http.Handler {
+	var handler http.Handler = h
+	for i := len(checks) - 1; i >= 0; i-- {
+		handler = checks[i](handler)
+	}
+	return a.Middleware(handler)
+}
 
+// untraced are the paths polled by infrastructure, whose spans would drown
+// out those of real requests.
+var untraced = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+func newRouter(srv *handlers.Server) *mux.Router {
 	r := mux.NewRouter()
-	r.Use(middleware.LoggingMiddleware)
+	r.NotFoundHandler = apierror.NotFound
+	r.MethodNotAllowedHandler = apierror.MethodNotAllowed
+	r.Use(
+		otelmux.Middleware(tracing.ServiceName, otelmux.WithFilter(func(r *http.Request) bool { return !untraced[r.URL.Path] })),
+		middleware.RequestID, middleware.LoggingMiddleware, middleware.MetricsMiddleware,
+	)
+
+	// Probes and build info, for load balancers and deploy scripts
+	r.HandleFunc("/healthz", health.LiveHandler).Methods("GET")
+	r.HandleFunc("/version", health.VersionHandler).Methods("GET")
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Authentication, rate-limited per client address
+	limited := func(h http.HandlerFunc) http.Handler { return srv.Limiter.Middleware(h) }
+	r.Handle("/api/auth/signup", limited(srv.SignupHandler)).Methods("POST")
+	r.Handle("/api/auth/login", limited(srv.LoginHandler)).Methods("POST")
+	r.Handle("/api/auth/refresh", limited(srv.RefreshTokenHandler)).Methods("POST")
+	r.Handle("/api/auth/logout", limited(srv.LogoutHandler)).Methods("POST")
+	r.Handle("/api/auth/verify-email", limited(srv.VerifyEmailHandler)).Methods("POST")
+	r.Handle("/api/auth/verify-email/request", srv.Limiter.Middleware(protect(srv.Auth, srv.RequestEmailVerificationHandler))).Methods("POST")
+	r.Handle("/api/auth/password-reset", limited(srv.ResetPasswordHandler)).Methods("POST")
+	r.Handle("/api/auth/password-reset/request", limited(srv.RequestPasswordResetHandler)).Methods("POST")
+	r.Handle("/api/auth/otp/request", limited(srv.RequestOTPHandler)).Methods("POST")
+	r.Handle("/api/auth/otp/verify", limited(srv.VerifyOTPHandler)).Methods("POST")
+	r.Handle("/api/auth/mfa", limited(srv.VerifyMFAHandler)).Methods("POST")
 
 	// API endpoints
-	r.HandleFunc("/api/auth/signup", handlers.SignupHandler).Methods("POST")
-	r.HandleFunc("/api/auth/login", handlers.LoginHandler).Methods("POST")
-	r.HandleFunc("/api/trips/search", handlers.SearchTripsHandler).Methods("GET")
-	r.Handle("/api/trips/{id}", auth.Middleware(http.HandlerFunc(handlers.GetTripByIDHandler))).Methods("GET")
-	r.Handle("/api/bookings", auth.Middleware(http.HandlerFunc(handlers.CreateBookingHandler))).Methods("POST")
-	r.Handle("/api/profile", auth.Middleware(http.HandlerFunc(handlers.GetProfileHandler))).Methods("GET")
+	r.HandleFunc("/.well-known/jwks.json", srv.JWKSHandler).Methods("GET")
+	r.HandleFunc("/api/trips/search", srv.SearchTripsHandler).Methods("GET")
+	r.Handle("/api/trips/{id}", protect(srv.Auth, srv.GetTripByIDHandler)).Methods("GET")
+	r.HandleFunc("/api/trips/{id}/status", srv.GetTripStatusHandler).Methods("GET")
+	r.HandleFunc("/api/trips/{id}/seats/events", srv.TripSeatEventsHandler).Methods("GET")
+	bookTrips := auth.RequirePermission(auth.PermBookTrips)
+	r.Handle("/api/bookings", protect(srv.Auth, srv.CreateBookingHandler, bookTrips, srv.Idempotency.Middleware)).Methods("POST")
+	r.Handle("/api/trips/{id}/waitlist", protect(srv.Auth, srv.JoinWaitlistHandler, bookTrips)).Methods("POST")
+	r.Handle("/api/trips/{id}/waitlist", protect(srv.Auth, srv.GetWaitlistHandler, bookTrips)).Methods("GET")
+	r.Handle("/api/trips/{id}/waitlist", protect(srv.Auth, srv.LeaveWaitlistHandler, bookTrips)).Methods("DELETE")
+	r.Handle("/api/trips/{id}/waitlist/confirm", protect(srv.Auth, srv.ConfirmWaitlistOfferHandler, bookTrips, srv.Idempotency.Middleware)).Methods("POST")
+	r.Handle("/api/profile", protect(srv.Auth, srv.GetProfileHandler)).Methods("GET")
+	r.Handle("/api/profile/phone", protect(srv.Auth, srv.LinkPhoneHandler)).Methods("POST")
+	r.Handle("/api/profile/phone/verify", protect(srv.Auth, srv.VerifyLinkPhoneHandler)).Methods("POST")
+	r.Handle("/api/profile/email", protect(srv.Auth, srv.LinkEmailHandler)).Methods("POST")
+	r.Handle("/api/sessions", protect(srv.Auth, srv.ListSessionsHandler)).Methods("GET")
+	r.Handle("/api/sessions/{id}", protect(srv.Auth, srv.RevokeSessionHandler)).Methods("DELETE")
+	r.Handle("/api/mfa", protect(srv.Auth, srv.MFAStatusHandler)).Methods("GET")
+	r.Handle("/api/mfa/totp/setup", protect(srv.Auth, srv.SetupTOTPHandler)).Methods("POST")
+	r.Handle("/api/mfa/totp/enable", protect(srv.Auth, srv.EnableTOTPHandler)).Methods("POST")
+	r.Handle("/api/mfa/totp/disable", protect(srv.Auth, srv.DisableTOTPHandler)).Methods("POST")
+	r.Handle("/api/mfa/recovery-codes", protect(srv.Auth, srv.RegenerateRecoveryCodesHandler)).Methods("POST")
+
+	// Operator portal
+	manageTrips := auth.RequirePermission(auth.PermManageTrips)
+	manageBuses := auth.RequirePermission(auth.PermManageBuses)
+	viewBookings := auth.RequirePermission(auth.PermViewTripBookings)
+	updateStatus := auth.RequirePermission(auth.PermUpdateTripStatus)
+	operator := r.PathPrefix("/api/operator").Subrouter()
+	operator.Handle("/trips", protect(srv.Auth, srv.OperatorListTripsHandler, viewBookings, srv.Auth.OperatorMiddleware)).Methods("GET")
+	operator.Handle("/trips", protect(srv.Auth, srv.OperatorCreateTripHandler, manageTrips, srv.Auth.OperatorMiddleware)).Methods("POST")
+	operator.Handle("/trips/{tripID}", protect(srv.Auth, srv.OperatorUpdateTripHandler, manageTrips, srv.Auth.OperatorMiddleware)).Methods("PUT")
+	operator.Handle("/trips/{tripID}/status", protect(srv.Auth, srv.OperatorUpdateTripStatusHandler, updateStatus, srv.Auth.OperatorMiddleware)).Methods("PUT")
+	operator.Handle("/trips/{tripID}/cancel", protect(srv.Auth, srv.OperatorCancelTripHandler, manageTrips, srv.Auth.OperatorMiddleware)).Methods("POST")
+	operator.Handle("/trips/{tripID}/bookings", protect(srv.Auth, srv.OperatorTripBookingsHandler, viewBookings, srv.Auth.OperatorMiddleware)).Methods("GET")
+	operator.Handle("/buses", protect(srv.Auth, srv.OperatorListBusesHandler, manageBuses, srv.Auth.OperatorMiddleware)).Methods("GET")
+	operator.Handle("/buses", protect(srv.Auth, srv.OperatorCreateBusHandler, manageBuses, srv.Auth.OperatorMiddleware)).Methods("POST")
+	operator.Handle("/buses/{busID}", protect(srv.Auth, srv.OperatorUpdateBusHandler, manageBuses, srv.Auth.OperatorMiddleware)).Methods("PUT")
+	operator.Handle("/buses/{busID}", protect(srv.Auth, srv.OperatorDeleteBusHandler, manageBuses, srv.Auth.OperatorMiddleware)).Methods("DELETE")
+
+	// Admin API
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(srv.Auth.Middleware, auth.RequireRole(auth.RoleAdmin))
+	admin.HandleFunc("/trips", srv.AdminListTripsHandler).Methods("GET")
+	admin.HandleFunc("/trips", srv.AdminCreateTripHandler).Methods("POST")
+	admin.HandleFunc("/trips/{id}", srv.AdminGetTripHandler).Methods("GET")
+	admin.HandleFunc("/trips/{id}", srv.AdminUpdateTripHandler).Methods("PUT")
+	admin.HandleFunc("/trips/{id}", srv.AdminDeleteTripHandler).Methods("DELETE")
+	admin.HandleFunc("/trips/{id}/seats", srv.AdminAdjustSeatsHandler).Methods("PUT")
+	admin.HandleFunc("/users", srv.AdminListUsersHandler).Methods("GET")
+	admin.HandleFunc("/bookings", srv.AdminListBookingsHandler).Methods("GET")
+	admin.HandleFunc("/bookings/{id}", srv.AdminGetBookingHandler).Methods("GET")
+	admin.HandleFunc("/bookings/{id}/cancel", srv.AdminCancelBookingHandler).Methods("POST")
+	admin.HandleFunc("/audit", srv.AdminListAuditHandler).Methods("GET")
+
+	// Role management
+	manageRoles := auth.RequirePermission(auth.PermManageRoles)
+	admin.Handle("/users/{id}/roles", manageRoles(http.HandlerFunc(srv.GetUserRolesHandler))).Methods("GET")
+	admin.Handle("/users/{id}/roles", manageRoles(http.HandlerFunc(srv.GrantRoleHandler))).Methods("POST")
+	admin.Handle("/users/{id}/roles/{role}", manageRoles(http.HandlerFunc(srv.RevokeRoleHandler))).Methods("DELETE")
+
+	// Operator staff accounts
+	manageUsers := auth.RequirePermission(auth.PermManageUsers)
+	admin.Handle("/users/{id}/operator", manageUsers(http.HandlerFunc(srv.SetUserOperatorHandler))).Methods("PUT")
+	admin.Handle("/users/{id}/operator", manageUsers(http.HandlerFunc(srv.RemoveUserOperatorHandler))).Methods("DELETE")
+
+	return r
+}
+
+// newNotifiers configures delivery. Channels without a provider write to a
+// local file instead, so development needs no accounts.
+func newNotifiers(cfg config.NotificationsConfig) map[notifications.Channel]notifications.Notifier {
+	sink := &notifications.FileNotifier{Path: cfg.File}
+	notifiers := map[notifications.Channel]notifications.Notifier{
+		notifications.ChannelEmail: sink,
+		notifications.ChannelSMS:   sink,
+	}
+
+	if cfg.SMTP.Addr != "" {
+		email := &notifications.SMTPNotifier{Addr: cfg.SMTP.Addr, From: cfg.SMTP.From}
+		if cfg.SMTP.Username != "" {
+			host, _, _ := net.SplitHostPort(cfg.SMTP.Addr)
+			email.Auth = smtp.PlainAuth("", cfg.SMTP.Username, cfg.SMTP.Password, host)
+		}
+		notifiers[notifications.ChannelEmail] = email
+	}
+	if cfg.SMS.URL != "" {
+		notifiers[notifications.ChannelSMS] = &notifications.HTTPSMSNotifier{
+			URL:    cfg.SMS.URL,
+			APIKey: cfg.SMS.APIKey,
+			Sender: cfg.SMS.Sender,
+			Client: &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		}
+	}
+	return notifiers
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// log.Printf calls are written through the structured logger too
+	slog.SetDefault(logger)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Initialize database, waiting for it if it is still starting
+	db, err := database.Connect(context.Background(), cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Connected to database", "name", cfg.Database.Name)
+	metrics.RegisterDB(db, cfg.Database.Name)
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Database.MigrateOnStart {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range applied {
+			slog.Info("Applied migration", "migration", migration.String())
+		}
+	}
+
+	store := database.NewPostgresStore(db)
+	authn, err := auth.New(cfg.Auth, store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Auth.KeysDir == "" {
+		slog.Warn("auth.keys_dir is not set; signing tokens with a temporary key")
+	}
+	srv := handlers.NewServer(store, authn, cfg.App)
+
+	// Notifications are queued by handlers and delivered in the background
+	srv.Outbox = notifications.NewPostgresStore(db)
+	dispatcher := &notifications.Dispatcher{Store: srv.Outbox, Notifiers: newNotifiers(cfg.Notifications)}
+
+	srv.Jobs = jobs.NewPostgresStore(db)
+	pool := &jobs.Pool{Store: srv.Jobs}
+	pool.Handle(handlers.JobTripReminder, srv.SendTripReminder)
+	pool.Handle(handlers.JobWaitlistOfferExpiry, srv.ExpireWaitlistOffer)
+
+	// Rate limits are kept in process unless every replica should share them
+	var limits ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "postgres" {
+		limits = ratelimit.NewPostgresStore(db)
+	}
+	srv.Limiter, err = ratelimit.New(limits, cfg.RateLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// So are idempotency keys, for retried bookings
+	var keys idempotency.Store = idempotency.NewMemoryStore()
+	if cfg.Idempotency.Store == "postgres" {
+		keys = idempotency.NewPostgresStore(db)
+	}
+	srv.Idempotency = idempotency.New(keys, cfg.Idempotency)
+
+	// Seat changes reach the event streams of every replica through the
+	// database
+	relay := availability.NewPostgresRelay(db, cfg.Database.DSN(), srv.SeatEvents)
+	srv.SeatEvents.Relay = relay
+
+	r := newRouter(srv)
 
 	// CORS handler
 	c := cors.New(cors.Options{
-		AllowedOrigins: []string{"*"},
+		AllowedOrigins: cfg.Server.CORSOrigins,
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", middleware.RequestIDHeader, "traceparent", "tracestate", idempotency.Header},
+		ExposedHeaders: []string{middleware.RequestIDHeader, "Retry-After", idempotency.ReplayedHeader},
 	})
 
-	handler := c.Handler(r)
+	s := server.New(cfg.Server, c.Handler(r))
+	s.Go("notification dispatcher", dispatcher.Run)
+	s.Go("job pool", pool.Run)
+	s.Go("seat event relay", relay.Run)
+	s.HTTP.RegisterOnShutdown(srv.SeatEvents.Close)
+	if limits, ok := limits.(*ratelimit.PostgresStore); ok {
+		s.Go("rate limit pruning", limits.Run)
+	}
+	if keys, ok := keys.(*idempotency.PostgresStore); ok {
+		s.Go("idempotency key pruning", keys.Run)
+	}
+
+	ready := &health.Checker{}
+	ready.Add("database", db.PingContext)
+	ready.Add("migrations", func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending, up to %s", len(pending), pending[len(pending)-1])
+		}
+		return nil
+	})
+	ready.Add("workers", s.CheckWorkers)
+	r.Handle("/readyz", ready).Methods("GET")
 
-	fmt.Println("Server starting on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	// Runs until SIGINT or SIGTERM, then drains requests and stops the workers
+	err = s.Run(context.Background())
+	db.Close()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("Could not flush traces", "error", err)
+	}
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Server stopped")
 }
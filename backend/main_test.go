@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/auth"
+	"ticket-booking-app/backend/config"
+	"ticket-booking-app/backend/database"
+	"ticket-booking-app/backend/handlers"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestServer returns a Server on an empty in-memory store.
+func newTestServer(t *testing.T) *handlers.Server {
+	store := database.NewMemoryStore()
+	authn, err := auth.New(config.Default().Auth, store)
+	if err != nil {
+		t.Fatalf("Invalid auth config: %v", err)
+	}
+	return handlers.NewServer(store, authn, config.Default().App)
+}
+
+// These cases are all rejected by the auth middleware before a handler runs,
+// so the store stays empty.
+func TestProtectedRoutes(t *testing.T) {
+	srv := newTestServer(t)
+	r := newRouter(srv)
+
+	token := func(roles []string, operatorID int) string {
+		s, err := srv.Auth.SignToken(auth.NewClaims(1, "user@example.com", roles, operatorID, time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to create token: %v", err)
+		}
+		return s
+	}
+	customer := token([]string{"customer"}, 0)
+	conductor := token([]string{"conductor"}, 1)
+	unattachedOperator := token([]string{"operator"}, 0)
+	noRoles := token(nil, 0)
+
+	cases := []struct {
+		method string
+		path   string
+		// forbidden lists tokens that are valid but must be rejected with 403
+		forbidden []string
+	}{
+		{"GET", "/api/trips/1", nil},
+		{"POST", "/api/bookings", []string{noRoles, conductor}},
+		{"GET", "/api/profile", nil},
+		{"POST", "/api/profile/phone", nil},
+		{"POST", "/api/profile/phone/verify", nil},
+		{"POST", "/api/profile/email", nil},
+		{"GET", "/api/sessions", nil},
+		{"POST", "/api/auth/verify-email/request", nil},
+		{"DELETE", "/api/sessions/1", nil},
+		{"GET", "/api/mfa", nil},
+		{"POST", "/api/mfa/totp/setup", nil},
+		{"POST", "/api/mfa/totp/enable", nil},
+		{"POST", "/api/mfa/totp/disable", nil},
+		{"POST", "/api/mfa/recovery-codes", nil},
+		{"GET", "/api/operator/trips", []string{customer, unattachedOperator}},
+		{"POST", "/api/operator/trips", []string{customer, conductor, unattachedOperator}},
+		{"PUT", "/api/operator/trips/1", []string{customer, conductor, unattachedOperator}},
+		{"PUT", "/api/operator/trips/1/status", []string{customer, unattachedOperator}},
+		{"POST", "/api/operator/trips/1/cancel", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/operator/trips/1/bookings", []string{customer, unattachedOperator}},
+		{"GET", "/api/operator/buses", []string{customer, conductor, unattachedOperator}},
+		{"POST", "/api/operator/buses", []string{customer, conductor, unattachedOperator}},
+		{"PUT", "/api/operator/buses/1", []string{customer, conductor, unattachedOperator}},
+		{"DELETE", "/api/operator/buses/1", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/trips", []string{customer, conductor, unattachedOperator}},
+		{"POST", "/api/admin/trips", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/trips/1", []string{customer, conductor, unattachedOperator}},
+		{"PUT", "/api/admin/trips/1", []string{customer, conductor, unattachedOperator}},
+		{"DELETE", "/api/admin/trips/1", []string{customer, conductor, unattachedOperator}},
+		{"PUT", "/api/admin/trips/1/seats", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/users", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/bookings", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/bookings/1", []string{customer, conductor, unattachedOperator}},
+		{"POST", "/api/admin/bookings/1/cancel", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/audit", []string{customer, conductor, unattachedOperator}},
+		{"GET", "/api/admin/users/1/roles", []string{customer, conductor, unattachedOperator}},
+		{"POST", "/api/admin/users/1/roles", []string{customer, conductor, unattachedOperator}},
+		{"DELETE", "/api/admin/users/1/roles/operator", []string{customer, conductor, unattachedOperator}},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without token: got status %v want %v", tc.method, tc.path, rr.Code, http.StatusUnauthorized)
+		}
+
+		for _, tok := range tc.forbidden {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tok)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("%s %s with insufficient token: got status %v want %v", tc.method, tc.path, rr.Code, http.StatusForbidden)
+			}
+		}
+	}
+}
+
+// Probes are called by load balancers without credentials.
+func TestProbesArePublic(t *testing.T) {
+	r := newRouter(newTestServer(t))
+	for _, path := range []string{"/healthz", "/version"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("GET %s: got status %v want %v", path, rr.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestMetricsUseRouteTemplates(t *testing.T) {
+	r := newRouter(newTestServer(t))
+	for _, path := range []string{"/api/trips/1", "/api/trips/2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+	if want := `http_requests_total{code="401",method="GET",route="/api/trips/{id}"}`; !strings.Contains(body, want) {
+		t.Errorf("expected %s in metrics", want)
+	}
+	if strings.Contains(body, `route="/api/trips/1"`) {
+		t.Error("metrics labelled with the raw path")
+	}
+}
+
+func TestRequestIDIsEchoedOrGenerated(t *testing.T) {
+	r := newRouter(newTestServer(t))
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set("X-Request-ID", "lb-1234")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); got != "lb-1234" {
+		t.Errorf("expected the caller's request ID, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set("X-Request-ID", "not valid\n")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); len(got) != 32 {
+		t.Errorf("expected a generated request ID, got %q", got)
+	}
+}
+
+func TestRequestsContinueTheCallersTrace(t *testing.T) {
+	defer func(tp trace.TracerProvider, p propagation.TextMapPropagator) {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(p)
+	}(otel.GetTracerProvider(), otel.GetTextMapPropagator())
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	r := newRouter(newTestServer(t))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/trips/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected one span, got %d", len(ended))
+	}
+	if got := ended[0].SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("span in trace %s, want %s", got, traceID)
+	}
+	if name := ended[0].Name(); name != "/api/trips/{id}" {
+		t.Errorf("span named %q, want the route template", name)
+	}
+}
+
+func TestUnmatchedRoutesAnswerJSON(t *testing.T) {
+	r := newRouter(newTestServer(t))
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/api/nowhere", http.StatusNotFound},
+		{"PATCH", "/api/bookings", http.StatusMethodNotAllowed},
+		{"GET", "/api/auth/nowhere", http.StatusNotFound},
+		{"GET", "/api/auth/login", http.StatusMethodNotAllowed},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		if rr.Code != tc.status || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: got %d %s", tc.method, tc.path, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+}
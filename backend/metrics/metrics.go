@@ -0,0 +1,123 @@
+// Package metrics defines the Prometheus metrics the server exports on
+// /metrics: HTTP traffic, database queries and bookings.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route template and status code.",
+	}, []string{"method", "route", "code"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by database statements, by SQL command.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Database statements that failed, by SQL command.",
+	}, []string{"operation"})
+
+	bookingsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bookings_created_total",
+		Help: "Bookings made by passengers.",
+	})
+	seatsSold = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "seats_sold_total",
+		Help: "Seats booked by passengers.",
+	})
+	bookingsCancelled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bookings_cancelled_total",
+		Help: "Bookings cancelled, by who cancelled them.",
+	}, []string{"reason"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limited_requests_total",
+		Help: "Requests refused for exceeding a rate limit, by what was limited.",
+	}, []string{"limit"})
+	loginLockouts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "login_lockouts_total",
+		Help: "Email addresses locked out after repeated failed logins.",
+	})
+	idempotentRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "idempotent_requests_total",
+		Help: "Requests carrying an Idempotency-Key, by outcome: handled, replayed, reused or in_progress.",
+	}, []string{"outcome"})
+)
+
+// Reasons a booking is cancelled, as reported by bookings_cancelled_total.
+const (
+	CancelledByAdmin  = "admin"
+	CancelledWithTrip = "trip_cancelled"
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest records a served request. route is the route template, not
+// the requested path, to keep the number of series bounded.
+func ObserveRequest(method, route string, code int, d time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(d.Seconds())
+}
+
+// ObserveQuery records a database statement. operation is its SQL command,
+// e.g. "select".
+func ObserveQuery(operation string, d time.Duration, err error) {
+	dbQueryDuration.WithLabelValues(operation).Observe(d.Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled with
+// the database name.
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// BookingCreated counts a new booking of seats seats.
+func BookingCreated(seats int) {
+	bookingsCreated.Inc()
+	seatsSold.Add(float64(seats))
+}
+
+// BookingsCancelled counts n bookings cancelled for reason.
+func BookingsCancelled(reason string, n int) {
+	bookingsCancelled.WithLabelValues(reason).Add(float64(n))
+}
+
+// RateLimited counts a request refused by the limit on limit, e.g. "ip".
+func RateLimited(limit string) {
+	rateLimited.WithLabelValues(limit).Inc()
+}
+
+// LoginLockout counts an email address locked out after failed logins.
+func LoginLockout() {
+	loginLockouts.Inc()
+}
+
+// IdempotentRequest counts a request carrying an Idempotency-Key by outcome,
+// e.g. "replayed".
+func IdempotentRequest(outcome string) {
+	idempotentRequests.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadSortsAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0010_add_index.up.sql":      {Data: []byte("CREATE INDEX ...")},
+		"0010_add_index.down.sql":    {Data: []byte("DROP INDEX ...")},
+		"0002_create_users.up.sql":   {Data: []byte("CREATE TABLE users ()")},
+		"0002_create_users.down.sql": {Data: []byte("DROP TABLE users")},
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Version != 10 {
+		t.Fatalf("unexpected order: %v", migrations)
+	}
+	if migrations[0].String() != "0002_create_users" || migrations[0].Down != "DROP TABLE users" {
+		t.Errorf("unexpected migration %+v", migrations[0])
+	}
+}
+
+func TestLoadRejectsBrokenSets(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {"0001_a.up.sql": {Data: []byte("x")}},
+		"bad name":     {"create_users.sql": {Data: []byte("x")}},
+		"duplicate version": {
+			"0001_a.up.sql": {Data: []byte("x")}, "0001_a.down.sql": {Data: []byte("x")},
+			"0001_b.up.sql": {Data: []byte("x")}, "0001_b.down.sql": {Data: []byte("x")},
+		},
+	} {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Embedded()
+	if err != nil {
+		t.Fatalf("Embedded: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("expected migrations starting at version 1, got %v", migrations)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("versions should be sequential, %s is at position %d", m, i+1)
+		}
+		if strings.Contains(strings.ToUpper(m.Up), "DROP TABLE IF EXISTS") {
+			t.Errorf("%s: up migrations must not drop existing data", m)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "0007_old.up.sql"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "0007_old.down.sql"), []byte("x"), 0644)
+
+	up, down, err := Create(dir, "add_waitlist")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if filepath.Base(up) != "0008_add_waitlist.up.sql" || filepath.Base(down) != "0008_add_waitlist.down.sql" {
+		t.Errorf("unexpected files %s %s", up, down)
+	}
+	if _, err := Load(os.DirFS(dir)); err != nil {
+		t.Errorf("created files do not load: %v", err)
+	}
+	if _, _, err := Create(dir, "Add Waitlist"); err == nil {
+		t.Error("expected an invalid name to be rejected")
+	}
+}
@@ -0,0 +1,14 @@
+DROP TABLE jobs;
+DROP TABLE notification_outbox;
+DROP TABLE audit_log;
+DROP TABLE recovery_codes;
+DROP TABLE otp_codes;
+DROP TABLE user_tokens;
+DROP TABLE refresh_tokens;
+DROP TABLE sessions;
+DROP TABLE user_roles;
+DROP TABLE bookings;
+DROP TABLE trips;
+DROP TABLE buses;
+DROP TABLE users;
+DROP TABLE operators;
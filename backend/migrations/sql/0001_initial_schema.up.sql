@@ -0,0 +1,182 @@
+CREATE TABLE operators (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL
+);
+
+-- A user signs in with an email and password, a phone number and SMS
+-- codes, or both. Phone numbers are stored normalized as +251XXXXXXXXX.
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE,
+    password VARCHAR(255) NOT NULL DEFAULT '',
+    operator_id INTEGER REFERENCES operators(id),
+    language VARCHAR(5) NOT NULL DEFAULT 'en',
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    phone VARCHAR(20) UNIQUE,
+    phone_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    -- totp_secret is set on enrolment and only used once totp_enabled.
+    -- totp_last_step is the last accepted time step, to stop code replay.
+    totp_secret VARCHAR(64) NOT NULL DEFAULT '',
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step BIGINT NOT NULL DEFAULT 0,
+    CHECK (email IS NOT NULL OR phone IS NOT NULL)
+);
+
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    -- mfa is set once the session has passed two-factor authentication
+    mfa BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id);
+
+-- Refresh tokens are stored as SHA-256 hashes. A token is used once; the
+-- used rows are kept so that a replayed token can be detected.
+CREATE TABLE refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    session_id INTEGER NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
+
+-- Single-use tokens mailed to users, e.g. to verify their email address or
+-- reset their password. Only the SHA-256 hash is stored.
+CREATE TABLE user_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(20) NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One-time codes sent by SMS. user_id is set when the code links a phone to
+-- an existing account rather than logging in.
+CREATE TABLE otp_codes (
+    id SERIAL PRIMARY KEY,
+    phone VARCHAR(20) NOT NULL,
+    purpose VARCHAR(10) NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(255) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    consumed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX otp_codes_phone_idx ON otp_codes (phone, created_at);
+
+-- Two-factor recovery codes, stored as SHA-256 hashes.
+CREATE TABLE recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ,
+    UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE buses (
+    id SERIAL PRIMARY KEY,
+    operator_id INTEGER NOT NULL REFERENCES operators(id),
+    plate_number VARCHAR(50) UNIQUE NOT NULL,
+    capacity INTEGER NOT NULL,
+    seats TEXT[] NOT NULL,
+    amenities TEXT[] NOT NULL
+);
+
+CREATE TABLE trips (
+    id SERIAL PRIMARY KEY,
+    "from" VARCHAR(255) NOT NULL,
+    "to" VARCHAR(255) NOT NULL,
+    date DATE NOT NULL,
+    departure_time TIME NOT NULL,
+    arrival_time TIME NOT NULL,
+    price NUMERIC(10, 2) NOT NULL,
+    seats_available INTEGER NOT NULL,
+    bus_operator VARCHAR(255) NOT NULL,
+    duration VARCHAR(255) NOT NULL,
+    amenities TEXT[] NOT NULL,
+    intermediate_stops TEXT[] NOT NULL,
+    reviews JSONB NOT NULL,
+    seats TEXT[],
+    operator_id INTEGER REFERENCES operators(id),
+    bus_id INTEGER REFERENCES buses(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+    delay_minutes INTEGER NOT NULL DEFAULT 0,
+    status_reason TEXT NOT NULL DEFAULT '',
+    status_updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE bookings (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id),
+    trip_id INTEGER REFERENCES trips(id),
+    seats TEXT[] NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'confirmed',
+    refund_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    cancelled_at TIMESTAMPTZ
+);
+
+CREATE TABLE audit_log (
+    id SERIAL PRIMARY KEY,
+    actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    entity_id INTEGER,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE notification_outbox (
+    id SERIAL PRIMARY KEY,
+    channel VARCHAR(10) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX notification_outbox_due_idx ON notification_outbox (next_attempt_at) WHERE status IN ('pending', 'sending');
+
+CREATE TABLE jobs (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    run_at TIMESTAMPTZ NOT NULL,
+    key VARCHAR(255) UNIQUE,
+    group_key VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT NOT NULL DEFAULT '',
+    locked_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX jobs_group_idx ON jobs (group_key) WHERE status = 'pending';
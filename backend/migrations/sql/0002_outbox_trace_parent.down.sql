@@ -0,0 +1 @@
+ALTER TABLE notification_outbox DROP COLUMN trace_parent;
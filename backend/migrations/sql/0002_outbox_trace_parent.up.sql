@@ -0,0 +1 @@
+ALTER TABLE notification_outbox ADD COLUMN trace_parent TEXT NOT NULL DEFAULT '';
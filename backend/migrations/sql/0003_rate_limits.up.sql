@@ -0,0 +1,17 @@
+-- Token buckets of the rate limiter, when rate_limit.store is postgres.
+CREATE TABLE rate_limits (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limits_updated_idx ON rate_limits (updated_at);
+
+-- Consecutive failed logins per email address, for progressive lockout.
+-- Keyed by address rather than user so that unknown addresses are locked
+-- out the same way as registered ones.
+CREATE TABLE login_failures (
+    email VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,14 @@
+-- Responses to requests sent with an Idempotency-Key header, when
+-- idempotency.store is postgres. Keys are scoped to the user who sent them.
+-- status is 0 while the first request is being handled.
+CREATE TABLE idempotency_keys (
+    key VARCHAR(300) PRIMARY KEY,
+    fingerprint CHAR(64) NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    body BYTEA,
+    started_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,17 @@
+-- Users waiting for seats on sold-out trips. Freed seats are taken off the
+-- trip and held in offered_seats until offer_expires_at.
+CREATE TABLE waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    trip_id INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seats INTEGER NOT NULL CHECK (seats > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting',
+    offered_seats TEXT[],
+    offer_expires_at TIMESTAMPTZ,
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One active entry per user and trip
+CREATE UNIQUE INDEX waitlist_entries_active_idx ON waitlist_entries (trip_id, user_id) WHERE status IN ('waiting', 'offered');
+CREATE INDEX waitlist_entries_user_idx ON waitlist_entries (user_id);
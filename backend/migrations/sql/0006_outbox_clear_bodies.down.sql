@@ -0,0 +1 @@
+-- Cleared bodies cannot be restored.
@@ -0,0 +1,4 @@
+-- Bodies of delivered and abandoned notifications may hold one-time codes
+-- and account links. The outbox now clears them once it is done with a
+-- message; this clears those left from before.
+UPDATE notification_outbox SET body = '' WHERE status IN ('sent', 'failed');
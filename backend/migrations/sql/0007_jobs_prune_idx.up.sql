@@ -0,0 +1,2 @@
+-- Finished jobs are pruned by age; index the ones that qualify.
+CREATE INDEX jobs_done_idx ON jobs (finished_at) WHERE status = 'done';
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Review struct {
 	ID       int    `json:"id"`
 	Rating   int    `json:"rating"`
@@ -8,32 +13,111 @@ type Review struct {
 }
 
 type Trip struct {
-	ID                int      `json:"id"`
-	From              string   `json:"from"`
-	To                string   `json:"to"`
-	Date              string   `json:"date"`
-	DepartureTime     string   `json:"departureTime"`
-	ArrivalTime       string   `json:"arrivalTime"`
-	Price             float64  `json:"price"`
-	SeatsAvailable    int      `json:"seatsAvailable"`
-	BusOperator       string   `json:"busOperator"`
-	Duration          string   `json:"duration"`
-	Seats             []string `json:"seats"`
-	Amenities         []string `json:"amenities"`
-	IntermediateStops []string `json:"intermediateStops"`
-	Reviews           []Review `json:"reviews"`
+	ID                int        `json:"id"`
+	From              string     `json:"from"`
+	To                string     `json:"to"`
+	Date              string     `json:"date"`
+	DepartureTime     string     `json:"departureTime"`
+	ArrivalTime       string     `json:"arrivalTime"`
+	Price             float64    `json:"price"`
+	SeatsAvailable    int        `json:"seatsAvailable"`
+	BusOperator       string     `json:"busOperator"`
+	Duration          string     `json:"duration"`
+	Seats             []string   `json:"seats"`
+	Amenities         []string   `json:"amenities"`
+	IntermediateStops []string   `json:"intermediateStops"`
+	Reviews           []Review   `json:"reviews"`
+	OperatorID        int        `json:"operatorId,omitempty"`
+	BusID             int        `json:"busId,omitempty"`
+	Status            TripStatus `json:"status"`
+	DelayMinutes      int        `json:"delayMinutes"`
+	StatusReason      string     `json:"statusReason,omitempty"`
 }
 
 type User struct {
-    ID       int    `json:"id"`
-    Name     string `json:"name"`
-    Email    string `json:"email"`
-    Password string `json:"password"`
+    ID            int    `json:"id"`
+    Name          string `json:"name"`
+    Email         string `json:"email,omitempty"`
+    Password      string `json:"password"`
+    OperatorID    int    `json:"operatorId,omitempty"`
+    Language      string `json:"language,omitempty"`
+    EmailVerified bool   `json:"emailVerified"`
+    // Phone is in +251XXXXXXXXX form. Users have an email, a phone or both.
+    Phone         string `json:"phone,omitempty"`
+    PhoneVerified bool   `json:"phoneVerified"`
+    TOTPEnabled   bool   `json:"totpEnabled"`
+    TOTPSecret    string `json:"-"`
+    TOTPLastStep  int64  `json:"-"`
+}
+
+// Verified reports whether the user has proven control of an email address
+// or phone number.
+func (u User) Verified() bool {
+    return u.EmailVerified || u.PhoneVerified
 }
 
+const (
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+)
+
 type Booking struct {
-    ID     int      `json:"id"`
-    UserID int      `json:"userId"`
-    TripID int      `json:"trip_id"`
-    Seats  []string `json:"seats"`
+    ID             int      `json:"id"`
+    UserID         int      `json:"userId"`
+    TripID         int      `json:"trip_id"`
+    Seats          []string `json:"seats"`
+    Status         string   `json:"status,omitempty"`
+    // RefundEligible is set when the operator cancelled the trip, entitling
+    // the passenger to a full refund.
+    RefundEligible bool     `json:"refundEligible"`
+    CreatedAt      string   `json:"createdAt,omitempty"`
+}
+
+type Operator struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Bus struct {
+	ID          int      `json:"id"`
+	OperatorID  int      `json:"operatorId"`
+	PlateNumber string   `json:"plateNumber"`
+	Capacity    int      `json:"capacity"`
+	Seats       []string `json:"seats"`
+	Amenities   []string `json:"amenities"`
+}
+
+type AuditEntry struct {
+	ID          int             `json:"id"`
+	ActorUserID int             `json:"actorUserId"`
+	Action      string          `json:"action"`
+	Entity      string          `json:"entity"`
+	EntityID    int             `json:"entityId"`
+	Details     json.RawMessage `json:"details"`
+	CreatedAt   string          `json:"createdAt"`
+}
+
+// Session is a login on one device. Its refresh tokens are rotated on every
+// use; revoking the session invalidates them and its access tokens.
+type Session struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	// MFA is set once the session passed two-factor authentication.
+	MFA bool `json:"mfa"`
+	// Current marks the session of the token making the request.
+	Current bool `json:"current"`
+}
+
+// Page is the envelope returned by paginated list endpoints.
+type Page struct {
+	Items    interface{} `json:"items"`
+	Total    int         `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"pageSize"`
 }
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+type TripStatus string
+
+const (
+	TripScheduled TripStatus = "scheduled"
+	TripBoarding  TripStatus = "boarding"
+	TripDeparted  TripStatus = "departed"
+	TripArrived   TripStatus = "arrived"
+	TripDelayed   TripStatus = "delayed"
+	TripCancelled TripStatus = "cancelled"
+)
+
+// tripTransitions lists the states a trip may move to from each state.
+// Delays only apply before departure; a delayed trip may be delayed again to
+// revise the estimate. Arrived and cancelled are terminal.
+var tripTransitions = map[TripStatus][]TripStatus{
+	TripScheduled: {TripBoarding, TripDelayed, TripCancelled},
+	TripDelayed:   {TripScheduled, TripBoarding, TripDelayed, TripCancelled},
+	TripBoarding:  {TripDeparted, TripDelayed, TripCancelled},
+	TripDeparted:  {TripArrived},
+	TripArrived:   {},
+	TripCancelled: {},
+}
+
+// Valid reports whether s is a known trip status.
+func (s TripStatus) Valid() bool {
+	_, ok := tripTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a trip in state s may move to next.
+func (s TripStatus) CanTransitionTo(next TripStatus) bool {
+	for _, allowed := range tripTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Bookable reports whether seats can still be booked on a trip in state s.
+func (s TripStatus) Bookable() bool {
+	return s == TripScheduled || s == TripDelayed || s == TripBoarding
+}
+
+// TripStatusUpdate is the public view of a trip's current state.
+type TripStatusUpdate struct {
+	TripID       int        `json:"tripId"`
+	Status       TripStatus `json:"status"`
+	DelayMinutes int        `json:"delayMinutes"`
+	Reason       string     `json:"reason,omitempty"`
+	UpdatedAt    string     `json:"updatedAt,omitempty"`
+}
+
+// TripLocation is the time zone trip dates and departure times are given in.
+var TripLocation = time.FixedZone("EAT", 3*60*60)
+
+// Departure returns the scheduled departure of the trip, not counting any
+// delay. Date may be a plain date or a full timestamp as returned by the
+// database driver.
+func (t Trip) Departure() (time.Time, error) {
+	date := t.Date
+	if len(date) > len("2006-01-02") {
+		date = date[:len("2006-01-02")]
+	}
+	clock := t.DepartureTime
+	if len(clock) == len("15:04") {
+		clock += ":00"
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", date+" "+clock, TripLocation)
+}
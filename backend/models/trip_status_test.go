@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTripStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from, to TripStatus
+		want     bool
+	}{
+		{TripScheduled, TripBoarding, true},
+		{TripScheduled, TripDelayed, true},
+		{TripScheduled, TripDeparted, false},
+		{TripDelayed, TripDelayed, true},
+		{TripDelayed, TripScheduled, true},
+		{TripBoarding, TripDeparted, true},
+		{TripDeparted, TripArrived, true},
+		{TripDeparted, TripCancelled, false},
+		{TripArrived, TripScheduled, false},
+		{TripCancelled, TripScheduled, false},
+		{TripScheduled, TripStatus("teleported"), false},
+	}
+	for _, tc := range cases {
+		if got := tc.from.CanTransitionTo(tc.to); got != tc.want {
+			t.Errorf("%s -> %s: got %v want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestTripStatusBookable(t *testing.T) {
+	for status, want := range map[TripStatus]bool{
+		TripScheduled: true,
+		TripDelayed:   true,
+		TripBoarding:  true,
+		TripDeparted:  false,
+		TripArrived:   false,
+		TripCancelled: false,
+	} {
+		if got := status.Bookable(); got != want {
+			t.Errorf("%s: got %v want %v", status, got, want)
+		}
+	}
+}
+
+func TestTripStatusValid(t *testing.T) {
+	if !TripBoarding.Valid() {
+		t.Error("expected boarding to be valid")
+	}
+	if TripStatus("").Valid() {
+		t.Error("expected empty status to be invalid")
+	}
+}
+
+func TestTripDeparture(t *testing.T) {
+	want := time.Date(2025, 9, 1, 5, 30, 0, 0, time.UTC)
+	for _, trip := range []Trip{
+		{Date: "2025-09-01", DepartureTime: "08:30"},
+		{Date: "2025-09-01T00:00:00Z", DepartureTime: "08:30:00"},
+	} {
+		got, err := trip.Departure()
+		if err != nil {
+			t.Fatalf("Departure(%q, %q) failed: %v", trip.Date, trip.DepartureTime, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("Departure(%q, %q) = %v, want %v", trip.Date, trip.DepartureTime, got, want)
+		}
+	}
+
+	if _, err := (Trip{Date: "soon"}).Departure(); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
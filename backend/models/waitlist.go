@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	WaitlistWaiting = "waiting"
+	// WaitlistOffered entries hold freed seats until their offer expires.
+	WaitlistOffered = "offered"
+	WaitlistBooked  = "booked"
+	WaitlistExpired = "expired"
+	WaitlistLeft    = "left"
+	// WaitlistCancelled entries were ended by the trip being cancelled.
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry is a user waiting for seats on a sold-out trip.
+type WaitlistEntry struct {
+	ID     int `json:"id"`
+	TripID int `json:"tripId"`
+	UserID int `json:"userId"`
+	// Seats is how many seats the user wants.
+	Seats  int    `json:"seats"`
+	Status string `json:"status"`
+	// Position is the place of a waiting entry among the trip's waiting
+	// entries, from 1.
+	Position int `json:"position,omitempty"`
+	// OfferedSeats are held for the user until OfferExpiresAt.
+	OfferedSeats   []string   `json:"offeredSeats,omitempty"`
+	OfferExpiresAt *time.Time `json:"offerExpiresAt,omitempty"`
+	BookingID      int        `json:"bookingId,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,125 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"ticket-booking-app/backend/jobs"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var tracer = otel.Tracer("ticket-booking-app/backend/notifications")
+
+const (
+	defaultMaxAttempts  = 6
+	defaultBatchSize    = 20
+	defaultPollInterval = 5 * time.Second
+	defaultLease        = time.Minute
+)
+
+// Dispatcher delivers outbox messages through the notifier registered for
+// their channel.
+type Dispatcher struct {
+	Store     Store
+	Notifiers map[Channel]Notifier
+	// MaxAttempts is the number of sends tried before a message is marked
+	// failed. Zero means the default of 6.
+	MaxAttempts  int
+	PollInterval time.Duration
+	// Now is overridable for tests.
+	Now func() time.Time
+}
+
+func (d *Dispatcher) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+// Run dispatches due messages every PollInterval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	interval := d.PollInterval
+	if interval == 0 {
+		interval = defaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Notification dispatch failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due messages and tries to send each of
+// them, returning how many were sent.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	maxAttempts := d.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	claimed, err := d.Store.Claim(ctx, d.now(), defaultBatchSize, defaultLease)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, m := range claimed {
+		sendErr := d.send(ctx, m.Message)
+		if sendErr == nil {
+			if err := d.Store.MarkSent(ctx, m.ID); err != nil {
+				return sent, err
+			}
+			sent++
+			continue
+		}
+
+		var retryAt time.Time
+		if m.Attempts < maxAttempts {
+			retryAt = d.now().Add(jobs.Backoff(m.Attempts))
+		} else {
+			slog.WarnContext(ctx, "Giving up on notification", "notification_id", m.ID, "channel", m.Channel, "attempts", m.Attempts, "error", sendErr)
+		}
+		if err := d.Store.MarkFailed(ctx, m.ID, sendErr, retryAt); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// send delivers msg in a span that continues the trace of the request
+// which queued it.
+func (d *Dispatcher) send(ctx context.Context, msg Message) (err error) {
+	if msg.TraceParent != "" {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": msg.TraceParent})
+	}
+	ctx, span := tracer.Start(ctx, "notification.send")
+	span.SetAttributes(attribute.String("notification.channel", string(msg.Channel)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	notifier, ok := d.Notifiers[msg.Channel]
+	if !ok {
+		return fmt.Errorf("no notifier configured for channel %q", msg.Channel)
+	}
+	return notifier.Send(ctx, msg)
+}
@@ -0,0 +1,253 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var bookingData = Data{
+	Name:          "Abebe",
+	BookingID:     42,
+	From:          "Addis Ababa",
+	To:            "Adama",
+	Date:          "2025-09-01",
+	DepartureTime: "08:00",
+	Seats:         []string{"A1", "A2"},
+}
+
+func TestRenderEnglishAndAmharic(t *testing.T) {
+	en, err := Render(EventBookingConfirmed, ChannelEmail, "en", bookingData)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if en.Subject != "Booking #42 confirmed: Addis Ababa to Adama" || !strings.Contains(en.Body, "Seats: A1, A2") {
+		t.Errorf("unexpected English message: %+v", en)
+	}
+
+	am, err := Render(EventBookingConfirmed, ChannelEmail, "am", bookingData)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(am.Subject, "ተረጋግጧል") || !strings.Contains(am.Body, "ሰላም Abebe") {
+		t.Errorf("unexpected Amharic message: %+v", am)
+	}
+
+	fallback, _ := Render(EventBookingConfirmed, ChannelEmail, "fr", bookingData)
+	if fallback.Subject != en.Subject {
+		t.Errorf("expected unknown language to fall back to English, got %q", fallback.Subject)
+	}
+
+	sms, _ := Render(EventBookingConfirmed, ChannelSMS, "en", bookingData)
+	if sms.Subject != "" || sms.Body != "Booking #42 confirmed: Addis Ababa-Adama 2025-09-01 08:00, seats A1,A2." {
+		t.Errorf("unexpected SMS message: %+v", sms)
+	}
+}
+
+func TestEveryTemplateRenders(t *testing.T) {
+	for event, byLanguage := range catalog {
+		if _, ok := byLanguage[DefaultLanguage]; !ok {
+			t.Errorf("%s has no %s template", event, DefaultLanguage)
+		}
+		for language := range byLanguage {
+			for _, channel := range []Channel{ChannelEmail, ChannelSMS} {
+				msg, err := Render(event, channel, language, bookingData)
+				if err != nil || msg.Body == "" {
+					t.Errorf("%s/%s/%s: %v %+v", event, language, channel, err, msg)
+				}
+			}
+		}
+	}
+}
+
+func TestNotifySkipsMissingAddresses(t *testing.T) {
+	store := NewMemoryStore()
+	err := Notify(context.Background(), store, Recipient{Name: "Abebe", Email: "abebe@example.com"}, EventTripDelayed, Data{DelayMinutes: 20})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	all := store.All()
+	if len(all) != 1 || all[0].Channel != ChannelEmail || all[0].To != "abebe@example.com" {
+		t.Fatalf("expected a single email, got %+v", all)
+	}
+	if !strings.Contains(all[0].Body, "Hello Abebe") {
+		t.Errorf("expected recipient name in body, got %q", all[0].Body)
+	}
+}
+
+type notifierFunc func(ctx context.Context, msg Message) error
+
+func (f notifierFunc) Send(ctx context.Context, msg Message) error { return f(ctx, msg) }
+
+func TestDeliveryContinuesTheRequestTrace(t *testing.T) {
+	defer func(p propagation.TextMapPropagator) { otel.SetTextMapPropagator(p) }(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa},
+		TraceFlags: trace.FlagsSampled,
+	})
+	store := NewMemoryStore()
+	err := Notify(trace.ContextWithSpanContext(context.Background(), sc), store, Recipient{Email: "abebe@example.com"}, EventTripDelayed, Data{DelayMinutes: 20})
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if all := store.All(); len(all) != 1 || !strings.Contains(all[0].TraceParent, sc.TraceID().String()) {
+		t.Fatalf("expected the trace context to be queued, got %+v", all)
+	}
+
+	var traceID trace.TraceID
+	send := notifierFunc(func(ctx context.Context, msg Message) error {
+		traceID = trace.SpanContextFromContext(ctx).TraceID()
+		return nil
+	})
+	d := &Dispatcher{Store: store, Notifiers: map[Channel]Notifier{ChannelEmail: send}}
+	if sent, err := d.DispatchOnce(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("expected one message sent, got sent=%d err=%v", sent, err)
+	}
+	if traceID != sc.TraceID() {
+		t.Errorf("delivery ran in trace %s, want %s", traceID, sc.TraceID())
+	}
+}
+
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time { return c.now }
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{now: time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	notifier := &MemoryNotifier{Err: errors.New("smtp unavailable")}
+	d := &Dispatcher{Store: store, Notifiers: map[Channel]Notifier{ChannelEmail: notifier}, Now: c.Now}
+
+	store.Enqueue(ctx, Message{Channel: ChannelEmail, To: "abebe@example.com", Body: "hi"})
+
+	if sent, err := d.DispatchOnce(ctx); err != nil || sent != 0 {
+		t.Fatalf("expected failed send, got sent=%d err=%v", sent, err)
+	}
+	m := store.All()[0]
+	if m.Status != StatusPending || m.Attempts != 1 || !m.NextAttemptAt.Equal(c.now.Add(30*time.Second)) || m.LastError != "smtp unavailable" {
+		t.Fatalf("unexpected state after failure: %+v", m)
+	}
+
+	// Not due yet
+	notifier.Err = nil
+	if sent, _ := d.DispatchOnce(ctx); sent != 0 {
+		t.Fatalf("message was retried before its backoff elapsed")
+	}
+
+	c.now = c.now.Add(30 * time.Second)
+	if sent, _ := d.DispatchOnce(ctx); sent != 1 {
+		t.Fatalf("expected message to be sent after backoff")
+	}
+	if m := store.All()[0]; m.Status != StatusSent || m.Body != "" {
+		t.Errorf("expected the message sent and its body cleared, got %+v", m)
+	}
+	if len(notifier.Messages()) != 1 {
+		t.Errorf("expected one delivered message, got %d", len(notifier.Messages()))
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	c := &clock{now: time.Now()}
+	store := NewMemoryStore()
+	d := &Dispatcher{Store: store, Notifiers: map[Channel]Notifier{}, MaxAttempts: 2, Now: c.Now}
+
+	store.Enqueue(ctx, Message{Channel: ChannelSMS, To: "+251911000000", Body: "hi"})
+	d.DispatchOnce(ctx)
+	c.now = c.now.Add(time.Hour)
+	d.DispatchOnce(ctx)
+
+	m := store.All()[0]
+	if m.Status != StatusFailed || m.Attempts != 2 || m.Body != "" {
+		t.Errorf("expected message to fail permanently after 2 attempts, got %+v", m)
+	}
+}
+
+func TestExpiredLeaseIsReclaimed(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryStore()
+	store.Enqueue(ctx, Message{Channel: ChannelEmail, To: "abebe@example.com", Body: "hi"})
+
+	// A dispatcher claims the message and dies before reporting back
+	if claimed, _ := store.Claim(ctx, now, 10, time.Minute); len(claimed) != 1 {
+		t.Fatalf("expected to claim the message")
+	}
+	if claimed, _ := store.Claim(ctx, now.Add(30*time.Second), 10, time.Minute); len(claimed) != 0 {
+		t.Fatalf("leased message was claimed twice")
+	}
+	if claimed, _ := store.Claim(ctx, now.Add(time.Minute), 10, time.Minute); len(claimed) != 1 || claimed[0].Attempts != 2 {
+		t.Fatalf("expected message to be reclaimed after its lease expired, got %+v", claimed)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	n := &FileNotifier{Path: path}
+	n.Send(context.Background(), Message{Channel: ChannelEmail, To: "a@example.com", Subject: "one", Body: "1"})
+	n.Send(context.Background(), Message{Channel: ChannelSMS, To: "+251911000000", Body: "2"})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read sink: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(lines[1]), &msg); err != nil || msg.To != "+251911000000" {
+		t.Errorf("unexpected line %q: %v", lines[1], err)
+	}
+}
+
+func TestBuildEmailEncodesSubject(t *testing.T) {
+	raw := string(buildEmail("noreply@example.com", Message{To: "a@example.com", Subject: "ተረጋግጧል", Body: "ሰላም"}, time.Now()))
+	if !strings.Contains(raw, "Subject: =?utf-8?q?") {
+		t.Errorf("expected encoded subject, got %q", raw)
+	}
+	if !strings.HasSuffix(raw, "\r\n\r\nሰላም\r\n") {
+		t.Errorf("expected body after headers, got %q", raw)
+	}
+}
+
+func TestHTTPSMSNotifier(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := &HTTPSMSNotifier{URL: srv.URL, APIKey: "key", Sender: "BusTicket"}
+	if err := n.Send(context.Background(), Message{Channel: ChannelSMS, To: "+251911000000", Body: "hi"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got["to"] != "+251911000000" || got["from"] != "BusTicket" || got["message"] != "hi" {
+		t.Errorf("unexpected payload: %v", got)
+	}
+
+	n.APIKey = "wrong"
+	if err := n.Send(context.Background(), Message{Channel: ChannelSMS, To: "+251911000000", Body: "hi"}); err == nil {
+		t.Error("expected an error for a rejected request")
+	}
+}
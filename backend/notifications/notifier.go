@@ -0,0 +1,61 @@
+// Package notifications delivers email and SMS messages to passengers.
+//
+// Messages are rendered from templates, written to a persistent outbox and
+// delivered asynchronously by a Dispatcher, so a send that fails or is
+// interrupted by a restart is retried rather than lost.
+package notifications
+
+import (
+	"context"
+	"sync"
+)
+
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+// Message is a single rendered notification addressed to one recipient.
+// Subject is ignored by channels that have no notion of one. TraceParent
+// is the W3C trace context of the request that queued the message, so that
+// its delivery shows up in the same trace.
+type Message struct {
+	Channel     Channel `json:"channel"`
+	To          string  `json:"to"`
+	Subject     string  `json:"subject,omitempty"`
+	Body        string  `json:"body"`
+	TraceParent string  `json:"-"`
+}
+
+// Notifier delivers a message over one channel.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// MemoryNotifier records messages instead of sending them. It is meant for
+// tests.
+type MemoryNotifier struct {
+	mu       sync.Mutex
+	messages []Message
+	// Err, if set, is returned from every Send.
+	Err error
+}
+
+func (n *MemoryNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.Err != nil {
+		return n.Err
+	}
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (n *MemoryNotifier) Messages() []Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Message(nil), n.messages...)
+}
@@ -0,0 +1,51 @@
+package notifications
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Recipient describes how a user can be reached. Empty addresses are
+// skipped.
+type Recipient struct {
+	Name     string
+	Email    string
+	Phone    string
+	Language string
+}
+
+// Notify renders event for every channel the recipient can be reached on and
+// adds the messages to the outbox.
+func Notify(ctx context.Context, store Store, to Recipient, event Event, data Data) error {
+	if data.Name == "" {
+		data.Name = to.Name
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	addresses := []struct {
+		channel Channel
+		address string
+	}{
+		{ChannelEmail, to.Email},
+		{ChannelSMS, to.Phone},
+	}
+	for _, a := range addresses {
+		if a.address == "" {
+			continue
+		}
+		msg, err := Render(event, a.channel, to.Language, data)
+		if err != nil {
+			return err
+		}
+		msg.To = a.address
+		msg.TraceParent = carrier.Get("traceparent")
+		if err := store.Enqueue(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
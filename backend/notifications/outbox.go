@@ -0,0 +1,189 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	StatusPending OutboxStatus = "pending"
+	StatusSending OutboxStatus = "sending"
+	StatusSent    OutboxStatus = "sent"
+	StatusFailed  OutboxStatus = "failed"
+)
+
+// OutboxMessage is a Message together with its delivery state.
+type OutboxMessage struct {
+	ID int
+	Message
+	Status        OutboxStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+}
+
+// Store persists the outbox.
+type Store interface {
+	Enqueue(ctx context.Context, msg Message) error
+	// Claim returns up to limit messages that are due for delivery and leases
+	// them for lease, so that concurrent dispatchers do not send them twice.
+	// A message whose lease expires (e.g. because the process died mid-send)
+	// becomes claimable again.
+	Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error)
+	// MarkSent and MarkFailed, when giving up, clear the message body: it
+	// may hold one-time codes and account links, which must not outlive
+	// their delivery.
+	MarkSent(ctx context.Context, id int) error
+	// MarkFailed records a failed attempt. A zero retryAt gives up on the
+	// message for good.
+	MarkFailed(ctx context.Context, id int, sendErr error, retryAt time.Time) error
+}
+
+// PostgresStore keeps the outbox in the notification_outbox table.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) Enqueue(ctx context.Context, msg Message) error {
+	_, err := s.DB.ExecContext(ctx, "INSERT INTO notification_outbox (channel, recipient, subject, body, trace_parent) VALUES ($1, $2, $3, $4, $5)",
+		msg.Channel, msg.To, msg.Subject, msg.Body, msg.TraceParent)
+	return err
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		UPDATE notification_outbox SET status = 'sending', attempts = attempts + 1, locked_until = $1
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE (status = 'pending' AND next_attempt_at <= $2) OR (status = 'sending' AND locked_until <= $2)
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, channel, recipient, subject, body, trace_parent, status, attempts, next_attempt_at, last_error`,
+		now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Channel, &m.To, &m.Subject, &m.Body, &m.TraceParent, &m.Status, &m.Attempts, &m.NextAttemptAt, &m.LastError); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, m)
+	}
+	return claimed, rows.Err()
+}
+
+func (s *PostgresStore) MarkSent(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE notification_outbox SET status = 'sent', body = '', sent_at = NOW(), locked_until = NULL WHERE id = $1", id)
+	return err
+}
+
+func (s *PostgresStore) MarkFailed(ctx context.Context, id int, sendErr error, retryAt time.Time) error {
+	if retryAt.IsZero() {
+		_, err := s.DB.ExecContext(ctx, "UPDATE notification_outbox SET status = 'failed', body = '', last_error = $1, locked_until = NULL WHERE id = $2", sendErr.Error(), id)
+		return err
+	}
+	_, err := s.DB.ExecContext(ctx, "UPDATE notification_outbox SET status = 'pending', last_error = $1, next_attempt_at = $2, locked_until = NULL WHERE id = $3",
+		sendErr.Error(), retryAt, id)
+	return err
+}
+
+// MemoryStore is an in-process Store for tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	nextID   int
+	messages map[int]*OutboxMessage
+	leases   map[int]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{messages: map[int]*OutboxMessage{}, leases: map[int]time.Time{}}
+}
+
+func (s *MemoryStore) Enqueue(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.messages[s.nextID] = &OutboxMessage{ID: s.nextID, Message: msg, Status: StatusPending}
+	return nil
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0, len(s.messages))
+	for id := range s.messages {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var claimed []OutboxMessage
+	for _, id := range ids {
+		if len(claimed) == limit {
+			break
+		}
+		m := s.messages[id]
+		due := m.Status == StatusPending && !m.NextAttemptAt.After(now)
+		expired := m.Status == StatusSending && !s.leases[id].After(now)
+		if !due && !expired {
+			continue
+		}
+		m.Status = StatusSending
+		m.Attempts++
+		s.leases[id] = now.Add(lease)
+		claimed = append(claimed, *m)
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) MarkSent(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[id].Status = StatusSent
+	s.messages[id].Body = ""
+	delete(s.leases, id)
+	return nil
+}
+
+func (s *MemoryStore) MarkFailed(ctx context.Context, id int, sendErr error, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.messages[id]
+	m.LastError = sendErr.Error()
+	if retryAt.IsZero() {
+		m.Status = StatusFailed
+		m.Body = ""
+	} else {
+		m.Status = StatusPending
+		m.NextAttemptAt = retryAt
+	}
+	delete(s.leases, id)
+	return nil
+}
+
+// All returns a snapshot of every message in the store, ordered by ID.
+func (s *MemoryStore) All() []OutboxMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make([]OutboxMessage, 0, len(s.messages))
+	for _, m := range s.messages {
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
@@ -0,0 +1,359 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+type Event string
+
+const (
+	EventBookingConfirmed Event = "booking_confirmed"
+	EventBookingCancelled Event = "booking_cancelled"
+	EventTripDelayed      Event = "trip_delayed"
+	EventTripCancelled    Event = "trip_cancelled"
+	EventTripBoarding     Event = "trip_boarding"
+	EventTripReminder     Event = "trip_reminder"
+	EventWaitlistOffer    Event = "waitlist_offer"
+	EventWaitlistEnded    Event = "waitlist_ended"
+	EventVerifyEmail      Event = "verify_email"
+	EventPasswordReset    Event = "password_reset"
+	EventOTPCode          Event = "otp_code"
+)
+
+const DefaultLanguage = "en"
+
+// Data is the set of values available to every template. Fields that do not
+// apply to an event are left empty.
+type Data struct {
+	Name           string
+	BookingID      int
+	From           string
+	To             string
+	Date           string
+	DepartureTime  string
+	Seats          []string
+	DelayMinutes   int
+	Reason         string
+	RefundEligible bool
+	// HoursUntilDeparture is set for reminders.
+	HoursUntilDeparture int
+	// HoldMinutes is how long seats offered from a waitlist are held.
+	HoldMinutes int
+	// Link is the action link of account emails and waitlist offers.
+	Link string
+	// Code is a one-time login code.
+	Code string
+}
+
+type messageTemplate struct {
+	subject string
+	email   string
+	sms     string
+}
+
+// catalog holds the message texts by event and language. Every event must
+// have an entry for DefaultLanguage.
+var catalog = map[Event]map[string]messageTemplate{
+	EventBookingConfirmed: {
+		"en": {
+			subject: "Booking #{{.BookingID}} confirmed: {{.From}} to {{.To}}",
+			email: `Hello {{.Name}},
+
+Your booking #{{.BookingID}} is confirmed.
+
+Route: {{.From}} to {{.To}}
+Date: {{.Date}}
+Departure: {{.DepartureTime}}
+Seats: {{join .Seats ", "}}
+
+Please arrive at the station 30 minutes before departure.`,
+			sms: `Booking #{{.BookingID}} confirmed: {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}}, seats {{join .Seats ","}}.`,
+		},
+		"am": {
+			subject: "ቦታ ማስያዣ #{{.BookingID}} ተረጋግጧል፦ ከ{{.From}} ወደ {{.To}}",
+			email: `ሰላም {{.Name}}፣
+
+ቦታ ማስያዣዎ #{{.BookingID}} ተረጋግጧል።
+
+መስመር፦ ከ{{.From}} ወደ {{.To}}
+ቀን፦ {{.Date}}
+መነሻ ሰዓት፦ {{.DepartureTime}}
+መቀመጫዎች፦ {{join .Seats ", "}}
+
+እባክዎ ከመነሻ ሰዓቱ 30 ደቂቃ ቀደም ብለው ጣቢያ ይድረሱ።`,
+			sms: `ቦታ ማስያዣ #{{.BookingID}} ተረጋግጧል፦ {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}}፣ መቀመጫ {{join .Seats ","}}።`,
+		},
+	},
+	EventBookingCancelled: {
+		"en": {
+			subject: "Booking #{{.BookingID}} cancelled",
+			email: `Hello {{.Name}},
+
+Your booking #{{.BookingID}} from {{.From}} to {{.To}} on {{.Date}} has been cancelled.{{if .Reason}}
+Reason: {{.Reason}}{{end}}{{if .RefundEligible}}
+
+You are eligible for a full refund.{{end}}`,
+			sms: `Booking #{{.BookingID}} {{.From}}-{{.To}} {{.Date}} was cancelled.{{if .RefundEligible}} Full refund available.{{end}}`,
+		},
+		"am": {
+			subject: "ቦታ ማስያዣ #{{.BookingID}} ተሰርዟል",
+			email: `ሰላም {{.Name}}፣
+
+ከ{{.From}} ወደ {{.To}} በ{{.Date}} ያስያዙት ቦታ #{{.BookingID}} ተሰርዟል።{{if .Reason}}
+ምክንያት፦ {{.Reason}}{{end}}{{if .RefundEligible}}
+
+ሙሉ ገንዘብዎ ተመላሽ ይደረጋል።{{end}}`,
+			sms: `ቦታ ማስያዣ #{{.BookingID}} {{.From}}-{{.To}} {{.Date}} ተሰርዟል።{{if .RefundEligible}} ሙሉ ገንዘብዎ ተመላሽ ይደረጋል።{{end}}`,
+		},
+	},
+	EventTripDelayed: {
+		"en": {
+			subject: "Delay: {{.From}} to {{.To}} on {{.Date}}",
+			email: `Hello {{.Name}},
+
+Your bus from {{.From}} to {{.To}} on {{.Date}}, scheduled to depart at {{.DepartureTime}}, is delayed by about {{.DelayMinutes}} minutes.{{if .Reason}}
+Reason: {{.Reason}}{{end}}
+
+We apologize for the inconvenience.`,
+			sms: `Your bus {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}} is delayed ~{{.DelayMinutes}} min.{{if .Reason}} {{.Reason}}{{end}}`,
+		},
+		"am": {
+			subject: "መዘግየት፦ ከ{{.From}} ወደ {{.To}} በ{{.Date}}",
+			email: `ሰላም {{.Name}}፣
+
+በ{{.Date}} ከ{{.From}} ወደ {{.To}} በ{{.DepartureTime}} ሊነሳ የነበረው አውቶቡስዎ በግምት {{.DelayMinutes}} ደቂቃ ዘግይቷል።{{if .Reason}}
+ምክንያት፦ {{.Reason}}{{end}}
+
+ለተፈጠረው ችግር ይቅርታ እንጠይቃለን።`,
+			sms: `አውቶቡስዎ {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}} በግምት {{.DelayMinutes}} ደቂቃ ዘግይቷል።{{if .Reason}} {{.Reason}}{{end}}`,
+		},
+	},
+	EventTripCancelled: {
+		"en": {
+			subject: "Cancelled: {{.From}} to {{.To}} on {{.Date}}",
+			email: `Hello {{.Name}},
+
+We are sorry to tell you that the bus from {{.From}} to {{.To}} on {{.Date}} at {{.DepartureTime}} has been cancelled.{{if .Reason}}
+Reason: {{.Reason}}{{end}}
+
+Your booking #{{.BookingID}} has been cancelled and you are eligible for a full refund.`,
+			sms: `Your bus {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}} is cancelled. Booking #{{.BookingID}} will be fully refunded.`,
+		},
+		"am": {
+			subject: "ተሰርዟል፦ ከ{{.From}} ወደ {{.To}} በ{{.Date}}",
+			email: `ሰላም {{.Name}}፣
+
+በ{{.Date}} በ{{.DepartureTime}} ከ{{.From}} ወደ {{.To}} የሚሄደው አውቶቡስ መሰረዙን ስንገልጽ እናዝናለን።{{if .Reason}}
+ምክንያት፦ {{.Reason}}{{end}}
+
+ቦታ ማስያዣዎ #{{.BookingID}} ተሰርዟል፤ ሙሉ ገንዘብዎ ተመላሽ ይደረጋል።`,
+			sms: `አውቶቡስዎ {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}} ተሰርዟል። ለቦታ ማስያዣ #{{.BookingID}} ሙሉ ገንዘብዎ ተመላሽ ይደረጋል።`,
+		},
+	},
+	EventTripBoarding: {
+		"en": {
+			subject: "Now boarding: {{.From}} to {{.To}}",
+			email: `Hello {{.Name}},
+
+Your bus from {{.From}} to {{.To}} is now boarding. Seats: {{join .Seats ", "}}.`,
+			sms: `Now boarding: {{.From}}-{{.To}}. Seats {{join .Seats ","}}.`,
+		},
+		"am": {
+			subject: "ተሳፋሪዎች እየተሳፈሩ ነው፦ ከ{{.From}} ወደ {{.To}}",
+			email: `ሰላም {{.Name}}፣
+
+ከ{{.From}} ወደ {{.To}} የሚሄደው አውቶቡስዎ ተሳፋሪዎችን እየጫነ ነው። መቀመጫዎች፦ {{join .Seats ", "}}።`,
+			sms: `አውቶቡስዎ {{.From}}-{{.To}} ተሳፋሪዎችን እየጫነ ነው። መቀመጫ {{join .Seats ","}}።`,
+		},
+	},
+	EventTripReminder: {
+		"en": {
+			subject: "Reminder: {{.From}} to {{.To}} departs in {{.HoursUntilDeparture}} hours",
+			email: `Hello {{.Name}},
+
+This is a reminder that your bus from {{.From}} to {{.To}} departs in {{.HoursUntilDeparture}} hours.
+
+Booking: #{{.BookingID}}
+Date: {{.Date}}
+Departure: {{.DepartureTime}}
+Seats: {{join .Seats ", "}}
+
+Please arrive at the station 30 minutes before departure.`,
+			sms: `Reminder: bus {{.From}}-{{.To}} departs in {{.HoursUntilDeparture}}h ({{.Date}} {{.DepartureTime}}), seats {{join .Seats ","}}.`,
+		},
+		"am": {
+			subject: "ማስታወሻ፦ ከ{{.From}} ወደ {{.To}} የሚሄደው አውቶቡስ በ{{.HoursUntilDeparture}} ሰዓት ውስጥ ይነሳል",
+			email: `ሰላም {{.Name}}፣
+
+ከ{{.From}} ወደ {{.To}} የሚሄደው አውቶቡስዎ በ{{.HoursUntilDeparture}} ሰዓት ውስጥ እንደሚነሳ ለማስታወስ ነው።
+
+ቦታ ማስያዣ፦ #{{.BookingID}}
+ቀን፦ {{.Date}}
+መነሻ ሰዓት፦ {{.DepartureTime}}
+መቀመጫዎች፦ {{join .Seats ", "}}
+
+እባክዎ ከመነሻ ሰዓቱ 30 ደቂቃ በፊት ጣቢያ ይድረሱ።`,
+			sms: `ማስታወሻ፦ አውቶቡስዎ {{.From}}-{{.To}} በ{{.HoursUntilDeparture}} ሰዓት ውስጥ ይነሳል ({{.Date}} {{.DepartureTime}})፣ መቀመጫ {{join .Seats ","}}።`,
+		},
+	},
+	EventWaitlistOffer: {
+		"en": {
+			subject: "Seats available: {{.From}} to {{.To}} on {{.Date}}",
+			email: `Hello {{.Name}},
+
+Seats have opened up on the trip you are waiting for, from {{.From}} to {{.To}}.
+
+Date: {{.Date}}
+Departure: {{.DepartureTime}}
+Seats: {{join .Seats ", "}}
+
+The seats are held for you for {{.HoldMinutes}} minutes. Confirm your booking here:
+
+{{.Link}}
+
+If you do not confirm in time, the seats are offered to the next person on the waitlist.`,
+			sms: `Seats {{join .Seats ","}} on bus {{.From}}-{{.To}} ({{.Date}} {{.DepartureTime}}) are held for you for {{.HoldMinutes}} min. Confirm: {{.Link}}`,
+		},
+		"am": {
+			subject: "ቦታ ተገኝቷል፦ ከ{{.From}} ወደ {{.To}} በ{{.Date}}",
+			email: `ሰላም {{.Name}}፣
+
+እየጠበቁት ባለው ከ{{.From}} ወደ {{.To}} በሚሄደው ጉዞ ላይ መቀመጫዎች ተለቀዋል።
+
+ቀን፦ {{.Date}}
+መነሻ ሰዓት፦ {{.DepartureTime}}
+መቀመጫዎች፦ {{join .Seats ", "}}
+
+መቀመጫዎቹ ለ{{.HoldMinutes}} ደቂቃ ለእርስዎ ተይዘዋል። ቦታ ማስያዣዎን እዚህ ያረጋግጡ፦
+
+{{.Link}}
+
+በጊዜው ካላረጋገጡ መቀመጫዎቹ በተጠባባቂ ዝርዝሩ ላይ ላለው ቀጣይ ሰው ይሰጣሉ።`,
+			sms: `መቀመጫ {{join .Seats ","}} በአውቶቡስ {{.From}}-{{.To}} ({{.Date}} {{.DepartureTime}}) ለ{{.HoldMinutes}} ደቂቃ ለእርስዎ ተይዟል። ያረጋግጡ፦ {{.Link}}`,
+		},
+	},
+	EventWaitlistEnded: {
+		"en": {
+			subject: "Cancelled: {{.From}} to {{.To}} on {{.Date}}",
+			email: `Hello {{.Name}},
+
+We are sorry to tell you that the bus from {{.From}} to {{.To}} on {{.Date}} at {{.DepartureTime}} has been cancelled.{{if .Reason}}
+Reason: {{.Reason}}{{end}}
+
+You are no longer on its waitlist{{if .Seats}}, and the seats held for you ({{join .Seats ", "}}) have been released{{end}}. You have not been charged.`,
+			sms: `Bus {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}} is cancelled. Your waitlist place has ended; you have not been charged.`,
+		},
+		"am": {
+			subject: "ተሰርዟል፦ ከ{{.From}} ወደ {{.To}} በ{{.Date}}",
+			email: `ሰላም {{.Name}}፣
+
+በ{{.Date}} በ{{.DepartureTime}} ከ{{.From}} ወደ {{.To}} የሚሄደው አውቶቡስ መሰረዙን ስንገልጽ እናዝናለን።{{if .Reason}}
+ምክንያት፦ {{.Reason}}{{end}}
+
+ከተጠባባቂ ዝርዝሩ ተወግደዋል{{if .Seats}}፤ ለእርስዎ ተይዘው የነበሩት መቀመጫዎች ({{join .Seats ", "}}) ተለቀዋል{{end}}። ምንም ክፍያ አልተከፈለዎትም።`,
+			sms: `አውቶቡስ {{.From}}-{{.To}} {{.Date}} {{.DepartureTime}} ተሰርዟል። በተጠባባቂ ዝርዝሩ ላይ የነበረዎት ቦታ አብቅቷል፤ ምንም ክፍያ አልተከፈለዎትም።`,
+		},
+	},
+	EventVerifyEmail: {
+		"en": {
+			subject: "Confirm your email address",
+			email: `Hello {{.Name}},
+
+Please confirm your email address by opening the link below. The link is valid for 48 hours.
+
+{{.Link}}
+
+If you did not create an account, you can ignore this email.`,
+			sms: `Confirm your email address: {{.Link}}`,
+		},
+		"am": {
+			subject: "የኢሜይል አድራሻዎን ያረጋግጡ",
+			email: `ሰላም {{.Name}}፣
+
+እባክዎ ከዚህ በታች ያለውን ሊንክ በመክፈት የኢሜይል አድራሻዎን ያረጋግጡ። ሊንኩ ለ48 ሰዓታት ያገለግላል።
+
+{{.Link}}
+
+መለያ ካልፈጠሩ ይህን ኢሜይል ችላ ማለት ይችላሉ።`,
+			sms: `የኢሜይል አድራሻዎን ያረጋግጡ፦ {{.Link}}`,
+		},
+	},
+	EventPasswordReset: {
+		"en": {
+			subject: "Reset your password",
+			email: `Hello {{.Name}},
+
+We received a request to reset your password. Open the link below to choose a new one. The link is valid for one hour and can be used once.
+
+{{.Link}}
+
+If you did not ask for this, you can ignore this email; your password will not change.`,
+			sms: `Reset your password: {{.Link}}`,
+		},
+		"am": {
+			subject: "የይለፍ ቃልዎን ይቀይሩ",
+			email: `ሰላም {{.Name}}፣
+
+የይለፍ ቃልዎን ለመቀየር ጥያቄ ደርሶናል። አዲስ የይለፍ ቃል ለመምረጥ ከዚህ በታች ያለውን ሊንክ ይክፈቱ። ሊንኩ ለአንድ ሰዓት ያገለግላል፤ አንድ ጊዜ ብቻ መጠቀም ይቻላል።
+
+{{.Link}}
+
+ይህን ካልጠየቁ ኢሜይሉን ችላ ማለት ይችላሉ፤ የይለፍ ቃልዎ አይቀየርም።`,
+			sms: `የይለፍ ቃልዎን ይቀይሩ፦ {{.Link}}`,
+		},
+	},
+	EventOTPCode: {
+		"en": {
+			subject: "Your login code",
+			email:   `Your login code is {{.Code}}. It expires in 5 minutes. Do not share it with anyone.`,
+			sms:     `{{.Code}} is your BusTicket code. It expires in 5 minutes. Do not share it with anyone.`,
+		},
+		"am": {
+			subject: "የመግቢያ ኮድዎ",
+			email:   `የመግቢያ ኮድዎ {{.Code}} ነው። በ5 ደቂቃ ውስጥ ጊዜው ያልፋል። ለማንም አያጋሩት።`,
+			sms:     `{{.Code}} የBusTicket ኮድዎ ነው። በ5 ደቂቃ ውስጥ ጊዜው ያልፋል። ለማንም አያጋሩት።`,
+		},
+	},
+}
+
+var funcs = template.FuncMap{"join": strings.Join}
+
+// Render produces the message for event in the given language, falling back
+// to English for languages without a translation.
+func Render(event Event, channel Channel, language string, data Data) (Message, error) {
+	byLanguage, ok := catalog[event]
+	if !ok {
+		return Message{}, fmt.Errorf("notifications: unknown event %q", event)
+	}
+	tmpl, ok := byLanguage[language]
+	if !ok {
+		tmpl = byLanguage[DefaultLanguage]
+	}
+
+	msg := Message{Channel: channel}
+	var err error
+	if channel == ChannelSMS {
+		msg.Body, err = execute(tmpl.sms, data)
+		return msg, err
+	}
+	if msg.Subject, err = execute(tmpl.subject, data); err != nil {
+		return msg, err
+	}
+	msg.Body, err = execute(tmpl.email, data)
+	return msg, err
+}
+
+func execute(text string, data Data) (string, error) {
+	t, err := template.New("").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limits table, so that every
+// server process using the database shares them.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// Take locks the bucket's row, so concurrent requests for the same key take
+// their tokens one after the other.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		key, float64(limit.Burst), now); err != nil {
+		return false, 0, err
+	}
+	var b bucket
+	if err := tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE", key).Scan(&b.tokens, &b.updated); err != nil {
+		return false, 0, err
+	}
+	allowed, retryAfter := b.take(limit, now)
+	if _, err := tx.ExecContext(ctx, "UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1", key, b.tokens, b.updated); err != nil {
+		return false, 0, err
+	}
+	return allowed, retryAfter, tx.Commit()
+}
+
+// Prune deletes the buckets unused since before.
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Run prunes idle buckets every few minutes until ctx is done.
+func (s *PostgresStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Prune(ctx, time.Now().Add(-idleTTL)); err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Error pruning rate limit buckets", "error", err)
+			}
+		}
+	}
+}
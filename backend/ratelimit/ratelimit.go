@@ -0,0 +1,172 @@
+// Package ratelimit limits how often clients may call the server, with a
+// token bucket per key such as a client address or an account. Buckets are
+// kept in process by MemoryStore or shared between processes by
+// PostgresStore.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"ticket-booking-app/backend/apierror"
+	"ticket-booking-app/backend/config"
+	"ticket-booking-app/backend/metrics"
+)
+
+// Limit allows bursts of Burst requests, refilled at Rate requests per
+// second. A zero Limit allows everything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests per minute, all at once if need be.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Store keeps the buckets.
+type Store interface {
+	// Take removes a token from the bucket of key, creating a full one if
+	// there is none. If the bucket is empty it returns false and how long
+	// until the next token.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+// idleTTL is how long a bucket is kept after it was last used. Every limit
+// must refill within it, as a dropped bucket comes back full.
+const idleTTL = time.Hour
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b for the time passed since it was last updated and takes a
+// token from it if there is one.
+func (b *bucket) take(limit Limit, now time.Time) (bool, time.Duration) {
+	// Clocks of different processes may disagree slightly; a bucket is never
+	// refilled for time that has not passed.
+	if now.After(b.updated) {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+		b.updated = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+// Limiter applies the configured limits. A nil Limiter allows everything.
+type Limiter struct {
+	Store Store
+	// PerIP limits the requests from one client address to the endpoints
+	// wrapped in Middleware.
+	PerIP Limit
+	// PerAccount limits the attempts on one account, see AllowAccount.
+	PerAccount Limit
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header
+	// gives the client address.
+	TrustedProxies []netip.Prefix
+}
+
+// New returns a Limiter for cfg keeping its buckets in store.
+func New(store Store, cfg config.RateLimitConfig) (*Limiter, error) {
+	l := &Limiter{Store: store, PerIP: PerMinute(cfg.AuthPerIP), PerAccount: PerMinute(cfg.LoginPerAccount)}
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := config.ParsePrefix(proxy)
+		if err != nil {
+			return nil, err
+		}
+		l.TrustedProxies = append(l.TrustedProxies, prefix)
+	}
+	return l, nil
+}
+
+// Middleware answers 429 to clients that exceed PerIP. All endpoints
+// wrapped by the same Limiter share each client's bucket.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.allow(w, r, "ip", l.ClientIP(r).String(), l.PerIP) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// AllowAccount takes a token for an attempt on account, e.g. a login to an
+// email address, whichever address it comes from. It writes a 429 and
+// returns false if the account has none left.
+func (l *Limiter) AllowAccount(w http.ResponseWriter, r *http.Request, account string) bool {
+	if l == nil {
+		return true
+	}
+	return l.allow(w, r, "account", strings.ToLower(account), l.PerAccount)
+}
+
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, kind, key string, limit Limit) bool {
+	if limit.Burst == 0 {
+		return true
+	}
+	ok, retryAfter, err := l.Store.Take(r.Context(), kind+":"+key, limit, time.Now())
+	if err != nil {
+		// Failing open keeps logins working while the store is unavailable
+		slog.ErrorContext(r.Context(), "Rate limit store failed", "limit", kind, "error", err)
+		return true
+	}
+	if !ok {
+		metrics.RateLimited(kind)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		apierror.Write(w, r, apierror.ErrTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// ClientIP returns the address of the client. When the request comes from a
+// trusted proxy, it is the nearest address in X-Forwarded-For that is not
+// itself a trusted proxy.
+func (l *Limiter) ClientIP(r *http.Request) netip.Addr {
+	addr := remoteAddr(r.RemoteAddr)
+	if !l.trusted(addr) {
+		return addr
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !l.trusted(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+func (l *Limiter) trusted(addr netip.Addr) bool {
+	for _, prefix := range l.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteAddr(s string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	addr, _ := netip.ParseAddr(s)
+	return addr.Unmap()
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreRefillsBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := PerMinute(2)
+	now := time.Date(2025, 9, 1, 8, 0, 0, 0, time.UTC)
+	take := func(key string) (bool, time.Duration) {
+		ok, retryAfter, err := store.Take(context.Background(), key, limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok, retryAfter
+	}
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := take("a"); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	if ok, retryAfter := take("a"); ok || retryAfter != 30*time.Second {
+		t.Errorf("expected a refusal for 30s, got %v %v", ok, retryAfter)
+	}
+	if ok, _ := take("b"); !ok {
+		t.Error("keys share a bucket")
+	}
+
+	now = now.Add(30 * time.Second)
+	if ok, _ := take("a"); !ok {
+		t.Error("bucket not refilled")
+	}
+	if ok, _ := take("a"); ok {
+		t.Error("bucket refilled too fast")
+	}
+
+	// Idle buckets are dropped and come back full
+	now = now.Add(2 * idleTTL)
+	take("c")
+	if _, ok := store.buckets["a"]; ok {
+		t.Error("idle bucket kept")
+	}
+}
+
+func TestMiddlewareLimitsPerClientAddress(t *testing.T) {
+	l := &Limiter{Store: NewMemoryStore(), PerIP: PerMinute(1)}
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	call := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/auth/login", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := call("192.0.2.1:1234"); rr.Code != http.StatusOK {
+		t.Fatalf("first request: got %d", rr.Code)
+	}
+	rr := call("192.0.2.1:5678")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("second request: got %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := call("192.0.2.2:1234"); rr.Code != http.StatusOK {
+		t.Errorf("other client: got %d", rr.Code)
+	}
+
+	var disabled *Limiter
+	if !disabled.AllowAccount(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil), "a@example.com") {
+		t.Error("nil Limiter refused a request")
+	}
+}
+
+func TestClientIPBehindTrustedProxies(t *testing.T) {
+	l := &Limiter{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	cases := []struct {
+		remote, forwarded, want string
+	}{
+		{"192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{"10.0.0.2:1234", "198.51.100.7", "198.51.100.7"},
+		{"10.0.0.2:1234", "203.0.113.9, 198.51.100.7, 10.0.0.3", "198.51.100.7"},
+		{"10.0.0.2:1234", "", "10.0.0.2"},
+		{"[::ffff:192.0.2.1]:1234", "", "192.0.2.1"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if got := l.ClientIP(req).String(); got != tc.want {
+			t.Errorf("%s via %q: got %s want %s", tc.remote, tc.forwarded, got, tc.want)
+		}
+	}
+}
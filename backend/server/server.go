@@ -0,0 +1,140 @@
+// Package server runs the HTTP server and the background workers for the
+// lifetime of the process, and stops them in order when it is asked to quit.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ticket-booking-app/backend/config"
+)
+
+// Server is an http.Server together with the workers that run next to it.
+type Server struct {
+	HTTP *http.Server
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once shutdown starts. Zero waits for them indefinitely.
+	ShutdownTimeout time.Duration
+
+	workers  []*worker
+	draining atomic.Bool
+}
+
+type worker struct {
+	name    string
+	run     func(ctx context.Context)
+	running atomic.Bool
+}
+
+// New returns a Server serving handler with the timeouts in cfg.
+func New(cfg config.ServerConfig, handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+		ShutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// Go registers a background worker. It is started by Serve and must return
+// once its context is cancelled.
+func (s *Server) Go(name string, run func(ctx context.Context)) {
+	s.workers = append(s.workers, &worker{name: name, run: run})
+}
+
+// CheckWorkers fails once shutdown has started, so that load balancers stop
+// sending traffic, or if a worker is not running. It is meant as a health
+// check.
+func (s *Server) CheckWorkers(ctx context.Context) error {
+	if s.draining.Load() {
+		return errors.New("shutting down")
+	}
+	var stopped []string
+	for _, w := range s.workers {
+		if !w.running.Load() {
+			stopped = append(stopped, w.name)
+		}
+	}
+	if len(stopped) > 0 {
+		return fmt.Errorf("not running: %s", strings.Join(stopped, ", "))
+	}
+	return nil
+}
+
+// Run listens on the configured address and serves until ctx is done or the
+// process receives SIGINT or SIGTERM, see Serve.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return s.Serve(ctx, ln)
+}
+
+// Serve starts the workers and serves requests on ln until ctx is done.
+// It then stops accepting connections, waits up to ShutdownTimeout for
+// in-flight requests, and finally stops the workers and waits for them, so
+// that work started by a request is not cut short.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var wg sync.WaitGroup
+	for _, w := range s.workers {
+		wg.Add(1)
+		w.running.Store(true)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(workerCtx)
+			w.running.Store(false)
+			slog.Info("Stopped worker", "worker", w.name)
+		}(w)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- s.HTTP.Serve(ln) }()
+	slog.Info("Server listening", "addr", ln.Addr().String())
+
+	var err error
+	select {
+	case err = <-served:
+		// The listener failed before shutdown was requested
+	case <-ctx.Done():
+		s.draining.Store(true)
+		slog.Info("Shutting down, waiting for requests in flight", "timeout", s.ShutdownTimeout.String())
+		shutdownCtx := context.Background()
+		if s.ShutdownTimeout > 0 {
+			var cancel context.CancelFunc
+			shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.ShutdownTimeout)
+			defer cancel()
+		}
+		if err = s.HTTP.Shutdown(shutdownCtx); err != nil {
+			// Cut off whatever is still running
+			s.HTTP.Close()
+		}
+		<-served
+	}
+
+	stopWorkers()
+	wg.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"ticket-booking-app/backend/config"
+)
+
+func TestShutdownDrainsRequestsThenStopsWorkers(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+	s := New(config.ServerConfig{ShutdownTimeout: 5 * time.Second}, handler)
+
+	workerStopped := make(chan struct{})
+	s.Go("test worker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	})
+
+	if err := s.CheckWorkers(context.Background()); err == nil {
+		t.Error("workers reported running before Serve")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+
+	responded := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			t.Errorf("request in flight failed: %v", err)
+			responded <- 0
+			return
+		}
+		resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+	<-started
+	if err := s.CheckWorkers(context.Background()); err != nil {
+		t.Errorf("workers while serving: %v", err)
+	}
+	cancel()
+
+	select {
+	case <-workerStopped:
+		t.Fatal("worker stopped while a request was in flight")
+	case <-served:
+		t.Fatal("Serve returned while a request was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := s.CheckWorkers(context.Background()); err == nil {
+		t.Error("still reported ready while shutting down")
+	}
+	close(release)
+	if code := <-responded; code != http.StatusOK {
+		t.Errorf("in-flight request: got status %d", code)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+	select {
+	case <-workerStopped:
+	default:
+		t.Error("worker still running after Serve returned")
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("listener still accepting connections")
+	}
+}
+
+func TestShutdownTimeoutCutsOffSlowRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+	s := New(config.ServerConfig{ShutdownTimeout: 50 * time.Millisecond}, handler)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, ln) }()
+
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the shutdown deadline to be reported, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the shutdown timeout")
+	}
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"ticket-booking-app/backend/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup(t *testing.T) {
+	defer func(tp trace.TracerProvider, p propagation.TextMapPropagator) {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(p)
+	}(otel.GetTracerProvider(), otel.GetTextMapPropagator())
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		t.Error("a provider was installed without an exporter")
+	}
+	if fields := otel.GetTextMapPropagator().Fields(); len(fields) == 0 {
+		t.Error("trace context is not propagated without an exporter")
+	}
+	shutdown(context.Background())
+
+	shutdown, err = Setup(context.Background(), config.TracingConfig{Exporter: "stdout", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); !ok {
+		t.Error("no provider installed for the stdout exporter")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+
+	if _, err := Setup(context.Background(), config.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
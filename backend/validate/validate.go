@@ -0,0 +1,240 @@
+// Package validate checks request values against rules declared in struct
+// tags, e.g.
+//
+//	type signupRequest struct {
+//		Email    string `json:"email" validate:"required,email,max=254"`
+//		Password string `json:"password" validate:"required,password"`
+//	}
+//
+// Rules are separated by commas. Apart from required, a rule is only
+// checked when the field is not its zero value, so optional fields may be
+// left out.
+//
+//	required    not empty or zero
+//	min=N       at least N characters or elements, or a number of at least N
+//	max=N       at most N characters or elements, or a number of at most N
+//	email       a plain email address
+//	date        a date such as 2025-09-01
+//	clock       a time of day such as 07:30 or 07:30:00
+//	oneof=a b   one of the listed values
+//	unique      a list without duplicates
+//	password    a password meeting the policy, see Password
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Errors maps the JSON names of invalid fields to what is wrong with them.
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + " " + e[field]
+	}
+	return "invalid request: " + strings.Join(parts, ", ")
+}
+
+// Struct checks the fields of the struct v, or of the struct v points to,
+// and returns nil if they are all valid. It panics on unknown rules, which
+// are programming errors.
+func Struct(v interface{}) Errors {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	errs := Errors{}
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		if msg := field(rv.Field(i), tag); msg != "" {
+			errs[jsonName(rt.Field(i))] = msg
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// field returns what is wrong with v according to the rules in tag, or "".
+func field(v reflect.Value, tag string) string {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			if isEmpty(v) {
+				return "is required"
+			}
+			continue
+		}
+		if v.IsZero() {
+			return ""
+		}
+		check, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("validate: unknown rule %q", name))
+		}
+		if msg := check(v, arg); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+var rules = map[string]func(v reflect.Value, arg string) string{
+	"min": func(v reflect.Value, arg string) string {
+		n := size(v)
+		if min := number(arg); n < min {
+			return bound(v, "at least", arg)
+		}
+		return ""
+	},
+	"max": func(v reflect.Value, arg string) string {
+		n := size(v)
+		if max := number(arg); n > max {
+			return bound(v, "at most", arg)
+		}
+		return ""
+	},
+	"email": func(v reflect.Value, _ string) string {
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return "must be an email address"
+		}
+		return ""
+	},
+	"date": func(v reflect.Value, _ string) string {
+		if _, err := time.Parse("2006-01-02", v.String()); err != nil {
+			return "must be a date such as 2025-09-01"
+		}
+		return ""
+	},
+	"clock": func(v reflect.Value, _ string) string {
+		for _, layout := range []string{"15:04", "15:04:05"} {
+			if _, err := time.Parse(layout, v.String()); err == nil {
+				return ""
+			}
+		}
+		return "must be a time such as 07:30"
+	},
+	"oneof": func(v reflect.Value, arg string) string {
+		for _, allowed := range strings.Fields(arg) {
+			if fmt.Sprint(v.Interface()) == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(arg), ", ")
+	},
+	"unique": func(v reflect.Value, _ string) string {
+		seen := map[interface{}]bool{}
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i).Interface()
+			if seen[item] {
+				return fmt.Sprintf("must not repeat %v", item)
+			}
+			seen[item] = true
+		}
+		return ""
+	},
+	"password": func(v reflect.Value, _ string) string {
+		return Password(v.String())
+	},
+}
+
+// size is the length of strings in characters, of lists in elements, and
+// the value of numbers.
+func size(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	panic(fmt.Sprintf("validate: cannot measure a %s", v.Kind()))
+}
+
+func bound(v reflect.Value, cmp, arg string) string {
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be %s %s characters long", cmp, arg)
+	case reflect.Slice, reflect.Map:
+		return fmt.Sprintf("must have %s %s items", cmp, arg)
+	}
+	return fmt.Sprintf("must be %s %s", cmp, arg)
+}
+
+func number(arg string) float64 {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: %q is not a number", arg))
+	}
+	return n
+}
+
+// Password limits. bcrypt ignores everything after 72 bytes.
+const (
+	MinPasswordLength = 8
+	MaxPasswordBytes  = 72
+)
+
+// Password returns what is wrong with password under the policy, or "". A
+// password must be at least MinPasswordLength characters and at most
+// MaxPasswordBytes bytes long, and must not consist of letters or digits
+// only.
+func Password(password string) string {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return fmt.Sprintf("must be at least %d characters long", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordBytes {
+		return fmt.Sprintf("must be at most %d bytes long", MaxPasswordBytes)
+	}
+	onlyLetters, onlyDigits := true, true
+	for _, c := range password {
+		if !unicode.IsLetter(c) {
+			onlyLetters = false
+		}
+		if !unicode.IsDigit(c) {
+			onlyDigits = false
+		}
+	}
+	if onlyLetters || onlyDigits {
+		return "must mix letters with digits or symbols"
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+type testRequest struct {
+	Name     string   `json:"name" validate:"required,max=5"`
+	Email    string   `json:"email" validate:"email"`
+	Date     string   `json:"date" validate:"date"`
+	Time     string   `json:"time" validate:"clock"`
+	Language string   `json:"language" validate:"oneof=en am"`
+	Count    int      `json:"count" validate:"min=1,max=3"`
+	Seats    []string `json:"seats" validate:"required,unique"`
+	Password string   `json:"password" validate:"password"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := testRequest{Name: "Abebe", Email: "abebe@example.com", Date: "2025-09-01", Time: "07:30", Language: "am", Count: 2, Seats: []string{"A1", "A2"}, Password: "hunter22"}
+	if errs := Struct(&valid); errs != nil {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+
+	// Optional fields may be left out
+	if errs := Struct(testRequest{Name: "Abebe", Seats: []string{"A1"}}); errs != nil {
+		t.Fatalf("expected no errors for optional fields, got %v", errs)
+	}
+
+	invalid := testRequest{Name: "  ", Email: "Abebe <abebe@example.com>", Date: "01/09/2025", Time: "7.30", Language: "fr", Count: 4, Seats: []string{"A1", "A1"}, Password: "password"}
+	errs := Struct(invalid)
+	for _, field := range []string{"name", "email", "date", "time", "language", "count", "seats", "password"} {
+		if errs[field] == "" {
+			t.Errorf("expected an error for %s, got %v", field, errs)
+		}
+	}
+	if len(errs) != 8 {
+		t.Errorf("expected 8 errors, got %v", errs)
+	}
+}
+
+func TestUnknownRulePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an unknown rule")
+		}
+	}()
+	Struct(struct {
+		Name string `validate:"shiny"`
+	}{Name: "x"})
+}
+
+func TestPassword(t *testing.T) {
+	cases := map[string]bool{
+		"hunter22":                true,
+		"correct horse":           true,
+		"ጥሩ-የይለፍ-ቃል":              true,
+		"short1":                  false,
+		"onlyletters":             false,
+		"1234567890":              false,
+		strings.Repeat("ab1", 25): false,
+	}
+	for password, ok := range cases {
+		if msg := Password(password); (msg == "") != ok {
+			t.Errorf("Password(%q) = %q, want valid %v", password, msg, ok)
+		}
+	}
+}
@@ -14,6 +14,8 @@ import ContactPage from './pages/ContactPage';
 import BookingConfirmationPage from './pages/BookingConfirmationPage';
 import SupportPage from './pages/SupportPage';
 import PaymentPage from './pages/PaymentPage';
+import VerifyEmailPage from './pages/VerifyEmailPage';
+import ResetPasswordPage from './pages/ResetPasswordPage';
 import ErrorBoundary from './components/ErrorBoundary';
 import { ToastContainer, toast } from 'react-toastify';
 import 'react-toastify/dist/ReactToastify.css';
@@ -59,6 +61,8 @@ function App() {
               <Route path="booking-confirmation" element={<BookingConfirmationPage />} />
               <Route path="support" element={<SupportPage />} />
               <Route path="payment" element={<PaymentPage />} />
+              <Route path="verify-email" element={<VerifyEmailPage />} />
+              <Route path="reset-password" element={<ResetPasswordPage />} />
             </Route>
           </Routes>
         </ErrorBoundary>
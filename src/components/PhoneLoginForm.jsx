@@ -0,0 +1,87 @@
+import React, { useState } from 'react';
+import { useNavigate } from 'react-router-dom';
+import { toast } from 'react-toastify';
+import { useTranslation } from 'react-i18next';
+import useAuthStore from '../store/authStore';
+import { requestOTP } from '../services/api';
+
+// PhoneLoginForm logs in, or signs up, with a code sent by SMS.
+const PhoneLoginForm = () => {
+  const [phone, setPhone] = useState('');
+  const [code, setCode] = useState('');
+  const [name, setName] = useState('');
+  const [codeSent, setCodeSent] = useState(false);
+  const loginWithPhone = useAuthStore((state) => state.loginWithPhone);
+  const navigate = useNavigate();
+  const { t } = useTranslation();
+
+  const handleSendCode = async (e) => {
+    e.preventDefault();
+    if (await requestOTP(phone)) {
+      setCodeSent(true);
+      toast.success(t('common.codeSent'));
+    } else {
+      toast.error(t('common.couldNotSendCode'));
+    }
+  };
+
+  const handleVerify = async (e) => {
+    e.preventDefault();
+    if (await loginWithPhone(phone, code, name)) {
+      navigate('/');
+    } else {
+      toast.error(t('common.invalidCode'));
+    }
+  };
+
+  return (
+    <form onSubmit={codeSent ? handleVerify : handleSendCode}>
+      <div className="form-floating mb-3">
+        <input
+          type="tel"
+          className="form-control"
+          id="loginPhone"
+          placeholder="0911 23 45 67"
+          value={phone}
+          onChange={(e) => setPhone(e.target.value)}
+          disabled={codeSent}
+          required
+        />
+        <label htmlFor="loginPhone">{t('common.phoneNumber')}</label>
+      </div>
+      {codeSent && (
+        <>
+          <div className="form-floating mb-3">
+            <input
+              type="text"
+              inputMode="numeric"
+              className="form-control"
+              id="loginCode"
+              placeholder="123456"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+              required
+            />
+            <label htmlFor="loginCode">{t('common.smsCode')}</label>
+          </div>
+          <div className="form-floating mb-3">
+            <input
+              type="text"
+              className="form-control"
+              id="loginName"
+              placeholder="Name"
+              value={name}
+              onChange={(e) => setName(e.target.value)}
+            />
+            <label htmlFor="loginName">{t('common.nameForNewAccounts')}</label>
+          </div>
+        </>
+      )}
+      <button className="w-100 btn btn-lg btn-outline-primary" type="submit">
+        {codeSent ? t('common.login') : t('common.sendCode')}
+      </button>
+    </form>
+  );
+};
+
+export default PhoneLoginForm;
@@ -1,7 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { useParams, Link, useNavigate } from 'react-router-dom';
 import useAuthStore from '../store/authStore';
-import { getTripById, createBooking } from '../services/api';
+import { getTripById, createBooking, watchSeats } from '../services/api';
 import SeatSelection from '../components/SeatSelection';
 import { useTranslation } from 'react-i18next';
 import { toast } from 'react-toastify';
@@ -12,6 +12,28 @@ const mockPromoCodes = [
   { code: 'FLAT5', discount: 5, type: 'flat' }, // $5 off
 ];
 
+// applySeatEvent returns trip updated with an event from watchSeats. Taken
+// seats stay on the seat map, greyed out.
+const applySeatEvent = (trip, event) => {
+  const seats = [...trip.seats, ...event.seats.filter((seat) => !trip.seats.includes(seat))];
+  let takenSeats;
+  switch (event.type) {
+    case 'snapshot':
+      takenSeats = seats.filter((seat) => !event.seats.includes(seat));
+      break;
+    case 'booked':
+    case 'held':
+      takenSeats = [...trip.takenSeats, ...event.seats.filter((seat) => !trip.takenSeats.includes(seat))];
+      break;
+    case 'released':
+      takenSeats = trip.takenSeats.filter((seat) => !event.seats.includes(seat));
+      break;
+    default:
+      return trip;
+  }
+  return { ...trip, seats, takenSeats, seatsAvailable: event.seatsAvailable };
+};
+
 const BookingPage = () => {
   const { id } = useParams();
   const { user, currency } = useAuthStore(); // Get currency from store
@@ -47,6 +69,19 @@ const BookingPage = () => {
     fetchTrip();
   }, [id, currency]); // Re-fetch trip if currency changes
 
+  useEffect(() => {
+    // Keep the seat map current as other passengers book and cancel
+    return watchSeats(id, (event) => {
+      setTrip((current) => current && applySeatEvent(current, event));
+      if (event.type !== 'released') {
+        // Let go of selected seats that someone else took meanwhile
+        setSelectedSeats((selected) => selected.filter((seat) => (
+          event.type === 'snapshot' ? event.seats.includes(seat) : !event.seats.includes(seat)
+        )));
+      }
+    });
+  }, [id]);
+
   const handleSelectSeat = (seat) => {
     setSelectedSeats((prevSelectedSeats) => {
       if (prevSelectedSeats.includes(seat)) {
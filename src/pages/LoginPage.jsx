@@ -1,26 +1,77 @@
 import React, { useState } from 'react';
-import { useNavigate } from 'react-router-dom';
+import { Link, useNavigate } from 'react-router-dom';
 import useAuthStore from '../store/authStore';
 import { toast } from 'react-toastify';
 import { useTranslation } from 'react-i18next';
+import PhoneLoginForm from '../components/PhoneLoginForm';
 
 const LoginPage = () => {
   const [email, setEmail] = useState('john.doe@example.com');
   const [password, setPassword] = useState('password123');
+  const [mfaCode, setMfaCode] = useState('');
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
   const login = useAuthStore((state) => state.login);
+  const verifyMFA = useAuthStore((state) => state.verifyMFA);
+  const mfaToken = useAuthStore((state) => state.mfaToken);
   const navigate = useNavigate();
   const { t } = useTranslation();
 
-  const handleLogin = (e) => {
+  const handleLogin = async (e) => {
     e.preventDefault();
-    const success = login(email, password);
-    if (success) {
+    const result = await login(email, password);
+    if (result === 'mfa') {
+      return;
+    }
+    if (result) {
       navigate('/');
     } else {
       toast.error(t('common.invalidEmailOrPassword'));
     }
   };
 
+  const handleMFA = async (e) => {
+    e.preventDefault();
+    const success = useRecoveryCode ? await verifyMFA(undefined, mfaCode) : await verifyMFA(mfaCode);
+    if (success) {
+      navigate('/');
+    } else {
+      toast.error(t('common.invalidCode'));
+    }
+  };
+
+  if (mfaToken) {
+    return (
+      <div className="container col-xl-10 col-xxl-8 px-4 py-5 slick-design">
+        <div className="row align-items-center g-lg-5 py-5">
+          <div className="col-md-10 mx-auto col-lg-5">
+            <form className="p-4 p-md-5 border rounded-3 bg-white shadow" onSubmit={handleMFA}>
+              <h2 className="text-center mb-4">{t('common.twoFactorTitle')}</h2>
+              <p className="text-muted">{useRecoveryCode ? t('common.enterRecoveryCode') : t('common.enterAuthenticatorCode')}</p>
+              <div className="form-floating mb-3">
+                <input
+                  type="text"
+                  className="form-control"
+                  id="mfaCode"
+                  autoComplete="one-time-code"
+                  value={mfaCode}
+                  onChange={(e) => setMfaCode(e.target.value)}
+                  required
+                />
+                <label htmlFor="mfaCode">{useRecoveryCode ? t('common.recoveryCode') : t('common.verificationCode')}</label>
+              </div>
+              <button className="w-100 btn btn-lg btn-primary" type="submit">{t('common.verify')}</button>
+              <div className="text-center mt-3">
+                <button type="button" className="btn btn-link" onClick={() => { setUseRecoveryCode(!useRecoveryCode); setMfaCode(''); }}>
+                  {useRecoveryCode ? t('common.useAuthenticatorCode') : t('common.useRecoveryCode')}
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      </div>
+    );
+  }
+
   return (
     <div className="container col-xl-10 col-xxl-8 px-4 py-5 slick-design">
        <div className="row align-items-center g-lg-5 py-5">
@@ -52,7 +103,14 @@ const LoginPage = () => {
               <label htmlFor="floatingPassword">{t('common.password')}</label>
             </div>
             <button className="w-100 btn btn-lg btn-primary" type="submit">{t('common.login')}</button>
+            <div className="text-center mt-3">
+              <Link to="/reset-password">{t('common.forgotPassword')}</Link>
+            </div>
           </form>
+          <div className="p-4 p-md-5 border rounded-3 bg-white shadow mt-3">
+            <h5 className="text-center mb-3">{t('common.orUsePhone')}</h5>
+            <PhoneLoginForm />
+          </div>
         </div>
       </div>
     </div>
@@ -1,7 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { Link } from 'react-router-dom';
 import useAuthStore from '../store/authStore';
-import { getProfile } from '../services/api';
+import { getProfile, getTripStatus } from '../services/api';
 import { useTranslation } from 'react-i18next';
 import { toast } from 'react-toastify';
 import LoadingSpinner from '../components/LoadingSpinner';
@@ -65,12 +65,20 @@ const ProfilePage = () => {
     toast.info(t('common.reminderSet', { from: booking.from, to: booking.to, date: booking.date, time: booking.departureTime }));
   };
 
-  const handleCheckStatus = (booking) => {
-    const delayChance = Math.random();
-    if (delayChance < 0.3) { // 30% chance of delay
-      toast.warn(t('common.tripDelayed', { from: booking.from, to: booking.to, date: booking.date }));
-    } else {
-      toast.success(t('common.tripOnTime', { from: booking.from, to: booking.to, date: booking.date }));
+  const handleCheckStatus = async (booking) => {
+    const params = { from: booking.from, to: booking.to, date: booking.date };
+    try {
+      const status = await getTripStatus(booking.trip_id);
+      if (status.status === 'cancelled') {
+        toast.error(t('common.tripCancelled', { ...params, reason: status.reason }));
+      } else if (status.status === 'delayed') {
+        toast.warn(t('common.tripDelayed', { ...params, minutes: status.delayMinutes, reason: status.reason }));
+      } else {
+        toast.success(t(`common.tripStatus.${status.status}`, params));
+      }
+    } catch (error) {
+      console.error("Failed to fetch trip status:", error);
+      toast.error(t('common.failedToLoadTripStatus'));
     }
   };
 
@@ -0,0 +1,84 @@
+import React, { useState } from 'react';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { toast } from 'react-toastify';
+import { useTranslation } from 'react-i18next';
+import { requestPasswordReset, resetPassword } from '../services/api';
+
+// ResetPasswordPage asks for the account email, or for a new password when
+// opened from the emailed link.
+const ResetPasswordPage = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
+  const navigate = useNavigate();
+  const { t } = useTranslation();
+
+  const handleRequest = async (e) => {
+    e.preventDefault();
+    try {
+      await requestPasswordReset(email);
+      toast.success(t('common.resetLinkSent'));
+    } catch (error) {
+      toast.error(t('common.somethingWentWrong'));
+    }
+  };
+
+  const handleReset = async (e) => {
+    e.preventDefault();
+    try {
+      if (await resetPassword(token, password)) {
+        toast.success(t('common.passwordResetSuccess'));
+        navigate('/login');
+      } else {
+        toast.error(t('common.invalidOrExpiredLink'));
+      }
+    } catch (error) {
+      toast.error(t('common.somethingWentWrong'));
+    }
+  };
+
+  return (
+    <div className="container col-xl-10 col-xxl-8 px-4 py-5 slick-design">
+      <div className="row align-items-center g-lg-5 py-5">
+        <div className="col-md-10 mx-auto col-lg-5">
+          <form className="p-4 p-md-5 border rounded-3 bg-white shadow" onSubmit={token ? handleReset : handleRequest}>
+            <h2 className="text-center mb-4">{t('common.resetPassword')}</h2>
+            {token ? (
+              <div className="form-floating mb-3">
+                <input
+                  type="password"
+                  className="form-control"
+                  id="newPassword"
+                  placeholder="Password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                />
+                <label htmlFor="newPassword">{t('common.newPassword')}</label>
+              </div>
+            ) : (
+              <div className="form-floating mb-3">
+                <input
+                  type="email"
+                  className="form-control"
+                  id="resetEmail"
+                  placeholder="name@example.com"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  required
+                />
+                <label htmlFor="resetEmail">{t('common.emailAddress')}</label>
+              </div>
+            )}
+            <button className="w-100 btn btn-lg btn-primary" type="submit">
+              {token ? t('common.resetPassword') : t('common.sendResetLink')}
+            </button>
+          </form>
+        </div>
+      </div>
+    </div>
+  );
+};
+
+export default ResetPasswordPage;
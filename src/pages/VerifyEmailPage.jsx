@@ -0,0 +1,38 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { useTranslation } from 'react-i18next';
+import { verifyEmail } from '../services/api';
+
+const VerifyEmailPage = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState('pending');
+  const { t } = useTranslation();
+
+  useEffect(() => {
+    const token = searchParams.get('token');
+    if (!token) {
+      setStatus('failed');
+      return;
+    }
+    verifyEmail(token)
+      .then((ok) => setStatus(ok ? 'verified' : 'failed'))
+      .catch(() => setStatus('failed'));
+  }, [searchParams]);
+
+  return (
+    <div className="container col-xl-10 col-xxl-8 px-4 py-5 slick-design">
+      <div className="p-4 p-md-5 border rounded-3 bg-white shadow text-center">
+        {status === 'pending' && <p>{t('common.verifyingEmail')}</p>}
+        {status === 'verified' && (
+          <>
+            <h2 className="mb-3">{t('common.emailVerified')}</h2>
+            <Link to="/" className="btn btn-primary">{t('common.searchBuses')}</Link>
+          </>
+        )}
+        {status === 'failed' && <p className="text-danger">{t('common.invalidOrExpiredLink')}</p>}
+      </div>
+    </div>
+  );
+};
+
+export default VerifyEmailPage;
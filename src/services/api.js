@@ -13,8 +13,58 @@ const getConvertedPrice = (priceUSD, targetCurrency) => {
   return (priceUSD * rate).toFixed(2);
 };
 
+// refreshSession exchanges the stored refresh token for a new token pair.
+// Refresh tokens are single use, so concurrent callers share one request.
+let refreshing = null;
+export const refreshSession = () => {
+  if (!refreshing) {
+    refreshing = (async () => {
+      const refreshToken = localStorage.getItem('refreshToken');
+      if (!refreshToken) {
+        return false;
+      }
+      const response = await fetch(`${API_URL}/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refreshToken }),
+      });
+      if (!response.ok) {
+        localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
+        return false;
+      }
+      const tokens = await response.json();
+      localStorage.setItem('token', tokens.token);
+      localStorage.setItem('refreshToken', tokens.refreshToken);
+      return true;
+    })().finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
+// authFetch sends an authenticated request, refreshing the access token and
+// retrying once if it has expired.
+const authFetch = async (url, options = {}) => {
+  const send = () => fetch(url, {
+    ...options,
+    headers: {
+      ...options.headers,
+      'Authorization': `Bearer ${localStorage.getItem('token')}`,
+    },
+  });
+  const response = await send();
+  if (response.status === 401 && await refreshSession()) {
+    return send();
+  }
+  return response;
+};
+
 export const searchTrips = async (from, to, date, flexibleDateRange, currency = 'ETB') => {
-  const params = new URLSearchParams({ from, to, date, flexibleDateRange, currency });
+  const params = new URLSearchParams({ from, to, currency });
+  if (date) params.set('date', date);
+  if (flexibleDateRange) params.set('flexibleDateRange', flexibleDateRange);
   const response = await fetch(`${API_URL}/trips/search?${params}`);
   const results = await response.json();
 
@@ -30,41 +80,42 @@ export const searchTrips = async (from, to, date, flexibleDateRange, currency =
 };
 
 export const getTripById = async (id, currency = 'ETB') => {
-  const token = localStorage.getItem('token');
-  const response = await fetch(`${API_URL}/trips/${id}`, {
-    headers: {
-      'Authorization': `Bearer ${token}`,
-    },
-  });
+  const response = await authFetch(`${API_URL}/trips/${id}`);
   const trip = await response.json();
 
   if (trip) {
-    // Simulate real-time seat availability: randomly reduce seats available and mark some as taken
-    const simulatedTrip = { ...trip };
-    const seatsToTake = Math.floor(Math.random() * 5); // Take up to 5 random seats
-    const takenSeats = [];
-    const availableSeats = [...simulatedTrip.seats]; // Start with all seats from the original trip
-
-    for (let i = 0; i < seatsToTake; i++) {
-      if (availableSeats.length > 0) {
-        const randomIndex = Math.floor(Math.random() * availableSeats.length);
-        takenSeats.push(availableSeats.splice(randomIndex, 1)[0]);
-      }
-    }
-    simulatedTrip.seatsAvailable = availableSeats.length;
-    simulatedTrip.seats = availableSeats; // Update the seats array to only include available ones
-    simulatedTrip.takenSeats = takenSeats; // Store taken seats separately
+    // Taken seats are filled in by watchSeats as other passengers book
+    const convertedTrip = { ...trip, takenSeats: [] };
 
     // Convert price to target currency
-    simulatedTrip.price = getConvertedPrice(simulatedTrip.price, currency);
-    simulatedTrip.originalPriceUSD = trip.price; // Store original USD price for reference
+    convertedTrip.price = getConvertedPrice(trip.price, currency);
+    convertedTrip.originalPriceUSD = trip.price; // Store original USD price for reference
 
-    return simulatedTrip;
+    return convertedTrip;
   } else {
     return null;
   }
 };
 
+// watchSeats calls onEvent with the seat events of a trip as they happen:
+// first a snapshot of the free seats, then booked, held and released seats.
+// It returns a function that stops watching.
+export const watchSeats = (tripId, onEvent) => {
+  const source = new EventSource(`${API_URL}/trips/${tripId}/seats/events`);
+  ['snapshot', 'booked', 'held', 'released'].forEach((type) => {
+    source.addEventListener(type, (e) => onEvent(JSON.parse(e.data)));
+  });
+  return () => source.close();
+};
+
+export const getTripStatus = async (id) => {
+  const response = await fetch(`${API_URL}/trips/${id}/status`);
+  if (!response.ok) {
+    throw new Error(`Failed to fetch trip status: ${response.status}`);
+  }
+  return response.json();
+};
+
 export const signup = async (name, email, password) => {
   const response = await fetch(`${API_URL}/auth/signup`, {
     method: 'POST',
@@ -87,25 +138,117 @@ export const login = async (email, password) => {
   return response.json();
 };
 
-export const createBooking = async (tripId, seats) => {
-  const token = localStorage.getItem('token');
-  const response = await fetch(`${API_URL}/bookings`, {
+// verifyMFA completes a login that answered with mfaRequired, using either
+// an authenticator code or a recovery code.
+export const verifyMFA = async (mfaToken, code, recoveryCode) => {
+  const response = await fetch(`${API_URL}/auth/mfa`, {
     method: 'POST',
-    headers: {
-      'Content-Type': 'application/json',
-      'Authorization': `Bearer ${token}`,
-    },
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ mfaToken, code, recoveryCode }),
+  });
+  return response.json().catch(() => ({}));
+};
+
+// Pass the same idempotencyKey when retrying a booking, so that the server
+// answers the retry with the first booking instead of making another.
+export const createBooking = async (tripId, seats, idempotencyKey) => {
+  const headers = {
+    'Content-Type': 'application/json',
+  };
+  if (idempotencyKey) {
+    headers['Idempotency-Key'] = idempotencyKey;
+  }
+  const response = await authFetch(`${API_URL}/bookings`, {
+    method: 'POST',
+    headers,
     body: JSON.stringify({ trip_id: tripId, seats }),
   });
   return response.json();
 };
 
+// The waitlist of a trip is for passengers who want more seats than are
+// free. The entry returned has the passenger's position while they wait,
+// and offeredSeats once seats are held for them to confirm.
+export const joinWaitlist = async (tripId, seats) => {
+  const response = await authFetch(`${API_URL}/trips/${tripId}/waitlist`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ seats }),
+  });
+  return response.json();
+};
+
+export const getWaitlistEntry = async (tripId) => {
+  const response = await authFetch(`${API_URL}/trips/${tripId}/waitlist`);
+  return response.json();
+};
+
+export const leaveWaitlist = async (tripId) => {
+  const response = await authFetch(`${API_URL}/trips/${tripId}/waitlist`, { method: 'DELETE' });
+  return response.ok;
+};
+
+export const confirmWaitlistOffer = async (tripId) => {
+  const response = await authFetch(`${API_URL}/trips/${tripId}/waitlist/confirm`, { method: 'POST' });
+  return response.json();
+};
+
 export const getProfile = async () => {
-  const token = localStorage.getItem('token');
-  const response = await fetch(`${API_URL}/profile`, {
-    headers: {
-      'Authorization': `Bearer ${token}`,
-    },
+  const response = await authFetch(`${API_URL}/profile`);
+  return response.json();
+};
+
+export const logout = async () => {
+  const refreshToken = localStorage.getItem('refreshToken');
+  if (!refreshToken) {
+    return;
+  }
+  await fetch(`${API_URL}/auth/logout`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ refreshToken }),
   });
+};
+
+export const getSessions = async () => {
+  const response = await authFetch(`${API_URL}/sessions`);
   return response.json();
 };
+
+export const revokeSession = async (id) => {
+  const response = await authFetch(`${API_URL}/sessions/${id}`, { method: 'DELETE' });
+  return response.ok;
+};
+
+const postJSON = async (path, body) => {
+  const response = await fetch(`${API_URL}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  });
+  return response.ok;
+};
+
+export const verifyEmail = (token) => postJSON('/auth/verify-email', { token });
+
+export const resendVerificationEmail = async () => {
+  const response = await authFetch(`${API_URL}/auth/verify-email/request`, { method: 'POST' });
+  return response.ok;
+};
+
+export const requestPasswordReset = (email) => postJSON('/auth/password-reset/request', { email });
+
+export const resetPassword = (token, password) => postJSON('/auth/password-reset', { token, password });
+
+export const requestOTP = (phone) => postJSON('/auth/otp/request', { phone });
+
+// verifyOTP logs in with a texted code. name is only needed the first time a
+// number is used, to create the account.
+export const verifyOTP = async (phone, code, name) => {
+  const response = await fetch(`${API_URL}/auth/otp/verify`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ phone, code, name }),
+  });
+  return response.json().catch(() => ({}));
+};
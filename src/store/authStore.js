@@ -1,8 +1,10 @@
 import { create } from 'zustand';
-import { login as apiLogin, signup as apiSignup, getProfile as apiGetProfile } from '../services/api';
+import { login as apiLogin, logout as apiLogout, signup as apiSignup, getProfile as apiGetProfile, verifyOTP as apiVerifyOTP, verifyMFA as apiVerifyMFA } from '../services/api';
 
 const useAuthStore = create((set) => ({
   user: null,
+  // mfaToken is set while a login waits for its second factor
+  mfaToken: null,
   currency: 'USD', // Default currency
   rehydrate: async () => {
     const token = localStorage.getItem('token');
@@ -15,14 +17,20 @@ const useAuthStore = create((set) => ({
       } catch (error) {
         console.error("Failed to rehydrate auth state:", error);
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
       }
     }
   },
   login: async (email, password) => {
     try {
       const response = await apiLogin(email, password);
+      if (response.mfaRequired) {
+        set({ mfaToken: response.mfaToken, pendingEmail: email });
+        return 'mfa';
+      }
       if (response.token) {
         localStorage.setItem('token', response.token);
+        localStorage.setItem('refreshToken', response.refreshToken);
         set({ user: { email: email, name: response.name, bookings: [], preferredLocations: [] } }); // Assuming name is returned
         return true;
       } else {
@@ -33,8 +41,45 @@ const useAuthStore = create((set) => ({
       return false;
     }
   },
-  logout: () => {
+  verifyMFA: async (code, recoveryCode) => {
+    try {
+      const { mfaToken, pendingEmail } = useAuthStore.getState();
+      const response = await apiVerifyMFA(mfaToken, code, recoveryCode);
+      if (response.token) {
+        localStorage.setItem('token', response.token);
+        localStorage.setItem('refreshToken', response.refreshToken);
+        set({ mfaToken: null, pendingEmail: null, user: { email: pendingEmail, name: response.name, bookings: [], preferredLocations: [] } });
+        return true;
+      }
+      return false;
+    } catch (error) {
+      console.error("Two-factor verification failed:", error);
+      return false;
+    }
+  },
+  loginWithPhone: async (phone, code, name) => {
+    try {
+      const response = await apiVerifyOTP(phone, code, name);
+      if (response.token) {
+        localStorage.setItem('token', response.token);
+        localStorage.setItem('refreshToken', response.refreshToken);
+        set({ user: { phone: phone, name: response.name, bookings: [], preferredLocations: [] } });
+        return true;
+      }
+      return false;
+    } catch (error) {
+      console.error("Phone login failed:", error);
+      return false;
+    }
+  },
+  logout: async () => {
+    try {
+      await apiLogout();
+    } catch (error) {
+      console.error("Logout failed:", error);
+    }
     localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
     set({ user: null });
   },
   signup: async (name, email, password) => {